@@ -2,22 +2,41 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"time"
 
 	"github.com/brandon1024/OpenEVT/internal/evt"
-	"github.com/brandon1024/OpenEVT/internal/types"
 	"github.com/brandon1024/OpenEVT/internal/web"
 )
 
-func inverterConnect(ctx context.Context, client *evt.Client, reconnectInverval time.Duration) error {
+// Number of consecutive polls without reply after which the inverter is considered disconnected (UDP mode only).
+const maxMissedPolls = 3
+
+func inverterConnect(ctx context.Context, client *evt.Client, reconnectInverval time.Duration, proxy *evt.Proxy, locator *relocator, wifi *wifiCollector) error {
+	// a serial number learned from the inverter is learned again on every connection, in case the inverter is replaced
+	serial := client.InverterID
+
 	for {
-		connect(ctx, client)
+		client.SetSerial(serial)
+
+		// find the inverter again if its address changed, if enabled
+		if locator != nil && locator.due(client) {
+			locator.relocate(ctx, client)
+		}
+
+		err := connect(ctx, client, proxy, wifi)
+
+		if locator != nil {
+			locator.record(err)
+		}
 
 		slog.Info("connection lost to inverter; retrying...",
-			"serial", client.InverterID,
+			"serial", client.Serial(),
+			"address", client.Address,
 			"retry-interval", reconnectInverval.String(),
 		)
 
@@ -33,8 +52,8 @@ func inverterConnect(ctx context.Context, client *evt.Client, reconnectInverval
 	}
 }
 
-func connect(ctx context.Context, client *evt.Client) error {
-	slog.Info("opening tcp connection to inverter", "serial", client.InverterID, "address", client.Address)
+func connect(ctx context.Context, client *evt.Client, proxy *evt.Proxy, wifi *wifiCollector) error {
+	slog.Info("opening connection to inverter", "serial", client.Serial(), "address", client.Address, "transport", transport(client))
 
 	// Connect to the inverter
 	err := client.Connect()
@@ -46,28 +65,70 @@ func connect(ctx context.Context, client *evt.Client) error {
 
 	defer client.Close()
 
-	web.UpdateConnectionStatus(client.Address, client.InverterID, 1.0)
-	defer web.UpdateConnectionStatus(client.Address, client.InverterID, 0.0)
-
 	go func() {
 		<-ctx.Done()
 		client.Close()
 	}()
 
-	// first, poll for current state
-	err = client.Poll()
-	if err != nil {
-		return err
+	router := newRouter(client)
+	missed := 0
+
+	// share frames with downstream clients, if enabled
+	if proxy != nil {
+		router.Tap(proxy)
+	}
+
+	if client.Serial() == "" {
+		// without a serial number, the inverter can't be polled, so wait for it to push its status
+		slog.Info("waiting for inverter to identify itself", "address", client.Address, "timeout", evt.DefaultIdentifyTimeout.String())
+
+		ev, err := client.Identify(evt.DefaultIdentifyTimeout)
+		if err != nil {
+			slog.Warn("failed to identify inverter", "address", client.Address, "err", err)
+			return err
+		}
+
+		slog.Info("inverter identified", "serial", client.Serial(), "address", client.Address)
+
+		web.UpdateConnectionStatus(client.Address, client.Serial(), 1.0)
+		defer web.UpdateConnectionStatus(client.Address, client.Serial(), 0.0)
+
+		err = client.DispatchEvent(router, ev)
+		if err != nil {
+			return err
+		}
+	} else {
+		web.UpdateConnectionStatus(client.Address, client.Serial(), 1.0)
+		defer web.UpdateConnectionStatus(client.Address, client.Serial(), 0.0)
+
+		// first, poll for current state
+		err = client.Poll()
+		if err != nil {
+			return err
+		}
+	}
+
+	// scrape the admin interface of the inverter while connected, if enabled
+	if wifi != nil {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go wifi.run(ctx, client.Address, client.Serial())
 	}
 
 	// setup read loop
 	for {
-		var msg types.InverterStatus
-
-		err = client.ReadFrame(&msg)
+		err = client.Dispatch(router)
 
 		// if we reached the deadline, poll
 		if errors.Is(err, os.ErrDeadlineExceeded) {
+			missed++
+
+			// without a connection, an unresponsive inverter only shows up as missing replies
+			if client.Transport == evt.TransportUDP && missed > maxMissedPolls {
+				return fmt.Errorf("no reply from inverter after %d polls", maxMissedPolls)
+			}
+
 			err = client.Poll()
 			if err != nil {
 				return err
@@ -81,15 +142,63 @@ func connect(ctx context.Context, client *evt.Client) error {
 			continue
 		}
 
+		// the inverter at this address isn't the one we're configured for, and won't answer our polls or acks
+		if errors.Is(err, evt.ErrSerialMismatch) {
+			slog.Error("serial number mismatch: the inverter at this address reports another serial number; "+
+				"fix '--serial-number', or omit it to detect the serial number automatically",
+				"serial", client.Serial(),
+				"address", client.Address,
+				"err", err,
+			)
+
+			return err
+		}
+
 		if err != nil {
 			return err
 		}
 
+		missed = 0
+	}
+}
+
+func transport(client *evt.Client) string {
+	if client.Transport == "" {
+		return evt.TransportTCP
+	}
+
+	return client.Transport
+}
+
+func newRouter(client *evt.Client) *evt.Router {
+	router := evt.NewRouter()
+
+	update := func(ev *evt.Event) error {
 		slog.Debug("inverter status message received",
-			"power-ac", msg.Module1.OutputPowerAC+msg.Module2.OutputPowerAC,
-			"total-energy", msg.Module1.TotalEnergy+msg.Module2.TotalEnergy,
+			"type", ev.Type.String(),
+			"modules", len(ev.Status.Modules),
+			"power-ac", ev.Status.TotalOutputPowerAC(),
+			"total-energy", ev.Status.TotalEnergy(),
 		)
 
-		web.Update(client.Address, &msg)
+		web.UpdateAt(client.Address, ev.Status, ev.Timestamp)
+
+		return nil
 	}
+
+	router.HandleFunc(evt.FrameStatus, update)
+	router.HandleFunc(evt.FramePollResponse, update)
+
+	router.HandleFunc(evt.FrameUnknown, func(ev *evt.Event) error {
+		slog.Warn("unrecognized message received from inverter",
+			"control", fmt.Sprintf("0x%02x", ev.Frame.Control),
+			"command", fmt.Sprintf("0x%02x", ev.Frame.Command),
+			"frame", hex.EncodeToString(ev.Raw),
+			"err", ev.Err,
+		)
+
+		return nil
+	})
+
+	return router
 }
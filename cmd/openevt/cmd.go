@@ -11,8 +11,9 @@ import (
 	"github.com/brandon1024/cmder"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/brandon1024/OpenEVT/internal/admin"
 	"github.com/brandon1024/OpenEVT/internal/evt"
-	"github.com/brandon1024/OpenEVT/internal/web"
+	"github.com/brandon1024/OpenEVT/internal/pcap"
 )
 
 const desc = `OpenEVT - Envertec EVT400/EVT800 Client
@@ -30,11 +31,15 @@ OpenEVT is known to work with the following Envertec inverters:
 To connect to your microinverter, you need:
 
   - the LAN address and port number (e.g. '192.0.2.1:14889'),
-  - the inverter serial number (e.g. '31583078')
+  - optionally, the inverter serial number (e.g. '31583078')
+
+Without a serial number, OpenEVT waits for the inverter to push a status frame and learns the serial number from it,
+which can take a few minutes. If a serial number is given, it's checked against the serial number reported by the
+inverter, and status frames from another inverter are rejected.
 
 Before connecting to your inverter, you must set up the inverter following the instructions provided by the
 manufacturer. The inverter must be connected to your LAN and must be configured in 'TCP-Server' mode in the 'Network
-Parameter Settings'.
+Parameter Settings'. Alternatively, inverters in UDP mode can be reached with '--transport udp'.
 
 The inverter enters a low-power standby mode when there's no sunlight, so OpenEVT won't be able to connect during the
 night.
@@ -44,8 +49,26 @@ const examples = `
 # connect to inverter and listen on port 9090
 openevt --addr 192.168.2.54:14889 --serial-number 31583078
 
+# connect to inverter, learning its serial number from the inverter
+openevt --addr 192.168.2.54:14889
+
+# connect to inverter, finding it again on the network if its address changes
+openevt --serial-number 31583078 --relocate.cidr 192.168.2.0/24
+
 # connect to inverter and listen on another port
 openevt --addr 192.168.2.54:14889 --serial-number 31583078 --web.listen-address :8080
+
+# connect to inverter in UDP mode
+openevt --addr 192.168.2.54:14889 --serial-number 31583078 --transport udp
+
+# connect to inverter and export the signal strength of its Wi-Fi network, read from its admin web interface
+openevt --addr 192.168.2.54:14889 --serial-number 31583078 --wifi.scrape --experimental
+
+# connect to inverter and record all traffic to a capture file, for use with Wireshark
+openevt --addr 192.168.2.54:14889 --serial-number 31583078 --record openevt.pcapng
+
+# connect to inverter and share the connection with other clients (e.g. the EnverView app) on port 14889
+openevt --addr 192.168.2.54:14889 --serial-number 31583078 --proxy.listen-address :14889
 `
 
 var (
@@ -56,10 +79,24 @@ var (
 	cmd = &Command{
 		BaseCommand: cmder.BaseCommand{
 			CommandName: "openevt",
-			Usage:       "openevt --addr <addr> --serial-number <num>",
+			Usage:       "openevt --addr <addr> [--serial-number <num>]",
 			ShortHelp:   "Envertec EVT400/EVT800 Client",
 			Help:        desc,
 			Examples:    examples,
+			Children: []cmder.Command{
+				listenCmd,
+				relayCmd,
+				pcapCmd,
+				replayCmd,
+				decodeCmd,
+				simulateCmd,
+				discoverCmd,
+				provisionCmd,
+				diagnoseCmd,
+				statusCmd,
+				watchCmd,
+				shellCmd,
+			},
 		},
 	}
 )
@@ -67,56 +104,170 @@ var (
 type Command struct {
 	cmder.BaseCommand
 
+	exporterOptions
+
 	client evt.Client
+	proxy  evt.Proxy
+
+	record         string
+	recordMaxSize  int64
+	recordMaxFiles int
+
+	reconnectInverval time.Duration
 
-	webListenAddress       string
-	telemetryPath          string
-	disableExporterMetrics bool
-	reconnectInverval      time.Duration
+	relocateCIDR  string
+	relocateAfter int
+
+	wifi       wifiCollector
+	wifiScrape bool
+
+	experimental bool
 }
 
 func (c *Command) InitializeFlags(fs *flag.FlagSet) {
-	fs.StringVar(&c.client.InverterID, "serial-number", "", "`serial` number of your microinverter (e.g. 31583078)")
+	fs.StringVar(&c.client.InverterID, "serial-number", "", "`serial` number of your microinverter (e.g. 31583078), learned from the inverter if omitted")
 	fs.Var(alias(fs.Lookup("serial-number"), "s"))
 	fs.StringVar(&c.client.Address, "addr", "", "`address` and port of the microinverter (e.g. 192.0.2.1:14889)")
 	fs.Var(alias(fs.Lookup("addr"), "a"))
 
+	fs.StringVar(&c.client.Transport, "transport", evt.TransportTCP, "`transport` used to talk to the inverter (tcp, udp)")
+
 	fs.DurationVar(&c.client.ReadTimeout, "poll-interval", time.Duration(0), "attempt to poll the inverter status more frequently than advertised")
 	fs.DurationVar(&c.reconnectInverval, "reconnect-interval", time.Minute, "interval between connection attempts (e.g. 1m)")
 
-	fs.StringVar(&c.webListenAddress, "web.listen-address", ":9090", "`address` on which to expose metrics")
-	fs.StringVar(&c.telemetryPath, "web.telemetry-path", "/metrics", "`path` under which to expose metrics")
-	fs.BoolVar(&c.disableExporterMetrics, "web.disable-exporter-metrics", false, "exclude metrics about the exporter itself (go_*)")
+	fs.StringVar(&c.relocateCIDR, "relocate.cidr", "", "`network` in which to find the inverter by serial number when its address changes (e.g. 192.168.2.0/24)")
+	fs.IntVar(&c.relocateAfter, "relocate.after", 3, "`number` of failed connection attempts after which the inverter is located again")
+
+	fs.BoolVar(&c.wifiScrape, "wifi.scrape", false, "scrape the Wi-Fi status (e.g. signal strength) from the admin web interface of the inverter (experimental)")
+	fs.StringVar(&c.wifi.client.URL, "wifi.url", "", "`url` of the admin web interface of the inverter (defaults to port 80 at the inverter address)")
+	fs.StringVar(&c.wifi.client.Username, "wifi.username", admin.DefaultUsername, "`username` of the admin web interface")
+	fs.StringVar(&c.wifi.client.Password, "wifi.password", admin.DefaultPassword, "`password` of the admin web interface")
+	fs.DurationVar(&c.wifi.interval, "wifi.interval", 5*time.Minute, "`interval` between scrapes of the admin web interface (e.g. 5m)")
+	statusLayoutFlags(fs, "wifi.", &c.wifi.layout)
+
+	fs.StringVar(&c.record, "record", "", "record all traffic exchanged with the inverter to a pcapng `file`")
+	fs.Int64Var(&c.recordMaxSize, "record.max-size", 100, "`size` in MiB after which the capture file is rotated (0 disables rotation)")
+	fs.IntVar(&c.recordMaxFiles, "record.max-files", 10, "`number` of rotated capture files to keep (0 keeps all)")
+
+	fs.StringVar(&c.proxy.Addr, "proxy.listen-address", "", "`address` on which to share the inverter connection with other clients (e.g. :14889)")
+
+	c.exporterOptions.initializeFlags(fs)
 
 	fs.TextVar(loggerLevel, "log.level", new(slog.LevelVar), "log `level` (e.g. debug, info, warn, error)")
+
+	fs.BoolVar(&c.experimental, "experimental", false, "enable experimental features (--wifi.scrape)")
+}
+
+func (c *Command) Initialize(ctx context.Context, args []string) error {
+	// setup logger, for this command and all subcommands
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: loggerLevel,
+	})))
+
+	return nil
 }
 
 func (c *Command) Run(ctx context.Context, args []string) error {
 	if len(args) != 0 {
 		return fmt.Errorf("unexpected args: %v", args)
 	}
-	if c.client.InverterID == "" {
-		return fmt.Errorf("serial number required")
-	}
-	if c.client.Address == "" {
+	if c.client.Address == "" && c.relocateCIDR == "" {
 		return fmt.Errorf("inverter address required")
 	}
+	if c.client.Transport != evt.TransportTCP && c.client.Transport != evt.TransportUDP {
+		return fmt.Errorf("unsupported transport: %s", c.client.Transport)
+	}
+	// in UDP mode, the inverter doesn't know where to send datagrams until it's polled
+	if c.client.InverterID == "" && c.client.Transport == evt.TransportUDP {
+		return fmt.Errorf("serial number required in UDP mode")
+	}
 
-	// setup logger
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-		Level: loggerLevel,
-	})))
+	var locator *relocator
+
+	// find the inverter by serial number when its address changes, if enabled
+	if c.relocateCIDR != "" {
+		if c.client.InverterID == "" {
+			return fmt.Errorf("serial number required to relocate the inverter")
+		}
+		if c.client.Transport != evt.TransportTCP {
+			return fmt.Errorf("relocating the inverter requires the %s transport", evt.TransportTCP)
+		}
+
+		var err error
+		if locator, err = newRelocator(c.relocateCIDR, c.relocateAfter, c.client.Address); err != nil {
+			return err
+		}
+	}
+
+	var wifi *wifiCollector
+
+	// scrape the Wi-Fi status of the inverter, if enabled
+	if c.wifiScrape {
+		if !c.experimental {
+			return fmt.Errorf("scraping the Wi-Fi status relies on the unverified layout of the admin web interface; enable it with --experimental")
+		}
+
+		if c.wifi.interval <= 0 {
+			return fmt.Errorf("illegal wi-fi scrape interval: %s", c.wifi.interval)
+		}
+
+		wifi = &c.wifi
+	}
+
+	profile, err := c.profile()
+	if err != nil {
+		return err
+	}
+
+	c.client.Profile = profile
+
+	if locator != nil {
+		locator.scanner.Profile = profile
+	}
+
+	// record traffic, if enabled
+	if c.record != "" {
+		recorder := &pcap.Recorder{
+			Path:     c.record,
+			MaxSize:  c.recordMaxSize << 20,
+			MaxFiles: c.recordMaxFiles,
+			OnError: func(err error) {
+				slog.Warn("failed to record inverter traffic", "file", c.record, "err", err)
+			},
+		}
+
+		defer recorder.Close()
+
+		c.client.Recorder = recorder
+	}
 
 	grp, ctx := errgroup.WithContext(ctx)
 
+	var proxy *evt.Proxy
+
+	// launch proxy for downstream clients, if enabled
+	if c.proxy.Addr != "" {
+		proxy = &c.proxy
+		proxy.Client = &c.client
+		proxy.OnError = func(err error) {
+			slog.Warn("proxy error", "err", err)
+		}
+
+		grp.Go(func() error {
+			slog.Info("sharing inverter connection with downstream clients", "address", proxy.Addr)
+
+			return proxy.ListenAndServe(ctx)
+		})
+	}
+
 	// launch inverter client
 	grp.Go(func() error {
-		return inverterConnect(ctx, &c.client, c.reconnectInverval)
+		return inverterConnect(ctx, &c.client, c.reconnectInverval, proxy, locator, wifi)
 	})
 
 	// launch web server
 	grp.Go(func() error {
-		return web.ListenAndServe(ctx, c.webListenAddress, c.telemetryPath, c.disableExporterMetrics)
+		return c.listenAndServe(ctx)
 	})
 
 	return grp.Wait()
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brandon1024/cmder"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+const decodeDesc = `Decode frames from a hex dump.
+
+Reads hex from the arguments, or from standard input if there are none. The hex may be spaced or continuous, with or
+without '0x' prefixes, or in the ASCII-hex form used for poll and ack messages (e.g. '3638 3030 3130 ...'). Several
+frames in a row are decoded one after the other.
+
+For each frame, the frame type is identified, the structure is validated (start and end tokens, length, checksum and
+payload layout), and every field is printed with its offsets, as documented in the 'Technical Info' section of the
+README. Status frames are decoded with the model profile detected from the frame length, unless '--model' is given.
+Truncated frames are decoded as far as the data allows.
+
+Exits with an error if any frame is invalid.
+`
+
+const decodeExamples = `
+# decode a poll message in its ASCII-hex form
+openevt decode 3638303031303638313037373331353833303738303030303030303039383136
+
+# decode a status frame pasted from an issue
+pbpaste | openevt decode
+
+# decode a frame as JSON
+openevt decode --output json '68 00 10 68 10 50 31 58 30 78 00 00 00 00 71 16'
+`
+
+var (
+	decodeCmd = &DecodeCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "decode",
+			Usage:       "openevt decode [--output table|json] [<hex>...]",
+			ShortHelp:   "Decode frames from a hex dump",
+			Help:        decodeDesc,
+			Examples:    decodeExamples,
+		},
+	}
+)
+
+type DecodeCommand struct {
+	cmder.BaseCommand
+
+	output    string
+	model     string
+	modelFile string
+}
+
+func (c *DecodeCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.output, "output", "table", "output `format` (table, json)")
+	fs.Var(alias(fs.Lookup("output"), "o"))
+
+	fs.StringVar(&c.model, "model", "auto", "inverter `model` profile used to decode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&c.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+}
+
+func (c *DecodeCommand) Run(ctx context.Context, args []string) error {
+	if c.output != "table" && c.output != "json" {
+		return fmt.Errorf("unsupported output format: %s", c.output)
+	}
+
+	profile, err := resolveProfile(c.model, c.modelFile)
+	if err != nil {
+		return err
+	}
+
+	input := strings.Join(args, " ")
+	if len(args) == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		input = string(data)
+	}
+
+	data, ascii, err := parseHexDump(input)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no hex input")
+	}
+
+	form := "binary"
+	if ascii {
+		form = "ascii-hex"
+	}
+
+	var (
+		frames  []decodedFrame
+		invalid int
+	)
+
+	for len(data) > 0 {
+		// split frames by their advertised length; anything that doesn't look like a frame is decoded as a whole
+		size, err := types.FrameLen(data)
+		if err != nil || size > len(data) {
+			size = len(data)
+		}
+
+		frame := decodeFrame(data[:size], form, profile)
+		if !frame.Valid {
+			invalid++
+		}
+
+		frames = append(frames, frame)
+		data = data[size:]
+	}
+
+	if c.output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		for _, frame := range frames {
+			if err := enc.Encode(frame); err != nil {
+				return err
+			}
+		}
+	} else if err := printFrames(os.Stdout, frames); err != nil {
+		return err
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d frames invalid", invalid, len(frames))
+	}
+
+	return nil
+}
+
+// A frame decoded from a hex dump.
+type decodedFrame struct {
+	Form    string
+	Type    string
+	Length  int
+	Profile string `json:",omitempty"`
+	Valid   bool
+	Error   string `json:",omitempty"`
+	Fields  []types.FrameField
+}
+
+func decodeFrame(data []byte, form string, profile *types.Profile) decodedFrame {
+	frame := decodedFrame{Form: form, Type: "unknown", Length: len(data)}
+
+	// the profile only matters for status frames
+	if len(data) >= types.FrameHeaderLen && data[4] == types.FrameControl &&
+		(data[5] == types.CommandStatus || data[5] == types.CommandPollResponse) {
+		// truncated frames are detected by the length in their header
+		size := len(data)
+		if n, err := types.FrameLen(data); err == nil && n > size {
+			size = n
+		}
+
+		if profile == nil {
+			profile = types.DetectProfile(size)
+		}
+
+		frame.Profile = profile.Name
+	}
+
+	fields, err := types.DissectFrame(data, profile)
+	for _, field := range fields {
+		if field.Name == "command" {
+			frame.Type = field.Value
+		}
+	}
+
+	frame.Fields = fields
+	frame.Valid = err == nil
+	if err != nil {
+		frame.Error = err.Error()
+	}
+
+	return frame
+}
+
+// Print the fields of each frame as a table, with offsets in the notation of the README (e.g. [26,27] or [30-33]).
+func printFrames(w io.Writer, frames []decodedFrame) error {
+	for i, frame := range frames {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		if err := printFrame(w, fmt.Sprintf("Frame %d", i+1), frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Print a summary of the frame, starting with the title, followed by its fields as a table.
+func printFrame(w io.Writer, title string, frame decodedFrame) error {
+	summary := fmt.Sprintf("%s: %s, %d bytes, %s", title, frame.Type, frame.Length, frame.Form)
+	if frame.Profile != "" {
+		summary += fmt.Sprintf(", %s profile", frame.Profile)
+	}
+
+	fmt.Fprintln(w, summary)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OFFSET\tHEX\tFIELD\tVALUE")
+
+	for _, field := range frame.Fields {
+		value := field.Value
+		if field.Err != "" {
+			value = strings.TrimSpace(value + " (invalid: " + field.Err + ")")
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", fieldOffsets(&field), field.Data, field.Label(), value)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if !frame.Valid {
+		fmt.Fprintf(w, "invalid frame: %s\n", strings.ReplaceAll(frame.Error, "\n", "; "))
+	}
+
+	return nil
+}
+
+func fieldOffsets(field *types.FrameField) string {
+	switch field.Len() {
+	case 1:
+		return fmt.Sprintf("[%d]", field.Offset)
+	case 2:
+		return fmt.Sprintf("[%d,%d]", field.Offset, field.Offset+1)
+	default:
+		return fmt.Sprintf("[%d-%d]", field.Offset, field.Offset+field.Len()-1)
+	}
+}
+
+// Parse a hex dump: spaced or continuous hex, optionally with '0x' prefixes or separators like ':' and ','. Dumps of
+// messages in their ASCII-hex form (see [types.PollMessage]) are decoded twice, in which case ascii is true.
+func parseHexDump(s string) (data []byte, ascii bool, err error) {
+	data, err = parseHex(s)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if decoded, ok := unwrapASCIIHex(data); ok {
+		return decoded, true, nil
+	}
+
+	return data, false, nil
+}
+
+// Parse spaced or continuous hex, optionally with '0x' prefixes or separators like ':' and ','.
+func parseHex(s string) ([]byte, error) {
+	s = strings.NewReplacer("0x", "", "0X", "").Replace(s)
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n', ':', ',', '-':
+			return -1
+		default:
+			return r
+		}
+	}, s)
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("illegal hex input: %w", err)
+	}
+
+	return data, nil
+}
+
+// Decode a frame in its ASCII-hex form. Returns false if the data isn't in ASCII-hex form.
+func unwrapASCIIHex(data []byte) ([]byte, bool) {
+	// the ASCII-hex form of a frame starts with '68' (the frame start token) and only consists of hex digits
+	if !bytes.HasPrefix(bytes.ToLower(data), []byte("68")) {
+		return nil, false
+	}
+
+	decoded, err := hex.DecodeString(string(data))
+	if err != nil {
+		return nil, false
+	}
+
+	return decoded, true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/brandon1024/cmder"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+)
+
+const diagnoseDesc = `Troubleshoot the connection to the inverter.
+
+Runs a series of checks against the inverter, one after the other, and prints whether each one passed along with a
+hint on how to fix it if it failed:
+
+  resolve   the address of the inverter resolves
+  connect   the port of the inverter accepts connections
+  protocol  the inverter sends EVT frames
+  poll      the inverter answers polls (only with '--serial-number')
+  serial    the inverter reports the expected serial number
+  session   acknowledging status frames keeps the session alive
+
+Once a check fails, the checks depending on it are skipped. Without '--serial-number', the inverter can't be polled,
+so OpenEVT waits for the inverter to push a status frame, which can take a few minutes.
+
+Inverters accept only one client at a time, so stop other clients (like the EnverView app, or another OpenEVT
+instance) before diagnosing. Exits with a non-zero status if any check failed.
+`
+
+const diagnoseExamples = `
+# check the connection to the inverter
+openevt diagnose --addr 192.168.2.54:14889 --serial-number 31583078
+
+# check the connection to the inverter, keeping the session open for five minutes
+openevt diagnose --addr 192.168.2.54:14889 --serial-number 31583078 --session 5m
+`
+
+var (
+	diagnoseCmd = &DiagnoseCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "diagnose",
+			Usage:       "openevt diagnose --addr <addr> [--serial-number <num>]",
+			ShortHelp:   "Troubleshoot the connection to the inverter",
+			Help:        diagnoseDesc,
+			Examples:    diagnoseExamples,
+		},
+	}
+)
+
+type DiagnoseCommand struct {
+	cmder.BaseCommand
+
+	doctor evt.Doctor
+
+	addr      string
+	model     string
+	modelFile string
+}
+
+func (c *DiagnoseCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.doctor.InverterID, "serial-number", "", "`serial` number of your microinverter (e.g. 31583078), learned from the inverter if omitted")
+	fs.Var(alias(fs.Lookup("serial-number"), "s"))
+	fs.StringVar(&c.addr, "addr", "", "`address` and port of the microinverter (e.g. 192.0.2.1:14889)")
+	fs.Var(alias(fs.Lookup("addr"), "a"))
+
+	fs.DurationVar(&c.doctor.DialTimeout, "dial-timeout", evt.DefaultDiagnoseDialTimeout, "`time` allowed to connect to the inverter")
+	fs.DurationVar(&c.doctor.ReadTimeout, "timeout", time.Duration(0), "`time` to wait for an answer to a poll (defaults to 30s, or 5m without serial number)")
+	fs.DurationVar(&c.doctor.SessionDuration, "session", evt.DefaultDiagnoseSessionDuration, "`time` the session is kept open to check that it stays alive")
+
+	fs.StringVar(&c.model, "model", "auto", "inverter `model` profile used to decode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&c.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+}
+
+func (c *DiagnoseCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	if c.addr == "" {
+		return fmt.Errorf("inverter address required")
+	}
+	if c.doctor.SessionDuration <= 0 {
+		return fmt.Errorf("illegal session duration: %s", c.doctor.SessionDuration)
+	}
+
+	profile, err := resolveProfile(c.model, c.modelFile)
+	if err != nil {
+		return err
+	}
+
+	c.doctor.Profile = profile
+
+	fmt.Printf("diagnosing inverter at %s (the session check alone takes %s)\n\n", c.addr, c.doctor.SessionDuration)
+
+	err = c.doctor.Diagnose(ctx, c.addr, func(check *evt.Check) {
+		printCheck(os.Stdout, check)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\nall checks passed")
+
+	return nil
+}
+
+// Print the outcome of a check, with the hint indented below it, e.g.:
+//
+//	FAIL  connect   failed to connect to 192.0.2.1:14889: connection refused
+//	                hint: the inverter is up, but nothing accepts connections on this port (...)
+func printCheck(w io.Writer, check *evt.Check) {
+	fmt.Fprintf(w, "%-4s  %-8s  %s\n", check.Outcome, check.Name, check.Detail)
+
+	if check.Hint != "" {
+		fmt.Fprintf(w, "%-4s  %-8s  hint: %s\n", "", "", check.Hint)
+	}
+}
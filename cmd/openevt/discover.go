@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brandon1024/cmder"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+)
+
+const discoverDesc = `Find inverters on the LAN.
+
+Scans every address of the network for the local mode port, concurrently. For every address where the port is open,
+OpenEVT waits for a status frame to identify the inverter, and reports its address, port, serial number, module IDs
+and firmware versions.
+
+Inverters only answer polls carrying their own serial number, so without '--serial', OpenEVT waits for the inverter to
+push a status frame, which can take a minute or two. Inverters accept only one client at a time: stop other clients
+(like the EnverView app, or another OpenEVT instance) before scanning. Only inverters in 'TCP-Server' mode can be
+found this way; inverters in 'TCP-Client' mode connect to a server instead (see 'openevt listen').
+
+With '--output command' or '--output env', a ready-to-use command line or environment (e.g. for a container) is
+printed for each inverter found.
+`
+
+const discoverExamples = `
+# find inverters on the LAN
+openevt discover --cidr 192.168.2.0/24
+
+# find inverters on the LAN, and print how to connect to them
+openevt discover --cidr 192.168.2.0/24 --output command
+
+# find a specific inverter, without waiting for it to push a status frame
+openevt discover --cidr 192.168.2.0/24 --serial 31583078
+`
+
+const (
+	discoverOutputTable   = "table"
+	discoverOutputJSON    = "json"
+	discoverOutputCommand = "command"
+	discoverOutputEnv     = "env"
+)
+
+var (
+	discoverCmd = &DiscoverCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "discover",
+			Usage:       "openevt discover --cidr <network> [--output table|json|command|env]",
+			ShortHelp:   "Find inverters on the LAN",
+			Help:        discoverDesc,
+			Examples:    discoverExamples,
+		},
+	}
+)
+
+type DiscoverCommand struct {
+	cmder.BaseCommand
+
+	scanner evt.Scanner
+
+	cidr      string
+	port      uint
+	output    string
+	model     string
+	modelFile string
+}
+
+func (c *DiscoverCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.cidr, "cidr", "", "`network` to scan (e.g. 192.168.2.0/24)")
+	fs.UintVar(&c.port, "port", evt.DefaultPort, "local mode `port` of the inverters")
+	fs.StringVar(&c.scanner.InverterID, "serial", "", "poll for the inverter with this `serial` number, instead of waiting for status frames")
+
+	fs.DurationVar(&c.scanner.ReadTimeout, "timeout", evt.DefaultScanReadTimeout, "`time` to wait for a status frame from each inverter")
+	fs.DurationVar(&c.scanner.DialTimeout, "dial-timeout", evt.DefaultScanDialTimeout, "`time` allowed to connect to each address")
+	fs.IntVar(&c.scanner.Concurrency, "concurrency", evt.DefaultScanConcurrency, "`number` of addresses scanned at once")
+
+	fs.StringVar(&c.output, "output", discoverOutputTable, "output `format` (table, json, command, env)")
+	fs.Var(alias(fs.Lookup("output"), "o"))
+
+	fs.StringVar(&c.model, "model", "auto", "inverter `model` profile used to decode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&c.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+}
+
+func (c *DiscoverCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	if c.cidr == "" {
+		return fmt.Errorf("network required")
+	}
+	if c.port == 0 || c.port > 0xffff {
+		return fmt.Errorf("illegal port: %d", c.port)
+	}
+
+	network, err := netip.ParsePrefix(c.cidr)
+	if err != nil {
+		return fmt.Errorf("illegal network %q: %w", c.cidr, err)
+	}
+
+	var printer discoveryPrinter
+
+	switch c.output {
+	case discoverOutputTable:
+		printer = newDiscoveryTablePrinter(os.Stdout)
+	case discoverOutputJSON:
+		printer = &discoveryJSONPrinter{enc: json.NewEncoder(os.Stdout)}
+	case discoverOutputCommand, discoverOutputEnv:
+		printer = &discoverySnippetPrinter{w: os.Stdout, env: c.output == discoverOutputEnv}
+	default:
+		return fmt.Errorf("unsupported output format: %s", c.output)
+	}
+
+	profile, err := resolveProfile(c.model, c.modelFile)
+	if err != nil {
+		return err
+	}
+
+	c.scanner.Port = uint16(c.port)
+	c.scanner.Profile = profile
+
+	slog.Info("scanning for inverters", "network", network.Masked().String(), "port", c.port, "timeout", c.scanner.ReadTimeout.String())
+
+	found := 0
+
+	err = c.scanner.Scan(ctx, network, func(d *evt.Discovery) {
+		if d.Status != nil {
+			found++
+			slog.Info("inverter found", "address", d.Address, "serial", d.Status.InverterId)
+		} else {
+			slog.Warn("port open, but no status frame received", "address", d.Address, "err", d.Err)
+		}
+
+		printer.Print(d)
+	})
+	if err != nil {
+		return err
+	}
+
+	if found == 0 {
+		slog.Warn("no inverters found", "network", network.Masked().String())
+	}
+
+	return printer.Flush()
+}
+
+// Prints inverters found while scanning.
+type discoveryPrinter interface {
+	Print(*evt.Discovery) error
+	Flush() error
+}
+
+// Prints inverters found as an aligned table, once the scan is complete.
+type discoveryTablePrinter struct {
+	w *tabwriter.Writer
+}
+
+func newDiscoveryTablePrinter(w io.Writer) *discoveryTablePrinter {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tPORT\tSERIAL\tMODULES\tFIRMWARE\tERROR")
+
+	return &discoveryTablePrinter{w: tw}
+}
+
+func (p *discoveryTablePrinter) Print(d *evt.Discovery) error {
+	host, port, _ := net.SplitHostPort(d.Address)
+
+	if d.Status == nil {
+		_, err := fmt.Fprintf(p.w, "%s\t%s\t-\t-\t-\t%s\n", host, port, errorLine(d.Err))
+		return err
+	}
+
+	var modules, firmware []string
+	for _, module := range d.Status.Modules {
+		modules = append(modules, module.ModuleId)
+		firmware = append(firmware, module.FirmwareVersion)
+	}
+
+	_, err := fmt.Fprintf(p.w, "%s\t%s\t%s\t%s\t%s\t\n", host, port, d.Status.InverterId, strings.Join(modules, ","), strings.Join(firmware, ","))
+	return err
+}
+
+func (p *discoveryTablePrinter) Flush() error {
+	return p.w.Flush()
+}
+
+// Prints inverters found as JSON lines.
+type discoveryJSONPrinter struct {
+	enc *json.Encoder
+}
+
+type jsonDiscovery struct {
+	Address  string
+	Host     string
+	Port     string
+	Serial   string   `json:",omitempty"`
+	Modules  []string `json:",omitempty"`
+	Firmware []string `json:",omitempty"`
+	Error    string   `json:",omitempty"`
+}
+
+func (p *discoveryJSONPrinter) Print(d *evt.Discovery) error {
+	out := jsonDiscovery{Address: d.Address}
+	out.Host, out.Port, _ = net.SplitHostPort(d.Address)
+
+	if d.Status != nil {
+		out.Serial = d.Status.InverterId
+
+		for _, module := range d.Status.Modules {
+			out.Modules = append(out.Modules, module.ModuleId)
+			out.Firmware = append(out.Firmware, module.FirmwareVersion)
+		}
+	}
+
+	if d.Err != nil {
+		out.Error = d.Err.Error()
+	}
+
+	return p.enc.Encode(out)
+}
+
+func (p *discoveryJSONPrinter) Flush() error {
+	return nil
+}
+
+// Prints a ready-to-use command line, or environment variables (see 'cmder.WithEnvironmentBinding'), for each inverter
+// found.
+type discoverySnippetPrinter struct {
+	w   io.Writer
+	env bool
+}
+
+func (p *discoverySnippetPrinter) Print(d *evt.Discovery) error {
+	if d.Status == nil {
+		_, err := fmt.Fprintf(p.w, "# %s: no status frame received (%s)\n", d.Address, errorLine(d.Err))
+		return err
+	}
+
+	if p.env {
+		_, err := fmt.Fprintf(p.w, "# inverter %s\nOPENEVT_ADDR=%s\nOPENEVT_SERIALNUMBER=%s\n", d.Status.InverterId, d.Address, d.Status.InverterId)
+		return err
+	}
+
+	_, err := fmt.Fprintf(p.w, "openevt --addr %s --serial-number %s\n", d.Address, d.Status.InverterId)
+	return err
+}
+
+func (p *discoverySnippetPrinter) Flush() error {
+	return nil
+}
+
+// Format the error on a single line, since joined errors span several lines.
+func errorLine(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return strings.ReplaceAll(err.Error(), "\n", ": ")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+	"github.com/brandon1024/OpenEVT/internal/web"
+)
+
+// Options shared by commands which decode status frames and export them over HTTP.
+type exporterOptions struct {
+	model                  string
+	modelFile              string
+	webListenAddress       string
+	telemetryPath          string
+	disableExporterMetrics bool
+	enableRawMetrics       bool
+}
+
+func (o *exporterOptions) initializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.model, "model", "auto", "inverter `model` profile used to decode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&o.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+
+	fs.StringVar(&o.webListenAddress, "web.listen-address", ":9090", "`address` on which to expose metrics")
+	fs.StringVar(&o.telemetryPath, "web.telemetry-path", "/metrics", "`path` under which to expose metrics")
+	fs.BoolVar(&o.disableExporterMetrics, "web.disable-exporter-metrics", false, "exclude metrics about the exporter itself (go_*)")
+	fs.BoolVar(&o.enableRawMetrics, "web.enable-raw-metrics", false, "include raw field values of status frames (openevt_module_raw_field)")
+}
+
+// Resolve the model profile used to decode status frames. See resolveProfile.
+func (o *exporterOptions) profile() (*types.Profile, error) {
+	return resolveProfile(o.model, o.modelFile)
+}
+
+func (o *exporterOptions) listenAndServe(ctx context.Context) error {
+	return web.ListenAndServe(ctx, o.webListenAddress, o.telemetryPath, o.disableExporterMetrics, o.enableRawMetrics)
+}
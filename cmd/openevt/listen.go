@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/brandon1024/cmder"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+	"github.com/brandon1024/OpenEVT/internal/web"
+)
+
+const listenDesc = `Accept connections from inverters in 'TCP-Client' mode.
+
+Out of the box, many inverters are configured in 'TCP-Client' mode and connect to a configured server instead of
+waiting for clients to connect. In this mode, OpenEVT acts as that server: point the server IP and port of your
+inverter at OpenEVT in the 'Network Parameter Settings' of the inverter.
+
+Several inverters can connect at once. Each inverter is identified by the serial number in the first status frame it
+sends, so no serial number needs to be configured. The status of a specific inverter can be read with
+'/inverter?sn=<serial>'.
+`
+
+const listenExamples = `
+# accept inverter connections on port 14889 and expose metrics on port 9090
+openevt listen --bind :14889
+
+# with debug logging
+openevt --log.level debug listen --bind :14889
+`
+
+var (
+	listenCmd = &ListenCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "listen",
+			Usage:       "openevt listen --bind <addr>",
+			ShortHelp:   "Accept connections from inverters in TCP-Client mode",
+			Help:        listenDesc,
+			Examples:    listenExamples,
+		},
+	}
+)
+
+type ListenCommand struct {
+	cmder.BaseCommand
+
+	exporterOptions
+
+	server evt.Server
+}
+
+func (c *ListenCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.server.Addr, "bind", ":14889", "`address` on which to accept inverter connections")
+	fs.DurationVar(&c.server.ReadTimeout, "poll-interval", time.Duration(0), "attempt to poll the inverter status more frequently than advertised")
+
+	c.exporterOptions.initializeFlags(fs)
+}
+
+func (c *ListenCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+
+	profile, err := c.profile()
+	if err != nil {
+		return err
+	}
+
+	c.server.Profile = profile
+	c.server.Handler = newRouter
+
+	c.server.OnConnect = inverterConnected
+	c.server.OnDisconnect = inverterDisconnected
+
+	grp, ctx := errgroup.WithContext(ctx)
+
+	// accept inverter connections
+	grp.Go(func() error {
+		slog.Info("listening for inverter connections", "address", c.server.Addr)
+
+		return c.server.ListenAndServe(ctx)
+	})
+
+	// launch web server
+	grp.Go(func() error {
+		return c.listenAndServe(ctx)
+	})
+
+	return grp.Wait()
+}
+
+// Record that an inverter connected to us and identified itself.
+func inverterConnected(client *evt.Client) {
+	slog.Info("inverter connected", "serial", client.Serial(), "address", client.Address)
+
+	web.UpdateConnectionStatus(client.Address, client.Serial(), 1.0)
+}
+
+// Record that the connection of an inverter ended.
+func inverterDisconnected(client *evt.Client, err error) {
+	if client.Serial() == "" {
+		slog.Warn("connection closed before inverter identified itself", "address", client.Address, "err", err)
+		return
+	}
+
+	slog.Info("inverter disconnected", "serial", client.Serial(), "address", client.Address, "err", err)
+
+	web.UpdateConnectionStatus(client.Address, client.Serial(), 0.0)
+}
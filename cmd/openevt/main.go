@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -18,6 +19,26 @@ func main() {
 
 	if err != nil {
 		slog.Error("error caught - shutting down", "err", err)
+
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+
 		os.Exit(1)
 	}
 }
+
+// An error with a specific exit status, for commands used in scripts (e.g. 'openevt status').
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
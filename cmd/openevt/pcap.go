@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandon1024/cmder"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+	"github.com/brandon1024/OpenEVT/internal/pcap"
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+const pcapDesc = `Work with packet captures of inverter traffic.
+`
+
+const pcapDecodeDesc = `Decode the inverter traffic of a packet capture.
+
+Reads pcap or pcapng captures (e.g. recorded with 'openevt --record', tcpdump or Wireshark), reassembles the TCP
+streams to and from the inverter port, and decodes every frame: status frames, polls, acks and unknown frames. This is
+useful to validate support for new inverter models offline.
+
+Decoded frames are printed as a table, or as JSON lines for scripting.
+`
+
+const pcapDecodeExamples = `
+# decode a capture
+openevt pcap decode openevt.pcapng
+
+# decode a capture of an inverter on another port as JSON lines
+openevt pcap decode --port 8899 --output jsonl capture.pcap
+
+# decode a capture with a custom model profile
+openevt pcap decode --model.file evt1200.json --model EVT1200 capture.pcapng
+`
+
+var (
+	pcapCmd = &cmder.BaseCommand{
+		CommandName: "pcap",
+		Usage:       "openevt pcap <command>",
+		ShortHelp:   "Work with packet captures of inverter traffic",
+		Help:        pcapDesc,
+		Examples:    pcapDecodeExamples,
+		Children: []cmder.Command{
+			pcapDecodeCmd,
+		},
+		RunFunc: func(ctx context.Context, args []string) error {
+			return fmt.Errorf("missing command (see 'openevt pcap --help')")
+		},
+	}
+
+	pcapDecodeCmd = &PcapDecodeCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "decode",
+			Usage:       "openevt pcap decode [--output table|jsonl] <file>",
+			ShortHelp:   "Decode the inverter traffic of a packet capture",
+			Help:        pcapDecodeDesc,
+			Examples:    pcapDecodeExamples,
+		},
+	}
+)
+
+type PcapDecodeCommand struct {
+	cmder.BaseCommand
+
+	port      uint
+	output    string
+	model     string
+	modelFile string
+}
+
+func (c *PcapDecodeCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.UintVar(&c.port, "port", 14889, "decode traffic to and from this `port` (0 for all traffic)")
+	fs.StringVar(&c.output, "output", "table", "output `format` (table, jsonl)")
+	fs.Var(alias(fs.Lookup("output"), "o"))
+
+	fs.StringVar(&c.model, "model", "auto", "inverter `model` profile used to decode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&c.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+}
+
+func (c *PcapDecodeCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a single capture file but got: %v", args)
+	}
+	if c.port > 0xffff {
+		return fmt.Errorf("illegal port: %d", c.port)
+	}
+
+	var printer eventPrinter
+
+	switch c.output {
+	case "table":
+		printer = newTablePrinter(os.Stdout)
+	case "jsonl":
+		printer = newJSONPrinter(os.Stdout)
+	default:
+		return fmt.Errorf("unsupported output format: %s", c.output)
+	}
+
+	profile, err := resolveProfile(c.model, c.modelFile)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	err = evt.DecodeCapture(r, uint16(c.port), profile, func(ev *evt.CaptureEvent) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return printer.Print(ev)
+	})
+	if err != nil {
+		return err
+	}
+
+	return printer.Flush()
+}
+
+// Prints decoded frames.
+type eventPrinter interface {
+	Print(*evt.CaptureEvent) error
+	Flush() error
+}
+
+// Prints decoded frames as an aligned table.
+type tablePrinter struct {
+	w *tabwriter.Writer
+}
+
+func newTablePrinter(w io.Writer) *tablePrinter {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSOURCE\tDESTINATION\tTYPE\tSERIAL\tDETAILS")
+
+	return &tablePrinter{w: tw}
+}
+
+func (p *tablePrinter) Print(ev *evt.CaptureEvent) error {
+	_, err := fmt.Fprintf(p.w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		ev.Timestamp.Format(time.RFC3339Nano), ev.Src, ev.Dst, ev.Type, ev.InverterID(), eventDetails(ev.Event))
+
+	return err
+}
+
+func (p *tablePrinter) Flush() error {
+	return p.w.Flush()
+}
+
+// Summarize the contents of a frame in a single line.
+func eventDetails(ev *evt.Event) string {
+	switch {
+	case ev.Status != nil:
+		return fmt.Sprintf("modules=%d power=%.2fW energy=%.3fkWh", len(ev.Status.Modules), ev.Status.TotalOutputPowerAC(), ev.Status.TotalEnergy())
+	case ev.Type == evt.FrameUnknown && ev.Err != nil:
+		return fmt.Sprintf("control=0x%02x command=0x%02x len=%d err=%q", ev.Frame.Control, ev.Frame.Command, len(ev.Raw), ev.Err.Error())
+	case ev.Type == evt.FrameUnknown:
+		return fmt.Sprintf("control=0x%02x command=0x%02x len=%d", ev.Frame.Control, ev.Frame.Command, len(ev.Raw))
+	default:
+		return ""
+	}
+}
+
+// Prints decoded frames as JSON lines.
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func newJSONPrinter(w io.Writer) *jsonPrinter {
+	return &jsonPrinter{enc: json.NewEncoder(w)}
+}
+
+type jsonEvent struct {
+	Time    time.Time
+	Src     string
+	Dst     string
+	Type    string
+	Serial  string `json:",omitempty"`
+	Control byte
+	Command byte
+	Frame   types.HexBytes
+	Status  *types.InverterStatus `json:",omitempty"`
+	Error   string                `json:",omitempty"`
+}
+
+func (p *jsonPrinter) Print(ev *evt.CaptureEvent) error {
+	out := jsonEvent{
+		Time:    ev.Timestamp,
+		Src:     ev.Src.String(),
+		Dst:     ev.Dst.String(),
+		Type:    ev.Type.String(),
+		Serial:  ev.InverterID(),
+		Control: ev.Frame.Control,
+		Command: ev.Frame.Command,
+		Frame:   ev.Raw,
+		Status:  ev.Status,
+	}
+
+	if ev.Err != nil {
+		out.Error = ev.Err.Error()
+	}
+
+	return p.enc.Encode(out)
+}
+
+func (p *jsonPrinter) Flush() error {
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// Load custom model profiles from file (if any), and resolve the profile for the given model. Returns a nil profile if
+// the model is 'auto', indicating that the profile should be detected from status frames.
+func resolveProfile(model, file string) (*types.Profile, error) {
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load model profiles: %w", err)
+		}
+
+		defer f.Close()
+
+		loaded, err := types.LoadProfiles(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load model profiles from %s: %w", file, err)
+		}
+
+		for _, p := range loaded {
+			slog.Info("loaded custom model profile", "model", p.Name, "file", file)
+		}
+	}
+
+	if model == "" || strings.EqualFold(model, "auto") {
+		return nil, nil
+	}
+
+	profile, ok := types.LookupProfile(model)
+	if !ok {
+		var names []string
+		for _, p := range types.Profiles() {
+			names = append(names, p.Name)
+		}
+
+		return nil, fmt.Errorf("unknown model %q (known models: auto, %s, %s)", model, strings.Join(names, ", "), types.ProfileGeneric.Name)
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brandon1024/cmder"
+
+	"github.com/brandon1024/OpenEVT/internal/admin"
+)
+
+const provisionDesc = `Set up the inverter through its admin web interface.
+
+Reads the network configuration of the inverter from the admin web interface of its Wi-Fi module, and optionally
+changes it: the Wi-Fi network the inverter joins ('STA Settings'), its work mode ('Network Parameter Settings') and
+its port ('Other Settings'). After saving the changes, the configuration is read back to verify that every setting was
+applied, and the Wi-Fi module is restarted so that the changes take effect.
+
+To provision a new inverter, join the access point of the inverter (the SSID is the serial number of the inverter)
+and use the default '--url'. Once the inverter joined your Wi-Fi network, its admin interface can also be reached on
+its LAN address.
+
+Without any settings, the current configuration is printed and nothing is changed.
+
+The layout of the admin interface (page paths, form field names, the default '--url' and the default credentials) is
+unverified: it hasn't been checked against captures of a real Wi-Fi module. Saving a form with the wrong field names
+may change settings you didn't mean to change, so provisioning is disabled unless '--experimental' is given, and no
+form is saved unless the forms of all settings to change have the expected fields. If the pages or fields of your
+inverter differ, override them with the '--page.*' and '--field.*' flags. Don't rely on provisioning until the layout
+has been checked against a captured session of the admin interface of your inverter.
+`
+
+const provisionExamples = `
+# print the current configuration of the inverter, from its access point
+openevt provision --experimental
+
+# join the inverter to your Wi-Fi network, in TCP-Server mode on port 14889
+openevt provision --experimental --wifi.ssid MyNetwork --wifi.password hunter2 --mode tcp-server --port 14889
+
+# change the port of an inverter on the LAN, whose port setting is on another page
+openevt provision --experimental --url http://192.168.2.54 --page.other /advanced.html --port 14889
+`
+
+var (
+	provisionCmd = &ProvisionCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "provision",
+			Usage:       "openevt provision --experimental [--url <url>] [--wifi.ssid <ssid> --wifi.password <password>] [--mode <mode>] [--port <port>]",
+			ShortHelp:   "Set up the inverter through its admin web interface (experimental)",
+			Help:        provisionDesc,
+			Examples:    provisionExamples,
+		},
+	}
+)
+
+type ProvisionCommand struct {
+	cmder.BaseCommand
+
+	client   admin.Client
+	layout   admin.Layout
+	settings admin.Settings
+
+	experimental bool
+	mode         string
+	port         uint
+	noRestart    bool
+}
+
+func (c *ProvisionCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.experimental, "experimental", false, "enable this experimental command")
+
+	fs.StringVar(&c.client.URL, "url", admin.DefaultURL, "`url` of the admin web interface of the inverter")
+	fs.StringVar(&c.client.Username, "username", admin.DefaultUsername, "`username` of the admin web interface")
+	fs.StringVar(&c.client.Password, "password", admin.DefaultPassword, "`password` of the admin web interface")
+
+	fs.StringVar(&c.settings.SSID, "wifi.ssid", "", "`ssid` of the Wi-Fi network the inverter joins")
+	fs.StringVar(&c.settings.Password, "wifi.password", "", "`password` of the Wi-Fi network the inverter joins")
+	fs.StringVar(&c.mode, "mode", "", "work `mode` of the inverter (tcp-server, tcp-client, udp)")
+	fs.UintVar(&c.port, "port", 0, "`port` of the inverter (e.g. 14889)")
+
+	fs.BoolVar(&c.noRestart, "no-restart", false, "don't restart the Wi-Fi module after changing settings")
+
+	layoutFlags(fs, "", &c.layout)
+}
+
+func (c *ProvisionCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	if !c.experimental {
+		return fmt.Errorf("the layout of the admin interface is unverified, and provisioning may change the wrong settings; enable it with --experimental")
+	}
+	if c.port > 65535 {
+		return fmt.Errorf("illegal port: %d", c.port)
+	}
+	if c.settings.Password != "" && c.settings.SSID == "" {
+		return fmt.Errorf("wifi password given without ssid")
+	}
+
+	if c.mode != "" {
+		mode, ok := workMode(c.mode)
+		if !ok {
+			return fmt.Errorf("unsupported work mode: %s", c.mode)
+		}
+
+		c.settings.WorkMode = mode
+	}
+
+	c.settings.Port = uint16(c.port)
+	c.client.Layout = &c.layout
+
+	cfg, err := c.client.ReadConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if c.settings == (admin.Settings{}) {
+		return printConfig(os.Stdout, cfg)
+	}
+
+	fmt.Println("current configuration:")
+	if err := printConfig(os.Stdout, cfg); err != nil {
+		return err
+	}
+
+	cfg, err = c.client.Provision(ctx, c.settings)
+	if cfg != nil {
+		fmt.Println("\nnew configuration:")
+		printConfig(os.Stdout, cfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\nall settings verified")
+
+	if c.noRestart {
+		fmt.Println("settings take effect once the Wi-Fi module restarts")
+		return nil
+	}
+
+	if err := c.client.Restart(ctx); err != nil {
+		return err
+	}
+
+	fmt.Println("restarting the Wi-Fi module; settings take effect in a few seconds")
+
+	return nil
+}
+
+// Register flags overriding the pages and form fields of the admin interface used to read the status of the Wi-Fi
+// module, since the default layout is unverified.
+func statusLayoutFlags(fs *flag.FlagSet, prefix string, l *admin.Layout) {
+	d := admin.DefaultLayout
+
+	fs.StringVar(&l.PageSystem, prefix+"page.system", d.PageSystem, "`path` of the 'System' page of the admin web interface")
+	fs.StringVar(&l.PageNetwork, prefix+"page.network", d.PageNetwork, "`path` of the 'Network Parameter Settings' page of the admin web interface")
+
+	fs.StringVar(&l.FieldSTAIP, prefix+"field.sta-ip", d.FieldSTAIP, "`name` of the field holding the address of the inverter on the 'System' page")
+	fs.StringVar(&l.FieldSTARSSI, prefix+"field.sta-rssi", d.FieldSTARSSI, "`name` of the field holding the signal strength on the 'System' page")
+	fs.StringVar(&l.FieldFirmware, prefix+"field.firmware", d.FieldFirmware, "`name` of the field holding the firmware build on the 'System' page")
+	fs.StringVar(&l.FieldSTASSID, prefix+"field.sta-ssid", d.FieldSTASSID, "`name` of the field holding the SSID of the Wi-Fi network")
+	fs.StringVar(&l.FieldWorkMode, prefix+"field.work-mode", d.FieldWorkMode, "`name` of the field holding the work mode")
+}
+
+// Register flags overriding all pages and form fields of the admin interface, see statusLayoutFlags.
+func layoutFlags(fs *flag.FlagSet, prefix string, l *admin.Layout) {
+	statusLayoutFlags(fs, prefix, l)
+
+	d := admin.DefaultLayout
+
+	fs.StringVar(&l.PageSTA, prefix+"page.sta", d.PageSTA, "`path` of the 'STA Settings' page of the admin web interface")
+	fs.StringVar(&l.PageOther, prefix+"page.other", d.PageOther, "`path` of the 'Other Settings' page of the admin web interface")
+	fs.StringVar(&l.PageRestart, prefix+"page.restart", d.PageRestart, "`path` of the page restarting the Wi-Fi module")
+
+	fs.StringVar(&l.FieldSTAPassword, prefix+"field.sta-password", d.FieldSTAPassword, "`name` of the field holding the password of the Wi-Fi network")
+	fs.StringVar(&l.FieldPort, prefix+"field.port", d.FieldPort, "`name` of the field holding the port")
+}
+
+// Resolve the work mode, ignoring case (e.g. 'tcp-server' for 'TCP-Server').
+func workMode(mode string) (string, bool) {
+	for _, m := range []string{admin.WorkModeTCPServer, admin.WorkModeTCPClient, admin.WorkModeUDP} {
+		if strings.EqualFold(mode, m) {
+			return m, true
+		}
+	}
+
+	return "", false
+}
+
+func printConfig(w io.Writer, cfg *admin.Config) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "SSID\t%s\n", cfg.SSID)
+	fmt.Fprintf(tw, "IP ADDRESS\t%s\n", cfg.IPAddress)
+	fmt.Fprintf(tw, "WORK MODE\t%s\n", cfg.WorkMode)
+	fmt.Fprintf(tw, "PORT\t%d\n", cfg.Port)
+
+	return tw.Flush()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/brandon1024/cmder"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+)
+
+const relayDesc = `Relay connections from inverters in 'TCP-Client' mode to an upstream server.
+
+In relay mode, the inverter connects to OpenEVT (see 'openevt listen'), and OpenEVT forwards all traffic unchanged to
+the upstream server and back. Status frames are decoded in transit and exported like in the other modes, so the
+Envertec cloud and the EnverView app keep working while your data is also collected locally.
+
+OpenEVT never acknowledges frames in relay mode: the upstream server does that. If the upstream server can't be
+reached, the inverter connection is closed and the inverter will retry later.
+
+To relay to the Envertec cloud, take note of the server IP and port configured in the 'Network Parameter Settings' of
+your inverter before pointing the inverter at OpenEVT, and use them as the upstream address.
+`
+
+const relayExamples = `
+# accept inverter connections on port 14889 and forward them upstream
+openevt relay --bind :14889 --upstream 192.0.2.10:14889
+`
+
+var (
+	relayCmd = &RelayCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "relay",
+			Usage:       "openevt relay --bind <addr> --upstream <addr>",
+			ShortHelp:   "Relay inverter connections to the Envertec cloud, decoding them in transit",
+			Help:        relayDesc,
+			Examples:    relayExamples,
+		},
+	}
+)
+
+type RelayCommand struct {
+	cmder.BaseCommand
+
+	exporterOptions
+
+	relay evt.Relay
+}
+
+func (c *RelayCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.relay.Addr, "bind", ":14889", "`address` on which to accept inverter connections")
+	fs.StringVar(&c.relay.Upstream, "upstream", "", "`address` and port of the upstream server (e.g. 192.0.2.10:14889)")
+
+	c.exporterOptions.initializeFlags(fs)
+}
+
+func (c *RelayCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	if c.relay.Upstream == "" {
+		return fmt.Errorf("upstream address required")
+	}
+
+	profile, err := c.profile()
+	if err != nil {
+		return err
+	}
+
+	c.relay.Profile = profile
+	c.relay.Handler = newRouter
+	c.relay.OnConnect = inverterConnected
+	c.relay.OnDisconnect = inverterDisconnected
+
+	grp, ctx := errgroup.WithContext(ctx)
+
+	// relay inverter connections
+	grp.Go(func() error {
+		slog.Info("relaying inverter connections", "address", c.relay.Addr, "upstream", c.relay.Upstream)
+
+		return c.relay.ListenAndServe(ctx)
+	})
+
+	// launch web server
+	grp.Go(func() error {
+		return c.listenAndServe(ctx)
+	})
+
+	return grp.Wait()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+	"github.com/brandon1024/OpenEVT/internal/web"
+)
+
+// Time to wait for the inverter to answer a poll while relocating it. Inverters answer polls carrying their serial
+// number right away, so there's no need to wait for status frames pushed by other devices.
+const relocateReadTimeout = 10 * time.Second
+
+// Relocates the inverter by serial number on a network, for inverters whose address changes over time (e.g. when
+// their DHCP lease changes).
+type relocator struct {
+	// network scanned for the inverter
+	network netip.Prefix
+
+	// number of consecutive failed connection attempts after which the inverter is relocated
+	after int
+
+	scanner evt.Scanner
+
+	failures int
+}
+
+// Setup a relocator scanning the given network, on the port of the configured address (if any).
+func newRelocator(cidr string, after int, addr string) (*relocator, error) {
+	network, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("illegal network %q: %w", cidr, err)
+	}
+	if after <= 0 {
+		return nil, fmt.Errorf("illegal number of connection attempts: %d", after)
+	}
+
+	r := &relocator{
+		network: network.Masked(),
+		after:   after,
+		scanner: evt.Scanner{ReadTimeout: relocateReadTimeout},
+	}
+
+	if addr != "" {
+		_, p, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("illegal inverter address %q: %w", addr, err)
+		}
+
+		port, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("illegal inverter port %q: %w", p, err)
+		}
+
+		r.scanner.Port = uint16(port)
+	}
+
+	return r, nil
+}
+
+// Record the outcome of a connection attempt. Only failures to reach the inverter count towards relocation, and
+// reaching another inverter at its address.
+func (r *relocator) record(err error) {
+	if errors.Is(err, evt.ErrConnect) || errors.Is(err, evt.ErrSerialMismatch) {
+		r.failures++
+	} else {
+		r.failures = 0
+	}
+}
+
+// Check if the inverter should be relocated before the next connection attempt.
+func (r *relocator) due(client *evt.Client) bool {
+	return client.Address == "" || r.failures >= r.after
+}
+
+// Scan the network for the inverter, and update the address of the client if it moved. If the inverter isn't found,
+// the client keeps its address until the next relocation is due.
+func (r *relocator) relocate(ctx context.Context, client *evt.Client) {
+	r.failures = 0
+
+	slog.Info("locating inverter", "serial", client.Serial(), "network", r.network.String())
+
+	addr, err := r.scanner.Locate(ctx, r.network, client.Serial())
+	if err != nil {
+		slog.Warn("failed to locate inverter", "serial", client.Serial(), "network", r.network.String(), "err", err)
+		return
+	}
+
+	if addr == client.Address {
+		slog.Info("inverter found at its previous address", "serial", client.Serial(), "address", addr)
+		return
+	}
+
+	slog.Info("inverter relocated", "serial", client.Serial(), "previous-address", client.Address, "address", addr)
+
+	// the inverter isn't at the previous address anymore, so neither are its metrics
+	if client.Address != "" {
+		web.RemoveAddress(client.Address, client.Serial())
+	}
+
+	client.Address = addr
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/brandon1024/cmder"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+	"github.com/brandon1024/OpenEVT/internal/pcap"
+	"github.com/brandon1024/OpenEVT/internal/types"
+	"github.com/brandon1024/OpenEVT/internal/web"
+)
+
+const replayDesc = `Replay recorded inverter traffic as if it came from the inverter.
+
+Reads pcap or pcapng captures (e.g. recorded with 'openevt --record') and feeds every decoded frame through the same
+pipeline as a live inverter connection: status frames update the metrics and the '/inverter' API. This is useful to
+reproduce a bug report exactly, or to check dashboards against known traffic.
+
+By default, frames are replayed in real time, with the delays between them as recorded. Use '--speed' to replay
+faster, or '--speed 0' to replay as fast as possible. Status frames keep their original capture time (exposed as
+'openevt_last_update_timestamp_seconds' and in the '/inverter' API), unless '--timestamps rewrite' is given, in which
+case they're stamped with the time they're replayed.
+
+Once the capture is exhausted, OpenEVT exits, unless '--hold' is given to keep serving the last status.
+
+Prometheus stamps scraped samples with the time of the scrape, so replaying into a scraped exporter doesn't fill gaps
+in the past. To backfill a monitoring system after an outage or a misconfiguration, use '--openmetrics' instead: the
+metrics are written to a file in the OpenMetrics format, sampled at the capture time of every status frame, which
+'promtool tsdb create-blocks-from openmetrics' turns into blocks for Prometheus. In this mode, the capture is replayed
+as fast as possible and no metrics are served.
+`
+
+const replayExamples = `
+# replay a capture in real time and expose metrics on port 9090
+openevt replay openevt.pcapng
+
+# replay a capture 60 times faster than it was recorded, as if it was happening now
+openevt replay --speed 60 --timestamps rewrite openevt.pcapng
+
+# replay a capture as fast as possible, and keep serving the last status afterwards
+openevt replay --speed 0 --hold openevt.pcapng
+
+# backfill Prometheus with the metrics of a capture
+openevt replay --openmetrics openevt.om openevt.pcapng
+promtool tsdb create-blocks-from openmetrics openevt.om /var/lib/prometheus
+`
+
+const (
+	timestampsOriginal = "original"
+	timestampsRewrite  = "rewrite"
+)
+
+var (
+	replayCmd = &ReplayCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "replay",
+			Usage:       "openevt replay [--speed <factor>] [--timestamps original|rewrite] [--openmetrics <file>] <file>",
+			ShortHelp:   "Replay recorded inverter traffic through the exporter",
+			Help:        replayDesc,
+			Examples:    replayExamples,
+		},
+	}
+)
+
+type ReplayCommand struct {
+	cmder.BaseCommand
+
+	exporterOptions
+
+	port        uint
+	speed       float64
+	timestamps  string
+	hold        bool
+	openmetrics string
+}
+
+func (c *ReplayCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.UintVar(&c.port, "port", 14889, "replay traffic to and from this `port` (0 for all traffic)")
+	fs.Float64Var(&c.speed, "speed", 1, "replay speed `factor` relative to the capture (e.g. 1 for real time, 0 for as fast as possible)")
+	fs.StringVar(&c.timestamps, "timestamps", timestampsOriginal, "`mode` for status timestamps (original, rewrite)")
+	fs.BoolVar(&c.hold, "hold", false, "keep serving metrics once the capture is exhausted")
+	fs.StringVar(&c.openmetrics, "openmetrics", "", "write timestamped metrics to an OpenMetrics `file` for backfilling ('-' for stdout), instead of serving them")
+
+	c.exporterOptions.initializeFlags(fs)
+}
+
+func (c *ReplayCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a single capture file but got: %v", args)
+	}
+	if c.port > 0xffff {
+		return fmt.Errorf("illegal port: %d", c.port)
+	}
+	if c.speed < 0 {
+		return fmt.Errorf("illegal speed: %v", c.speed)
+	}
+	if c.timestamps != timestampsOriginal && c.timestamps != timestampsRewrite {
+		return fmt.Errorf("unsupported timestamps mode: %s", c.timestamps)
+	}
+	if c.openmetrics != "" && c.timestamps != timestampsOriginal {
+		return fmt.Errorf("--openmetrics requires --timestamps %s", timestampsOriginal)
+	}
+	if c.openmetrics != "" && c.hold {
+		return fmt.Errorf("--openmetrics can't be combined with --hold")
+	}
+
+	profile, err := c.profile()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	if c.openmetrics != "" {
+		return c.backfill(ctx, r, profile, args[0])
+	}
+
+	grp, ctx := errgroup.WithContext(ctx)
+
+	// the web server is stopped once the replay is done, unless asked to hold
+	webCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	// replay capture
+	grp.Go(func() error {
+		slog.Info("replaying capture", "file", args[0], "speed", c.speed, "timestamps", c.timestamps)
+
+		if err := c.replay(ctx, r, profile, nil); err != nil {
+			return err
+		}
+
+		slog.Info("replay complete", "file", args[0])
+
+		if !c.hold {
+			stop()
+		}
+
+		return nil
+	})
+
+	// launch web server
+	grp.Go(func() error {
+		err := c.listenAndServe(webCtx)
+		if errors.Is(err, http.ErrServerClosed) && ctx.Err() == nil {
+			return nil
+		}
+
+		return err
+	})
+
+	return grp.Wait()
+}
+
+// Replay the capture as fast as possible, and write the metrics at the time of every status frame to an OpenMetrics
+// file.
+func (c *ReplayCommand) backfill(ctx context.Context, r *pcap.Reader, profile *types.Profile, name string) error {
+	backfill := &web.Backfill{EnableRawMetrics: c.enableRawMetrics}
+
+	c.speed = 0
+
+	slog.Info("replaying capture for backfill", "file", name, "output", c.openmetrics)
+
+	err := c.replay(ctx, r, profile, func(ev *evt.CaptureEvent) error {
+		if ev.Type != evt.FrameStatus && ev.Type != evt.FramePollResponse {
+			return nil
+		}
+
+		return backfill.Snapshot(ev.Timestamp, ev.Src.Addr().String(), ev.Status)
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.openmetrics == "-" {
+		_, err := backfill.WriteTo(os.Stdout)
+		return err
+	}
+
+	f, err := os.Create(c.openmetrics)
+	if err != nil {
+		return err
+	}
+
+	if _, err := backfill.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	slog.Info("backfill written", "file", c.openmetrics)
+
+	return f.Close()
+}
+
+// Dispatch the frames of the capture to a router per inverter, pacing them according to the replay speed. If given,
+// handled is called with every frame once dispatched.
+func (c *ReplayCommand) replay(ctx context.Context, r *pcap.Reader, profile *types.Profile, handled func(*evt.CaptureEvent) error) error {
+	var (
+		routers = map[string]*evt.Router{}
+		start   time.Time
+		first   time.Time
+	)
+
+	return evt.DecodeCapture(r, uint16(c.port), profile, func(ev *evt.CaptureEvent) error {
+		if first.IsZero() {
+			start, first = time.Now(), ev.Timestamp
+		}
+
+		// wait until the frame is due
+		if c.speed > 0 {
+			due := start.Add(time.Duration(float64(ev.Timestamp.Sub(first)) / c.speed))
+
+			tm := time.NewTimer(time.Until(due))
+
+			select {
+			case <-ctx.Done():
+				tm.Stop()
+				return ctx.Err()
+			case <-tm.C:
+				break
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if c.timestamps == timestampsRewrite {
+			ev.Timestamp = time.Now()
+		}
+
+		// frames sent by the inverter are dispatched to the router of the inverter, like for inverters in
+		// 'TCP-Client' mode (see 'openevt listen')
+		addr := ev.Src.Addr().String()
+
+		router, ok := routers[addr]
+		if !ok {
+			client := &evt.Client{Address: addr, InverterID: ev.InverterID()}
+
+			router = newRouter(client)
+			routers[addr] = router
+		}
+
+		if err := router.HandleEvent(ev.Event); err != nil {
+			return err
+		}
+
+		if handled != nil {
+			return handled(ev)
+		}
+
+		return nil
+	})
+}
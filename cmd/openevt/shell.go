@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandon1024/cmder"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+const shellCommands = `  poll                     send a poll message
+  ack                      send an ack message
+  frame <cmd> [<payload>]  send a frame with the given command byte, in binary form
+  text <cmd> [<payload>]   send a frame with the given command byte, in ASCII-hex form (like polls and acks)
+  send <hex>               send raw bytes, as-is
+  help                     print this help
+  quit                     close the connection and exit
+`
+
+const shellDesc = `EXPERIMENTAL: Interactive console to exchange raw frames with the inverter.
+
+The shell is meant for exploring the protocol (e.g. looking for commands to read settings or limit power). Sending
+unknown commands may change the settings of the inverter or leave it in an unknown state, so the shell is disabled
+unless '--experimental' is given. Use at your own risk.
+
+Commands are read from standard input, one per line:
+
+` + shellCommands + `
+In hex arguments, '{sn}' is replaced by the serial number of the inverter. Frames sent with 'frame' and 'text' have
+control byte 0x10 and their length and checksum filled in. Their payload defaults to '{sn}00000000', like polls and
+acks.
+
+Frames received from the inverter are printed as they arrive, raw and decoded, with the time elapsed since the last
+frame was sent. Status frames are acknowledged automatically, unless '--no-ack' is given. All traffic exchanged with
+the inverter and all commands are written to a session log.
+`
+
+const shellExamples = `
+# open a shell to the inverter
+openevt shell --experimental --addr 192.168.2.54:14889 --serial-number 31583078
+
+# send a frame with command byte 0x11 and the default payload, then the same frame as raw bytes (the checksum is only
+# valid for serial number 31583078)
+evt> frame 11
+evt> send 68 0010 68 10 11 {sn} 00000000 32 16
+`
+
+var (
+	shellCmd = &ShellCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "shell",
+			Usage:       "openevt shell --experimental --addr <addr> --serial-number <num>",
+			ShortHelp:   "Exchange raw frames with the inverter (experimental)",
+			Help:        shellDesc,
+			Examples:    shellExamples,
+		},
+	}
+)
+
+type ShellCommand struct {
+	cmder.BaseCommand
+
+	client evt.Client
+
+	experimental bool
+	noAck        bool
+	log          string
+	model        string
+	modelFile    string
+}
+
+func (c *ShellCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.experimental, "experimental", false, "enable this experimental command")
+
+	fs.StringVar(&c.client.InverterID, "serial-number", "", "`serial` number of your microinverter (e.g. 31583078)")
+	fs.Var(alias(fs.Lookup("serial-number"), "s"))
+	fs.StringVar(&c.client.Address, "addr", "", "`address` and port of the microinverter (e.g. 192.0.2.1:14889)")
+	fs.Var(alias(fs.Lookup("addr"), "a"))
+
+	fs.BoolVar(&c.noAck, "no-ack", false, "don't acknowledge status frames automatically")
+	fs.StringVar(&c.log, "log", "", "`path` of the session log (defaults to openevt-shell-<time>.log)")
+
+	fs.StringVar(&c.model, "model", "auto", "inverter `model` profile used to decode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&c.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+}
+
+func (c *ShellCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	if !c.experimental {
+		return fmt.Errorf("the shell is experimental and may leave the inverter in an unknown state; enable it with --experimental")
+	}
+	if c.client.InverterID == "" {
+		return fmt.Errorf("serial number required")
+	}
+	if c.client.Address == "" {
+		return fmt.Errorf("inverter address required")
+	}
+
+	profile, err := resolveProfile(c.model, c.modelFile)
+	if err != nil {
+		return err
+	}
+
+	if c.log == "" {
+		c.log = fmt.Sprintf("openevt-shell-%s.log", time.Now().Format("20060102-150405"))
+	}
+
+	log, err := openSessionLog(c.log)
+	if err != nil {
+		return err
+	}
+
+	defer log.Close()
+
+	c.client.Profile = profile
+	c.client.Recorder = log
+
+	if err := c.client.Connect(); err != nil {
+		return err
+	}
+
+	defer c.client.Close()
+
+	stop := context.AfterFunc(ctx, func() { c.client.Close() })
+	defer stop()
+
+	sh := &shell{client: &c.client, log: log, out: os.Stdout, profile: profile}
+
+	sh.printf("connected to %s, logging session to %s (type 'help' for help)\n", c.client.Address, c.log)
+
+	router := evt.NewRouter()
+	router.Tap(evt.HandlerFunc(sh.received))
+
+	if c.noAck {
+		router.SetAcknowledge(evt.FrameStatus, false)
+		router.SetAcknowledge(evt.FramePollResponse, false)
+	}
+
+	// print frames from the inverter as they arrive, until the connection is closed
+	closed := make(chan error, 1)
+
+	go func() {
+		for {
+			err := c.client.Dispatch(router)
+			if errors.Is(err, evt.ErrFrameDiscarded) {
+				sh.printf("< %s: discarded frame: %s\n", sh.elapsed(), errorLine(err))
+				continue
+			}
+			if err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	lines := make(chan string)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	interactive := isTerminal(os.Stdin)
+
+	for {
+		if interactive {
+			sh.printf("evt> ")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-closed:
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return fmt.Errorf("connection to inverter lost: %w", err)
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+
+			quit, err := sh.exec(line)
+			if err != nil {
+				sh.printf("error: %s\n", errorLine(err))
+			}
+			if quit {
+				return nil
+			}
+		}
+	}
+}
+
+// Check if the file is a terminal (rather than a pipe or a regular file).
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// An interactive session with the inverter.
+type shell struct {
+	client  *evt.Client
+	log     *sessionLog
+	out     io.Writer
+	profile *types.Profile
+
+	// guards out and sent, shared by the input loop and the reader
+	mux  sync.Mutex
+	sent time.Time
+}
+
+// Execute a command line. Returns true if the session should end.
+func (sh *shell) exec(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	sh.log.note(line)
+
+	switch fields[0] {
+	case "help":
+		sh.printf("%s", shellCommands)
+	case "quit", "exit":
+		return true, nil
+	case "poll":
+		msg, err := types.NewPollMessage(sh.client.InverterID)
+		if err != nil {
+			return false, err
+		}
+
+		return false, sh.send(msg)
+	case "ack":
+		msg, err := types.NewAckMessage(sh.client.InverterID)
+		if err != nil {
+			return false, err
+		}
+
+		return false, sh.send(msg)
+	case "frame", "text":
+		msg, err := sh.frame(fields[1:], fields[0] == "text")
+		if err != nil {
+			return false, err
+		}
+
+		return false, sh.send(msg)
+	case "send":
+		msg, err := parseHex(sh.expand(strings.Join(fields[1:], "")))
+		if err != nil {
+			return false, err
+		}
+		if len(msg) == 0 {
+			return false, fmt.Errorf("nothing to send")
+		}
+
+		return false, sh.send(msg)
+	default:
+		return false, fmt.Errorf("unknown command %q (type 'help' for help)", fields[0])
+	}
+
+	return false, nil
+}
+
+// Build a frame from a command byte and an optional payload, in binary or ASCII-hex form.
+func (sh *shell) frame(args []string, text bool) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("command byte required")
+	}
+
+	command, err := parseHex(args[0])
+	if err != nil || len(command) != 1 {
+		return nil, fmt.Errorf("illegal command byte: %s", args[0])
+	}
+
+	payload := "{sn}00000000"
+	if len(args) > 1 {
+		payload = strings.Join(args[1:], "")
+	}
+
+	data, err := parseHex(sh.expand(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	frame := types.Frame{Control: types.FrameControl, Command: command[0], Payload: data}
+
+	if text {
+		return frame.MarshalText()
+	}
+
+	return frame.MarshalBinary()
+}
+
+// Replace '{sn}' with the inverter serial number, whose digits are also its hex representation.
+func (sh *shell) expand(s string) string {
+	return strings.ReplaceAll(s, "{sn}", sh.client.InverterID)
+}
+
+// Send the message to the inverter, and print it.
+func (sh *shell) send(msg []byte) error {
+	sh.mux.Lock()
+	sh.sent = time.Now()
+	sh.mux.Unlock()
+
+	if _, err := sh.client.Write(msg); err != nil {
+		return err
+	}
+
+	sh.printf("> %s\n", hex.EncodeToString(msg))
+
+	if data, ok := unwrapASCIIHex(msg); ok {
+		sh.printFrame("> sent", decodeFrame(data, "ascii-hex", sh.profile))
+	} else {
+		sh.printFrame("> sent", decodeFrame(msg, "binary", sh.profile))
+	}
+
+	return nil
+}
+
+// Print a frame received from the inverter.
+func (sh *shell) received(ev *evt.Event) error {
+	title := "< " + sh.elapsed()
+
+	sh.printf("%s %s\n", title, hex.EncodeToString(ev.Raw))
+	sh.printFrame(title, decodeFrame(ev.Raw, "binary", sh.profile))
+
+	return nil
+}
+
+// Time elapsed since the last frame was sent (e.g. '+152ms').
+func (sh *shell) elapsed() string {
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	if sh.sent.IsZero() {
+		return "+?"
+	}
+
+	return "+" + time.Since(sh.sent).Round(time.Millisecond).String()
+}
+
+func (sh *shell) printf(format string, args ...any) {
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	fmt.Fprintf(sh.out, format, args...)
+}
+
+func (sh *shell) printFrame(title string, frame decodedFrame) {
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	printFrame(sh.out, title, frame)
+}
+
+// A session log, with a line for each command typed and each chunk of data exchanged with the inverter, e.g.:
+//
+//	2025-06-01T12:00:00.000000000Z # poll
+//	2025-06-01T12:00:00.000000000Z > 3638303031303638...
+//	2025-06-01T12:00:00.152000000Z < 6800566810513058...
+type sessionLog struct {
+	mux sync.Mutex
+	f   *os.File
+}
+
+func openSessionLog(path string) (*sessionLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session log: %w", err)
+	}
+
+	return &sessionLog{f: f}, nil
+}
+
+// Record data received from (inbound) or sent to (outbound) the inverter.
+func (l *sessionLog) Record(conn net.Conn, inbound bool, data []byte) {
+	direction := ">"
+	if inbound {
+		direction = "<"
+	}
+
+	l.write(direction, hex.EncodeToString(data))
+}
+
+// Record a command typed in the shell.
+func (l *sessionLog) note(line string) {
+	l.write("#", line)
+}
+
+// Logging is best-effort, a failed write doesn't end the session.
+func (l *sessionLog) write(kind, s string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	fmt.Fprintf(l.f, "%s %s %s\n", time.Now().UTC().Format("2006-01-02T15:04:05.000000000Z"), kind, s)
+}
+
+func (l *sessionLog) Close() error {
+	return l.f.Close()
+}
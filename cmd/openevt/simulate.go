@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brandon1024/cmder"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/brandon1024/OpenEVT/internal/admin"
+	"github.com/brandon1024/OpenEVT/internal/sim"
+)
+
+const simulateDesc = `Simulate an inverter, for testing without hardware.
+
+The simulated inverter behaves like an inverter in 'TCP-Server' mode: clients (like OpenEVT itself, or the EnverView
+app) connect to it, it answers polls, and it pushes status frames periodically. Like real inverters, it hangs up on
+clients which don't acknowledge status frames in time, and goes into standby while the sun is down.
+
+Status frames follow a daily power curve, with some variability from passing clouds. To test at night, start the
+simulated day at another time of day with '--clock', or speed it up with '--time-scale'.
+
+Faults can be injected to test how clients cope with misbehaving inverters: dropped connections, garbage bytes between
+frames, frames split across several writes and standby periods. Fault probabilities are in the range [0, 1].
+
+With '--admin.listen-address', a stand-in for the admin web interface of the inverter is served too (with the default
+credentials), for use with 'openevt provision' and '--wifi.scrape'. It serves the same unverified layout OpenEVT
+assumes by default, so it can't tell whether that layout matches a real inverter.
+`
+
+const simulateExamples = `
+# simulate an inverter on port 14889, and connect to it
+openevt simulate --serial 31583078 --listen :14889
+openevt --addr localhost:14889 --serial-number 31583078 --web.listen-address :9091
+
+# simulate a whole day in 24 minutes, starting at sunrise
+openevt simulate --serial 31583078 --clock 06:00 --time-scale 60 --interval 10s
+
+# simulate an inverter and its admin web interface, and provision it
+openevt simulate --serial 31583078 --admin.listen-address :8080
+openevt provision --experimental --url http://localhost:8080 --port 8899
+
+# simulate a flaky inverter
+openevt simulate --serial 31583078 --fault.drop 0.05 --fault.garbage 0.1 --fault.split 0.2
+`
+
+var (
+	simulateCmd = &SimulateCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "simulate",
+			Usage:       "openevt simulate --serial <num> [--listen <addr>]",
+			ShortHelp:   "Simulate an inverter, for testing without hardware",
+			Help:        simulateDesc,
+			Examples:    simulateExamples,
+		},
+	}
+)
+
+type SimulateCommand struct {
+	cmder.BaseCommand
+
+	inverter sim.Inverter
+
+	listen    string
+	adminAddr string
+	model     string
+	modelFile string
+	clock     string
+	timeScale float64
+}
+
+func (c *SimulateCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.inverter.Serial, "serial", "", "`serial` number of the simulated inverter (e.g. 31583078)")
+	fs.StringVar(&c.listen, "listen", ":14889", "`address` on which to accept clients")
+	fs.StringVar(&c.adminAddr, "admin.listen-address", "", "`address` on which to serve a stand-in for the admin web interface (e.g. :8080)")
+
+	fs.IntVar(&c.inverter.Modules, "modules", sim.DefaultModules, "`number` of inverter modules")
+	fs.StringVar(&c.model, "model", "auto", "inverter `model` profile used to encode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&c.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+
+	fs.DurationVar(&c.inverter.Interval, "interval", sim.DefaultInterval, "`interval` between status frames pushed to clients")
+	fs.DurationVar(&c.inverter.AckTimeout, "ack-timeout", sim.DefaultAckTimeout, "`time` allowed for clients to acknowledge a status frame")
+
+	fs.Float64Var(&c.inverter.Curve.PeakPower, "peak-power", sim.DefaultPeakPower, "peak output `power` of a module, in W")
+	fs.Float64Var(&c.inverter.Curve.Clouds, "clouds", 0.2, "`fraction` of power lost to passing clouds, at most")
+	fs.DurationVar(&c.inverter.Curve.Sunrise, "sunrise", sim.DefaultSunrise, "`time` of day of sunrise (e.g. 6h30m)")
+	fs.DurationVar(&c.inverter.Curve.Sunset, "sunset", sim.DefaultSunset, "`time` of day of sunset (e.g. 20h)")
+	fs.Float64Var(&c.inverter.Energy, "energy", 0, "total `energy` of each module when the simulation starts, in kWh")
+
+	fs.StringVar(&c.clock, "clock", "", "simulated `time` of day when the simulation starts (e.g. 12:00, defaults to now)")
+	fs.Float64Var(&c.timeScale, "time-scale", 1, "simulated time elapsed per second, in seconds (e.g. 60 for a day in 24 minutes)")
+	fs.BoolVar(&c.inverter.NoStandby, "no-standby", false, "stay out of standby at night")
+
+	fs.Float64Var(&c.inverter.Faults.Drop, "fault.drop", 0, "`probability` of dropping the connection instead of sending a status frame")
+	fs.Float64Var(&c.inverter.Faults.Garbage, "fault.garbage", 0, "`probability` of sending garbage bytes before a status frame")
+	fs.Float64Var(&c.inverter.Faults.Split, "fault.split", 0, "`probability` of splitting a status frame across two writes")
+	fs.Float64Var(&c.inverter.Faults.Standby, "fault.standby", 0, "`probability` of going into standby at each push interval")
+	fs.DurationVar(&c.inverter.Faults.StandbyDuration, "fault.standby-duration", sim.DefaultStandbyDuration, "`duration` of injected standby periods")
+	fs.Uint64Var(&c.inverter.Faults.Seed, "fault.seed", 0, "`seed` for fault injection, for reproducible runs (0 for random)")
+}
+
+func (c *SimulateCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	if c.inverter.Serial == "" {
+		return fmt.Errorf("serial number required")
+	}
+	if c.timeScale <= 0 {
+		return fmt.Errorf("illegal time scale: %v", c.timeScale)
+	}
+
+	profile, err := resolveProfile(c.model, c.modelFile)
+	if err != nil {
+		return err
+	}
+
+	c.inverter.Profile = profile
+
+	// the simulated clock starts at the given time of day, and runs at the given scale
+	start := time.Now()
+	clock := start
+
+	if c.clock != "" {
+		tod, err := time.Parse("15:04", c.clock)
+		if err != nil {
+			return fmt.Errorf("illegal clock %q: %w", c.clock, err)
+		}
+
+		clock = time.Date(start.Year(), start.Month(), start.Day(), tod.Hour(), tod.Minute(), 0, 0, start.Location())
+	}
+
+	c.inverter.Clock = func() time.Time {
+		return clock.Add(time.Duration(float64(time.Since(start)) * c.timeScale))
+	}
+
+	slog.Info("simulating inverter",
+		"serial", c.inverter.Serial,
+		"address", c.listen,
+		"modules", c.inverter.Modules,
+		"clock", c.inverter.Clock().Format(time.TimeOnly),
+		"standby", c.inverter.Standby(),
+	)
+
+	grp, ctx := errgroup.WithContext(ctx)
+
+	grp.Go(func() error {
+		return c.inverter.ListenAndServe(ctx, c.listen)
+	})
+
+	if c.adminAddr != "" {
+		grp.Go(func() error {
+			return c.serveAdmin(ctx)
+		})
+	}
+
+	return grp.Wait()
+}
+
+// Serve a stand-in for the admin web interface, configured like the simulated inverter.
+func (c *SimulateCommand) serveAdmin(ctx context.Context) error {
+	ui := &sim.AdminUI{
+		Username: admin.DefaultUsername,
+		Password: admin.DefaultPassword,
+		WorkMode: admin.WorkModeTCPServer,
+
+		// the simulated inverter joined a Wi-Fi network, with fair signal strength
+		SSID:      "openevt-sim",
+		IPAddress: "127.0.0.1",
+		RSSI:      -62,
+		Firmware:  "V1.0.22-Build 20190314",
+	}
+
+	if _, port, err := net.SplitHostPort(c.listen); err == nil {
+		if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+			ui.Port = uint16(p)
+		}
+	}
+
+	server := &http.Server{Addr: c.adminAddr, Handler: ui}
+
+	context.AfterFunc(ctx, func() { server.Close() })
+
+	slog.Info("serving admin web interface", "address", c.adminAddr)
+
+	err := server.ListenAndServe()
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandon1024/cmder"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// Exit statuses of 'openevt status', besides 0 (success) and 1 (other errors).
+const (
+	statusExitConnect  = 2
+	statusExitTimeout  = 3
+	statusExitMismatch = 4
+)
+
+// Interval between polls while waiting for a status, in case a poll gets lost (e.g. in UDP mode).
+const statusPollInterval = 10 * time.Second
+
+// Time to wait for a status from a polled inverter, if no '--timeout' is given. Without serial number, the inverter
+// can't be polled, and OpenEVT waits up to 'evt.DefaultIdentifyTimeout' instead.
+const statusDefaultTimeout = 30 * time.Second
+
+const statusDesc = `Print the current status of the inverter, and exit.
+
+Connects to the inverter, polls it, and waits for a status frame. The status is printed as a table, as JSON or as
+key=value lines (which can be sourced by a shell), so that the command can be used from cron jobs and scripts without
+running the exporter.
+
+Without '--serial-number', the inverter can't be polled, so OpenEVT waits for the inverter to push a status frame,
+which can take a few minutes. The '--timeout' defaults to 30s, or to 5m without '--serial-number'.
+
+The exit status tells what went wrong:
+
+  0  the status was printed
+  1  other errors (e.g. illegal flags)
+  2  failed to connect to the inverter (e.g. it's in standby at night)
+  3  no status received before the timeout
+  4  the inverter reports another serial number
+`
+
+const statusExamples = `
+# print the status of the inverter
+openevt status --addr 192.168.2.54:14889 --serial-number 31583078
+
+# print the status of the inverter as JSON, and extract the total power
+openevt status --addr 192.168.2.54:14889 --serial-number 31583078 --output json | jq .TotalOutputPowerAC
+
+# append the total energy to a CSV file every hour (crontab)
+0 * * * * eval "$(openevt status -a 192.168.2.54:14889 -s 31583078 -o kv)" && echo "$timestamp,$total_energy" >> energy.csv
+`
+
+const (
+	statusOutputTable = "table"
+	statusOutputJSON  = "json"
+	statusOutputKV    = "kv"
+)
+
+var (
+	statusCmd = &StatusCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "status",
+			Usage:       "openevt status --addr <addr> [--serial-number <num>] [--output table|json|kv]",
+			ShortHelp:   "Print the current status of the inverter, and exit",
+			Help:        statusDesc,
+			Examples:    statusExamples,
+		},
+	}
+)
+
+type StatusCommand struct {
+	cmder.BaseCommand
+
+	client evt.Client
+
+	timeout   time.Duration
+	output    string
+	model     string
+	modelFile string
+}
+
+func (c *StatusCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.client.InverterID, "serial-number", "", "`serial` number of your microinverter (e.g. 31583078), learned from the inverter if omitted")
+	fs.Var(alias(fs.Lookup("serial-number"), "s"))
+	fs.StringVar(&c.client.Address, "addr", "", "`address` and port of the microinverter (e.g. 192.0.2.1:14889)")
+	fs.Var(alias(fs.Lookup("addr"), "a"))
+
+	fs.StringVar(&c.client.Transport, "transport", evt.TransportTCP, "`transport` used to talk to the inverter (tcp, udp)")
+	fs.DurationVar(&c.timeout, "timeout", time.Duration(0), "`time` to wait for a status from the inverter (defaults to 30s, or 5m without serial number)")
+
+	fs.StringVar(&c.output, "output", statusOutputTable, "output `format` (table, json, kv)")
+	fs.Var(alias(fs.Lookup("output"), "o"))
+
+	fs.StringVar(&c.model, "model", "auto", "inverter `model` profile used to decode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&c.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+}
+
+func (c *StatusCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	if c.client.Address == "" {
+		return fmt.Errorf("inverter address required")
+	}
+	if c.client.Transport != evt.TransportTCP && c.client.Transport != evt.TransportUDP {
+		return fmt.Errorf("unsupported transport: %s", c.client.Transport)
+	}
+	if c.client.InverterID == "" && c.client.Transport == evt.TransportUDP {
+		return fmt.Errorf("serial number required in UDP mode")
+	}
+	if c.timeout < 0 {
+		return fmt.Errorf("illegal timeout: %s", c.timeout)
+	}
+	if c.timeout == 0 {
+		c.timeout = statusDefaultTimeout
+
+		if c.client.InverterID == "" {
+			c.timeout = evt.DefaultIdentifyTimeout
+		}
+	}
+
+	var print func(io.Writer, *inverterReading) error
+
+	switch c.output {
+	case statusOutputTable:
+		print = printReadingTable
+	case statusOutputJSON:
+		print = printReadingJSON
+	case statusOutputKV:
+		print = printReadingKV
+	default:
+		return fmt.Errorf("unsupported output format: %s", c.output)
+	}
+
+	profile, err := resolveProfile(c.model, c.modelFile)
+	if err != nil {
+		return err
+	}
+
+	c.client.Profile = profile
+
+	status, err := c.readStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	return print(os.Stdout, &inverterReading{
+		InverterStatus:     *status,
+		Address:            c.client.Address,
+		Timestamp:          time.Now(),
+		TotalOutputPowerAC: status.TotalOutputPowerAC(),
+		TotalEnergy:        status.TotalEnergy(),
+	})
+}
+
+// Connect to the inverter and wait for a status, polling the inverter if its serial number is known. Errors carry the
+// exit status of the command.
+func (c *StatusCommand) readStatus(ctx context.Context) (*types.InverterStatus, error) {
+	deadline := time.Now().Add(c.timeout)
+
+	if err := c.client.Connect(); err != nil {
+		return nil, &exitError{code: statusExitConnect, err: err}
+	}
+
+	defer c.client.Close()
+
+	stop := context.AfterFunc(ctx, func() { c.client.Close() })
+	defer stop()
+
+	if c.client.InverterID == "" {
+		ev, err := c.client.Identify(c.timeout)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, &exitError{code: statusExitTimeout, err: err}
+		}
+
+		// be polite, the inverter is known to hang up on clients which don't acknowledge status frames
+		if err := c.client.Acknowledge(); err != nil {
+			slog.Debug("failed to acknowledge status frame", "address", c.client.Address, "err", err)
+		}
+
+		return ev.Status, nil
+	}
+
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, &exitError{code: statusExitTimeout, err: fmt.Errorf("no status received from inverter within %s", c.timeout)}
+		}
+
+		if err := c.client.Poll(); err != nil {
+			return nil, &exitError{code: statusExitTimeout, err: err}
+		}
+
+		c.client.ReadTimeout = min(remaining, statusPollInterval)
+
+		var status types.InverterStatus
+
+		err := c.client.ReadFrame(&status)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err == nil:
+			return &status, nil
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			// poll again
+		case errors.Is(err, evt.ErrFrameDiscarded):
+			// not a status frame, keep waiting
+		case errors.Is(err, evt.ErrSerialMismatch):
+			return nil, &exitError{code: statusExitMismatch, err: err}
+		default:
+			return nil, &exitError{code: statusExitTimeout, err: err}
+		}
+	}
+}
+
+// A status read from the inverter, as printed by 'openevt status'.
+type inverterReading struct {
+	types.InverterStatus
+
+	Address            string
+	Timestamp          time.Time
+	TotalOutputPowerAC float64
+	TotalEnergy        float64
+}
+
+func printReadingTable(w io.Writer, r *inverterReading) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "INVERTER\t%s\n", r.InverterId)
+	fmt.Fprintf(tw, "ADDRESS\t%s\n", r.Address)
+	fmt.Fprintf(tw, "TIME\t%s\n", r.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(tw, "POWER\t%.1f W\n", r.TotalOutputPowerAC)
+	fmt.Fprintf(tw, "ENERGY\t%.2f kWh\n", r.TotalEnergy)
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "MODULE\tFIRMWARE\tDC VOLTAGE\tAC POWER\tAC VOLTAGE\tFREQUENCY\tTEMPERATURE\tENERGY\t")
+
+	for _, m := range r.Modules {
+		fmt.Fprintf(tw, "%s\t%s\t%.2f V\t%.2f W\t%.2f V\t%.2f Hz\t%.2f °C\t%.2f kWh\t\n",
+			m.ModuleId, m.FirmwareVersion, m.InputVoltageDC, m.OutputPowerAC, m.OutputVoltageAC, m.OutputFrequencyAC,
+			m.Temperature, m.TotalEnergy)
+	}
+
+	return tw.Flush()
+}
+
+func printReadingJSON(w io.Writer, r *inverterReading) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// Print the status as key=value lines, with keys that are valid shell variable names (e.g. 'module1_total_energy').
+func printReadingKV(w io.Writer, r *inverterReading) error {
+	var sb strings.Builder
+
+	kv := func(key string, value any) {
+		fmt.Fprintf(&sb, "%s=%v\n", key, value)
+	}
+
+	kv("inverter_id", r.InverterId)
+	kv("address", r.Address)
+	kv("timestamp", r.Timestamp.Unix())
+	kv("total_output_power_ac", r.TotalOutputPowerAC)
+	kv("total_energy", r.TotalEnergy)
+	kv("modules", len(r.Modules))
+
+	for i, m := range r.Modules {
+		prefix := fmt.Sprintf("module%d_", i+1)
+
+		kv(prefix+"id", m.ModuleId)
+		kv(prefix+"firmware_version", m.FirmwareVersion)
+		kv(prefix+"input_voltage_dc", m.InputVoltageDC)
+		kv(prefix+"output_power_ac", m.OutputPowerAC)
+		kv(prefix+"output_voltage_ac", m.OutputVoltageAC)
+		kv(prefix+"output_frequency_ac", m.OutputFrequencyAC)
+		kv(prefix+"temperature", m.Temperature)
+		kv(prefix+"total_energy", m.TotalEnergy)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
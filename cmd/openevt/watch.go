@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brandon1024/cmder"
+
+	"github.com/brandon1024/OpenEVT/internal/evt"
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+const watchDesc = `Watch the inverter live in the terminal.
+
+Connects to the inverter and shows a dashboard that is redrawn as frames arrive: the DC voltage, AC power, AC voltage,
+frequency, temperature and energy of each module, sparklines of recent power, the state of the connection, counters
+of the frames exchanged with the inverter, and the last frame received as hex.
+
+Like the exporter, OpenEVT reconnects when the connection is lost. Without '--serial-number', OpenEVT waits for the
+inverter to push a status frame to learn its serial number, which can take a few minutes.
+
+If standard output isn't a terminal, the dashboard is printed on every status frame instead of being redrawn. Press
+Ctrl-C to quit.
+
+While the dashboard is redrawn, the most recent log messages are shown at its bottom. To keep all of them, redirect
+standard error to a file (e.g. '2>watch.log'); logs are written there too when standard error isn't the terminal.
+`
+
+const watchExamples = `
+# watch the inverter
+openevt watch --addr 192.168.2.54:14889 --serial-number 31583078
+
+# watch the inverter, polling every 5 seconds and showing the last 60 readings in sparklines
+openevt watch --addr 192.168.2.54:14889 --serial-number 31583078 --poll-interval 5s --history 60
+`
+
+// Levels of the sparklines, from lowest to highest.
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Number of bytes per line of the hex dump of the last frame.
+const hexDumpWidth = 16
+
+// Number of log messages shown at the bottom of the dashboard.
+const watchLogLines = 5
+
+var (
+	watchCmd = &WatchCommand{
+		BaseCommand: cmder.BaseCommand{
+			CommandName: "watch",
+			Usage:       "openevt watch --addr <addr> [--serial-number <num>]",
+			ShortHelp:   "Watch the inverter live in the terminal",
+			Help:        watchDesc,
+			Examples:    watchExamples,
+		},
+	}
+)
+
+type WatchCommand struct {
+	cmder.BaseCommand
+
+	client evt.Client
+
+	reconnectInterval time.Duration
+	history           int
+	model             string
+	modelFile         string
+}
+
+func (c *WatchCommand) InitializeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.client.InverterID, "serial-number", "", "`serial` number of your microinverter (e.g. 31583078), learned from the inverter if omitted")
+	fs.Var(alias(fs.Lookup("serial-number"), "s"))
+	fs.StringVar(&c.client.Address, "addr", "", "`address` and port of the microinverter (e.g. 192.0.2.1:14889)")
+	fs.Var(alias(fs.Lookup("addr"), "a"))
+
+	fs.StringVar(&c.client.Transport, "transport", evt.TransportTCP, "`transport` used to talk to the inverter (tcp, udp)")
+
+	fs.DurationVar(&c.client.ReadTimeout, "poll-interval", 10*time.Second, "`interval` between polls when the inverter is idle (e.g. 10s)")
+	fs.DurationVar(&c.reconnectInterval, "reconnect-interval", time.Minute, "interval between connection attempts (e.g. 1m)")
+	fs.IntVar(&c.history, "history", 40, "`number` of readings shown in sparklines")
+
+	fs.StringVar(&c.model, "model", "auto", "inverter `model` profile used to decode status frames (e.g. auto, EVT800, EVT400)")
+	fs.StringVar(&c.modelFile, "model.file", "", "`path` to a JSON file with custom model profiles")
+}
+
+func (c *WatchCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected args: %v", args)
+	}
+	if c.client.Address == "" {
+		return fmt.Errorf("inverter address required")
+	}
+	if c.client.Transport != evt.TransportTCP && c.client.Transport != evt.TransportUDP {
+		return fmt.Errorf("unsupported transport: %s", c.client.Transport)
+	}
+	if c.client.InverterID == "" && c.client.Transport == evt.TransportUDP {
+		return fmt.Errorf("serial number required in UDP mode")
+	}
+	if c.client.ReadTimeout <= 0 {
+		return fmt.Errorf("illegal poll interval: %s", c.client.ReadTimeout)
+	}
+	if c.history <= 0 {
+		return fmt.Errorf("illegal history length: %d", c.history)
+	}
+
+	profile, err := resolveProfile(c.model, c.modelFile)
+	if err != nil {
+		return err
+	}
+
+	c.client.Profile = profile
+
+	dash := &dashboard{
+		address: c.client.Address,
+		serial:  c.client.InverterID,
+		history: c.history,
+		power:   map[string][]float64{},
+		changed: make(chan bool, 1),
+		state:   "connecting",
+	}
+
+	interactive := isTerminal(os.Stdout)
+
+	// logs written to the terminal would garble the dashboard, so show the most recent ones on the dashboard instead,
+	// and keep writing them to standard error only if it's redirected
+	if interactive {
+		var w io.Writer = dash.log()
+		if !isTerminal(os.Stderr) {
+			w = io.MultiWriter(os.Stderr, w)
+		}
+
+		slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: loggerLevel})))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		c.watch(ctx, dash)
+	}()
+
+	if interactive {
+		dash.draw(ctx, os.Stdout)
+	} else {
+		dash.print(ctx, os.Stdout)
+	}
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+// Connect to the inverter and feed the dashboard, reconnecting until the context is cancelled.
+func (c *WatchCommand) watch(ctx context.Context, dash *dashboard) {
+	// a serial number learned from the inverter is learned again on every connection, in case the inverter is replaced
+	serial := c.client.InverterID
+
+	for {
+		c.client.SetSerial(serial)
+
+		err := c.session(ctx, dash)
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Warn("connection lost to inverter; retrying...",
+			"address", c.client.Address,
+			"retry-interval", c.reconnectInterval.String(),
+			"err", err,
+		)
+
+		dash.update(func() {
+			dash.state = fmt.Sprintf("disconnected: %s", errorLine(err))
+			dash.retry = time.Now().Add(c.reconnectInterval)
+			dash.since = time.Time{}
+		})
+
+		tm := time.NewTimer(c.reconnectInterval)
+
+		select {
+		case <-ctx.Done():
+			tm.Stop()
+			return
+		case <-tm.C:
+		}
+	}
+}
+
+// Connect to the inverter, and dispatch frames to the dashboard until the connection is lost.
+func (c *WatchCommand) session(ctx context.Context, dash *dashboard) error {
+	dash.update(func() {
+		dash.state = "connecting"
+		dash.retry = time.Time{}
+	})
+
+	if err := c.client.Connect(); err != nil {
+		return err
+	}
+
+	defer c.client.Close()
+
+	stop := context.AfterFunc(ctx, func() { c.client.Close() })
+	defer stop()
+
+	dash.update(func() {
+		dash.state = "connected"
+		dash.since = time.Now()
+		dash.connects++
+	})
+
+	router := evt.NewRouter()
+	router.Tap(evt.HandlerFunc(func(ev *evt.Event) error {
+		dash.received(ev, router.Acknowledges(ev.Type))
+		return nil
+	}))
+
+	if c.client.Serial() == "" {
+		dash.update(func() { dash.state = "connected, waiting for the inverter to identify itself" })
+
+		ev, err := c.client.Identify(evt.DefaultIdentifyTimeout)
+		if err != nil {
+			return err
+		}
+
+		dash.update(func() {
+			dash.state = "connected"
+			dash.serial = c.client.Serial()
+		})
+
+		if err := c.client.DispatchEvent(router, ev); err != nil {
+			return err
+		}
+	} else if err := c.poll(dash); err != nil {
+		return err
+	}
+
+	missed := 0
+
+	for {
+		err := c.client.Dispatch(router)
+
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			missed++
+
+			// without a connection, an unresponsive inverter only shows up as missing replies
+			if c.client.Transport == evt.TransportUDP && missed > maxMissedPolls {
+				return fmt.Errorf("no reply from inverter after %d polls", maxMissedPolls)
+			}
+
+			if err := c.poll(dash); err != nil {
+				return err
+			}
+		case errors.Is(err, evt.ErrFrameDiscarded):
+			dash.update(func() { dash.discarded++ })
+		case err != nil:
+			return err
+		default:
+			missed = 0
+		}
+	}
+}
+
+func (c *WatchCommand) poll(dash *dashboard) error {
+	if err := c.client.Poll(); err != nil {
+		return err
+	}
+
+	dash.update(func() { dash.polls++ })
+
+	return nil
+}
+
+// State of the dashboard, updated as frames arrive and drawn periodically.
+type dashboard struct {
+	mux sync.Mutex
+
+	address string
+	serial  string
+
+	// state of the connection, when it was established, and when the next connection attempt is due
+	state    string
+	since    time.Time
+	retry    time.Time
+	connects int
+
+	// counters of frames received by type, frames discarded, and messages sent
+	frames    map[evt.FrameType]int
+	discarded int
+	polls     int
+	acks      int
+
+	// last status received, and the last frame received
+	status   *types.InverterStatus
+	updated  time.Time
+	last     *evt.Event
+	lastTime time.Time
+
+	// recent power readings of each module, and of the inverter, oldest first
+	history int
+	power   map[string][]float64
+	total   []float64
+
+	// recent log messages, oldest first
+	logs []string
+
+	// signals that the dashboard changed, and whether a status was received
+	changed chan bool
+}
+
+// Update the dashboard, and signal that it changed.
+func (d *dashboard) update(f func()) {
+	d.mux.Lock()
+	f()
+	d.mux.Unlock()
+
+	d.signal(false)
+}
+
+func (d *dashboard) signal(status bool) {
+	select {
+	case d.changed <- status:
+	default:
+		// a redraw is pending already; a status always gets printed in non-interactive mode, so don't lose it
+		if status {
+			select {
+			case <-d.changed:
+			default:
+			}
+
+			d.changed <- status
+		}
+	}
+}
+
+// Writer for log messages, which keeps the most recent ones to show on the dashboard.
+func (d *dashboard) log() io.Writer {
+	return dashboardLog{d}
+}
+
+type dashboardLog struct {
+	d *dashboard
+}
+
+func (l dashboardLog) Write(p []byte) (int, error) {
+	l.d.update(func() {
+		for line := range strings.Lines(string(p)) {
+			l.d.logs = appendHistory(l.d.logs, strings.TrimSuffix(line, "\n"), watchLogLines)
+		}
+	})
+
+	return len(p), nil
+}
+
+// Record a frame received from the inverter.
+func (d *dashboard) received(ev *evt.Event, acked bool) {
+	d.mux.Lock()
+
+	if d.frames == nil {
+		d.frames = map[evt.FrameType]int{}
+	}
+
+	d.frames[ev.Type]++
+	d.last = ev
+	d.lastTime = time.Now()
+
+	if acked {
+		d.acks++
+	}
+
+	if ev.Status != nil {
+		d.status = ev.Status
+		d.updated = d.lastTime
+
+		for _, module := range ev.Status.Modules {
+			d.power[module.ModuleId] = appendHistory(d.power[module.ModuleId], module.OutputPowerAC, d.history)
+		}
+
+		d.total = appendHistory(d.total, ev.Status.TotalOutputPowerAC(), d.history)
+	}
+
+	d.mux.Unlock()
+
+	d.signal(ev.Status != nil)
+}
+
+// Redraw the dashboard in place whenever it changes (and every second, for the clocks), until the context is cancelled.
+// The alternate screen buffer is used, so that the terminal is left as it was.
+func (d *dashboard) draw(ctx context.Context, w io.Writer) {
+	fmt.Fprint(w, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(w, "\x1b[?25h\x1b[?1049l")
+
+	tk := time.NewTicker(time.Second)
+	defer tk.Stop()
+
+	for {
+		var buf bytes.Buffer
+
+		d.render(&buf, time.Now())
+		fmt.Fprintf(&buf, "\nPress Ctrl-C to quit.\n")
+
+		// move home, clear each line as it's overwritten, then clear whatever is left below
+		fmt.Fprintf(w, "\x1b[H%s\x1b[J", strings.ReplaceAll(buf.String(), "\n", "\x1b[K\n"))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.changed:
+		case <-tk.C:
+		}
+	}
+}
+
+// Print the dashboard on every status received, until the context is cancelled.
+func (d *dashboard) print(ctx context.Context, w io.Writer) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case status := <-d.changed:
+			if !status {
+				continue
+			}
+
+			d.render(w, time.Now())
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func (d *dashboard) render(w io.Writer, now time.Time) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	serial := d.serial
+	if serial == "" {
+		serial = "(unknown serial number)"
+	}
+
+	fmt.Fprintf(w, "OpenEVT - inverter %s at %s - %s\n\n", serial, d.address, now.Format(time.TimeOnly))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	state := d.state
+	switch {
+	case !d.since.IsZero():
+		state += fmt.Sprintf(" for %s", since(d.since, now))
+	case !d.retry.IsZero():
+		state += fmt.Sprintf(" (retrying in %s)", until(d.retry, now))
+	}
+
+	fmt.Fprintf(tw, "STATE\t%s\n", state)
+	fmt.Fprintf(tw, "RECEIVED\tstatus %d, poll-response %d, unknown %d, discarded %d\n",
+		d.frames[evt.FrameStatus], d.frames[evt.FramePollResponse], d.frames[evt.FrameUnknown], d.discarded)
+	fmt.Fprintf(tw, "SENT\tpolls %d, acks %d\n", d.polls, d.acks)
+	fmt.Fprintf(tw, "CONNECTIONS\t%d\n", d.connects)
+
+	if d.updated.IsZero() {
+		fmt.Fprintf(tw, "UPDATED\tnever\n")
+	} else {
+		fmt.Fprintf(tw, "UPDATED\t%s ago\n", since(d.updated, now))
+	}
+
+	tw.Flush()
+
+	if d.status != nil {
+		fmt.Fprintln(w)
+
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+
+		fmt.Fprintf(tw, "MODULE\tDC VOLTAGE\tAC POWER\tAC VOLTAGE\tFREQUENCY\tTEMPERATURE\tENERGY\t\n")
+
+		for _, m := range d.status.Modules {
+			fmt.Fprintf(tw, "%s\t%.2f V\t%.2f W\t%.2f V\t%.2f Hz\t%.2f °C\t%.2f kWh\t\n",
+				m.ModuleId, m.InputVoltageDC, m.OutputPowerAC, m.OutputVoltageAC, m.OutputFrequencyAC, m.Temperature,
+				m.TotalEnergy)
+		}
+
+		fmt.Fprintf(tw, "TOTAL\t\t%.2f W\t\t\t\t%.2f kWh\t\n", d.status.TotalOutputPowerAC(), d.status.TotalEnergy())
+
+		tw.Flush()
+
+		fmt.Fprintf(w, "\nPOWER (last %d readings)\n", d.history)
+
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+		for _, m := range d.status.Modules {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", m.ModuleId, sparkline(d.power[m.ModuleId]), powerRange(d.power[m.ModuleId]))
+		}
+
+		fmt.Fprintf(tw, "TOTAL\t%s\t%s\n", sparkline(d.total), powerRange(d.total))
+
+		tw.Flush()
+	}
+
+	if d.last != nil {
+		fmt.Fprintf(w, "\nLAST FRAME (%s, %d bytes, %s ago)\n", d.last.Type, len(d.last.Raw), since(d.lastTime, now))
+
+		for off := 0; off < len(d.last.Raw); off += hexDumpWidth {
+			line := d.last.Raw[off:min(off+hexDumpWidth, len(d.last.Raw))]
+			fmt.Fprintf(w, "%04x  % x\n", off, line)
+		}
+	}
+
+	if len(d.logs) > 0 {
+		fmt.Fprintf(w, "\nLOG\n")
+
+		for _, line := range d.logs {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// Append a reading to the history, keeping at most n readings.
+func appendHistory[T any](history []T, value T, n int) []T {
+	history = append(history, value)
+	if len(history) > n {
+		history = history[len(history)-n:]
+	}
+
+	return history
+}
+
+// Draw the readings as a sparkline, scaled from zero to the highest reading.
+func sparkline(values []float64) string {
+	peak := 0.0
+	for _, v := range values {
+		peak = math.Max(peak, v)
+	}
+
+	var sb strings.Builder
+
+	for _, v := range values {
+		level := 0
+		if peak > 0 {
+			level = int(math.Round(math.Max(v, 0) / peak * float64(len(sparkLevels)-1)))
+		}
+
+		sb.WriteRune(sparkLevels[level])
+	}
+
+	return sb.String()
+}
+
+// Describe the range of the readings (e.g. '0.00-412.40 W').
+func powerRange(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+
+	return fmt.Sprintf("%.2f-%.2f W", low, high)
+}
+
+func since(t, now time.Time) time.Duration {
+	return now.Sub(t).Truncate(time.Second)
+}
+
+func until(t, now time.Time) time.Duration {
+	return max(t.Sub(now).Truncate(time.Second), 0)
+}
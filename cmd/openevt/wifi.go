@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/admin"
+	"github.com/brandon1024/OpenEVT/internal/web"
+)
+
+// Periodically reads the status of the Wi-Fi module (signal strength, address, firmware build and work mode) from the
+// admin web interface of the inverter, and exports it alongside the inverter metrics.
+type wifiCollector struct {
+	// admin interface of the inverter. Without a URL, the admin interface is reached on the address of the inverter.
+	client admin.Client
+
+	// pages and form fields of the admin interface, since the default layout is unverified
+	layout admin.Layout
+
+	interval time.Duration
+}
+
+// Scrape the admin interface of the inverter at the given address until the context is cancelled.
+func (w *wifiCollector) run(ctx context.Context, addr, sn string) {
+	client := w.client
+	client.Layout = &w.layout
+
+	if client.URL == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			slog.Warn("failed to scrape wi-fi status", "address", addr, "err", err)
+			return
+		}
+
+		client.URL = "http://" + net.JoinHostPort(host, "80")
+	}
+
+	slog.Debug("scraping wi-fi status", "url", client.URL, "interval", w.interval.String())
+
+	tk := time.NewTicker(w.interval)
+	defer tk.Stop()
+
+	for {
+		w.scrape(ctx, &client, addr, sn)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+		}
+	}
+}
+
+func (w *wifiCollector) scrape(ctx context.Context, client *admin.Client, addr, sn string) {
+	status, err := client.ReadStatus(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	// the layout of the admin interface is unverified, so export what was found rather than nothing
+	if errors.Is(err, admin.ErrFieldMissing) {
+		slog.Warn("wi-fi status incomplete; the admin interface may differ from the assumed layout, "+
+			"see the --wifi.page.* and --wifi.field.* flags",
+			"url", client.URL,
+			"err", err,
+		)
+	} else if err != nil {
+		slog.Warn("failed to scrape wi-fi status", "url", client.URL, "err", err)
+		web.UpdateWiFiFailure(addr, sn)
+		return
+	}
+
+	slog.Debug("scraped wi-fi status", "url", client.URL, "rssi", status.RSSI, "ip", status.IPAddress)
+
+	web.UpdateWiFi(addr, sn, status, time.Now())
+}
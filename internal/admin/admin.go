@@ -0,0 +1,542 @@
+// Package admin talks to the local admin web interface of the inverter, served by its Wi-Fi module, to read and change
+// its network configuration.
+//
+// The admin interface is reachable on the LAN address of the inverter, or on its own access point (the SSID is the
+// serial number of the inverter). Settings are spread over several pages, mirroring the tabs of the interface:
+//
+//   - 'System' shows the status of the Wi-Fi module, like its address on the LAN,
+//   - 'STA Settings' holds the Wi-Fi network the inverter joins,
+//   - 'Network Parameter Settings' holds the work mode (e.g. 'TCP-Server'),
+//   - 'Other Settings' holds the port number.
+//
+// Changes are saved by submitting the form of a page, and take effect once the Wi-Fi module restarts.
+//
+// The layout of the admin interface is unverified: the page paths, form field names, DefaultURL and the default
+// credentials below follow descriptions of the interface, not captures of a real Wi-Fi module. The tests and the
+// stand-in in package sim follow the same layout, so they can't tell whether it's right. If your inverter differs,
+// override the pages and fields with a [Layout].
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Address of the admin interface on the access point of the inverter (unverified).
+	DefaultURL = "http://10.10.100.254"
+
+	// Factory default credentials of the admin interface (unverified).
+	DefaultUsername = "admin"
+	DefaultPassword = "admin"
+
+	// Time allowed for each request, if the client has no 'HTTPClient'.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Work modes of the inverter. OpenEVT connects to inverters in 'TCP-Server' mode, and accepts connections from inverters
+// in 'TCP-Client' mode (see 'openevt listen').
+const (
+	WorkModeTCPServer = "TCP-Server"
+	WorkModeTCPClient = "TCP-Client"
+	WorkModeUDP       = "UDP"
+)
+
+// Pages of the admin interface, as assumed by DefaultLayout (unverified).
+const (
+	PageSystem  = "/system.html"
+	PageSTA     = "/sta.html"
+	PageNetwork = "/network.html"
+	PageOther   = "/other.html"
+	PageRestart = "/restart.html"
+)
+
+// Form fields of the admin interface, as assumed by DefaultLayout (unverified).
+const (
+	FieldSTAIP       = "sta_ip"
+	FieldSTARSSI     = "sta_rssi"
+	FieldFirmware    = "fw_version"
+	FieldSTASSID     = "sta_ssid"
+	FieldSTAPassword = "sta_key"
+	FieldWorkMode    = "work_mode"
+	FieldPort        = "port_id"
+)
+
+// Layout of the admin interface: the paths of its pages, and the names of the form fields read and changed on them.
+type Layout struct {
+	// Pages holding the status of the Wi-Fi module, the Wi-Fi network settings, the work mode and the port, and the
+	// page restarting the Wi-Fi module.
+	PageSystem  string
+	PageSTA     string
+	PageNetwork string
+	PageOther   string
+	PageRestart string
+
+	// Address, signal strength and firmware build shown on the 'System' page.
+	FieldSTAIP    string
+	FieldSTARSSI  string
+	FieldFirmware string
+
+	// Wi-Fi network and password on the 'STA Settings' page, also shown on the 'System' page.
+	FieldSTASSID     string
+	FieldSTAPassword string
+
+	// Work mode on the 'Network Parameter Settings' page, and port on the 'Other Settings' page.
+	FieldWorkMode string
+	FieldPort     string
+}
+
+// DefaultLayout is the assumed layout of the admin interface. It hasn't been verified against a real Wi-Fi module.
+var DefaultLayout = Layout{
+	PageSystem:  PageSystem,
+	PageSTA:     PageSTA,
+	PageNetwork: PageNetwork,
+	PageOther:   PageOther,
+	PageRestart: PageRestart,
+
+	FieldSTAIP:       FieldSTAIP,
+	FieldSTARSSI:     FieldSTARSSI,
+	FieldFirmware:    FieldFirmware,
+	FieldSTASSID:     FieldSTASSID,
+	FieldSTAPassword: FieldSTAPassword,
+	FieldWorkMode:    FieldWorkMode,
+	FieldPort:        FieldPort,
+}
+
+var (
+	ErrRequest      = errors.New("admin: request to inverter failed")
+	ErrUnauthorized = errors.New("admin: wrong username or password")
+	ErrPage         = errors.New("admin: unexpected page contents")
+	ErrFieldMissing = errors.New("admin: expected form fields missing")
+	ErrVerify       = errors.New("admin: setting not applied")
+)
+
+// Config is the network configuration of the inverter.
+type Config struct {
+	// Wi-Fi network (SSID) the inverter joins.
+	SSID string
+
+	// Address of the inverter on the Wi-Fi network it joined. Empty if the inverter isn't connected. Read-only.
+	IPAddress string
+
+	// Work mode of the inverter (e.g. WorkModeTCPServer).
+	WorkMode string
+
+	// Port of the inverter, in 'TCP-Server' mode.
+	Port uint16
+}
+
+// Status is the status of the Wi-Fi module of the inverter, as shown on the 'System' page.
+type Status struct {
+	// Wi-Fi network (SSID) the inverter joined, and its address on that network. Empty if the inverter isn't
+	// connected.
+	SSID      string
+	IPAddress string
+
+	// Signal strength of the Wi-Fi network, in dBm. Zero if the inverter isn't connected.
+	RSSI int
+
+	// Firmware build of the Wi-Fi module.
+	Firmware string
+
+	// Work mode of the inverter (e.g. WorkModeTCPServer).
+	WorkMode string
+}
+
+// Settings applied by 'Provision()'. Empty settings are left unchanged.
+type Settings struct {
+	// Wi-Fi network (SSID) to join, and its password.
+	SSID     string
+	Password string
+
+	// Work mode (e.g. WorkModeTCPServer).
+	WorkMode string
+
+	// Port of the inverter, in 'TCP-Server' mode.
+	Port uint16
+}
+
+// Client of the admin interface of an inverter.
+type Client struct {
+	// Base URL of the admin interface (e.g. http://192.0.2.1). Defaults to DefaultURL.
+	URL string
+
+	// Credentials of the admin interface. Default to DefaultUsername and DefaultPassword.
+	Username string
+	Password string
+
+	// HTTP client used for requests. Defaults to a client with DefaultTimeout.
+	HTTPClient *http.Client
+
+	// Pages and form fields of the admin interface. Defaults to DefaultLayout.
+	Layout *Layout
+}
+
+// Read the network configuration of the inverter.
+func (c *Client) ReadConfig(ctx context.Context) (*Config, error) {
+	var (
+		cfg Config
+		l   = c.layout()
+	)
+
+	for _, page := range []string{l.PageSystem, l.PageSTA, l.PageNetwork, l.PageOther} {
+		f, err := c.readForm(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+
+		switch page {
+		case l.PageSystem:
+			cfg.IPAddress = f.fields.Get(l.FieldSTAIP)
+		case l.PageSTA:
+			cfg.SSID = f.fields.Get(l.FieldSTASSID)
+		case l.PageNetwork:
+			cfg.WorkMode = f.fields.Get(l.FieldWorkMode)
+		case l.PageOther:
+			port, err := strconv.ParseUint(f.fields.Get(l.FieldPort), 10, 16)
+			if err != nil {
+				return nil, errors.Join(ErrPage, fmt.Errorf("%s: illegal port: %w", page, err))
+			}
+
+			cfg.Port = uint16(port)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// Read the status of the Wi-Fi module of the inverter. If some of the expected form fields are missing (e.g. because
+// the layout of the admin interface differs), the status is returned with those fields left empty, along with
+// ErrFieldMissing.
+func (c *Client) ReadStatus(ctx context.Context) (*Status, error) {
+	l := c.layout()
+
+	body, err := c.do(ctx, http.MethodGet, l.PageSystem, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status, missing, err := l.parseStatus(body)
+	if err != nil {
+		return nil, err
+	}
+
+	network, err := c.readForm(ctx, l.PageNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	if !network.fields.Has(l.FieldWorkMode) {
+		missing = append(missing, fmt.Sprintf("%s: %s", l.PageNetwork, l.FieldWorkMode))
+	}
+
+	status.WorkMode = network.fields.Get(l.FieldWorkMode)
+
+	if len(missing) > 0 {
+		return status, errors.Join(ErrFieldMissing, fmt.Errorf("%s", strings.Join(missing, ", ")))
+	}
+
+	return status, nil
+}
+
+// Parse the 'System' page. The signal strength may be given with or without unit (e.g. '-67 dBm' or '-67'). Returns the
+// expected fields missing from the page, or ErrPage if all of them are.
+func (l *Layout) parseStatus(page string) (*Status, []string, error) {
+	f := parseForm(page)
+
+	var (
+		fields  = []string{l.FieldSTASSID, l.FieldSTAIP, l.FieldSTARSSI, l.FieldFirmware}
+		missing []string
+	)
+
+	for _, field := range fields {
+		if !f.fields.Has(field) {
+			missing = append(missing, fmt.Sprintf("%s: %s", l.PageSystem, field))
+		}
+	}
+
+	if len(missing) == len(fields) {
+		return nil, nil, errors.Join(ErrPage, fmt.Errorf("%s: status fields missing", l.PageSystem))
+	}
+
+	status := &Status{
+		SSID:      f.fields.Get(l.FieldSTASSID),
+		IPAddress: f.fields.Get(l.FieldSTAIP),
+		Firmware:  f.fields.Get(l.FieldFirmware),
+	}
+
+	if rssi := strings.TrimSpace(strings.TrimSuffix(strings.ToLower(f.fields.Get(l.FieldSTARSSI)), "dbm")); rssi != "" {
+		value, err := strconv.Atoi(rssi)
+		if err != nil {
+			return nil, nil, errors.Join(ErrPage, fmt.Errorf("%s: illegal signal strength: %w", l.PageSystem, err))
+		}
+
+		status.RSSI = value
+	}
+
+	return status, missing, nil
+}
+
+// Apply the settings, and verify them by reading the configuration back. Returns the configuration read back, and
+// ErrVerify if a setting didn't stick. Settings take effect once the Wi-Fi module restarts, see 'Restart()'.
+//
+// Nothing is submitted unless the forms of all settings to change have the expected fields, otherwise ErrFieldMissing
+// is returned: submitting a form laid out differently than assumed could change other settings by accident.
+func (c *Client) Provision(ctx context.Context, s Settings) (*Config, error) {
+	var (
+		l        = c.layout()
+		expected = map[string][]string{}
+	)
+
+	if s.SSID != "" {
+		expected[l.PageSTA] = append(expected[l.PageSTA], l.FieldSTASSID, l.FieldSTAPassword)
+	}
+	if s.WorkMode != "" {
+		expected[l.PageNetwork] = append(expected[l.PageNetwork], l.FieldWorkMode)
+	}
+	if s.Port != 0 {
+		expected[l.PageOther] = append(expected[l.PageOther], l.FieldPort)
+	}
+
+	// check all forms before submitting any, so that the settings aren't applied half-way
+	for _, page := range slices.Sorted(maps.Keys(expected)) {
+		f, err := c.readForm(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := f.expect(page, expected[page]...); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.SSID != "" {
+		if err := c.SetWiFi(ctx, s.SSID, s.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.WorkMode != "" {
+		if err := c.SetWorkMode(ctx, s.WorkMode); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Port != 0 {
+		if err := c.SetPort(ctx, s.Port); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := c.ReadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	if s.SSID != "" && cfg.SSID != s.SSID {
+		errs = append(errs, fmt.Errorf("SSID is %q, expected %q", cfg.SSID, s.SSID))
+	}
+	if s.WorkMode != "" && cfg.WorkMode != s.WorkMode {
+		errs = append(errs, fmt.Errorf("work mode is %q, expected %q", cfg.WorkMode, s.WorkMode))
+	}
+	if s.Port != 0 && cfg.Port != s.Port {
+		errs = append(errs, fmt.Errorf("port is %d, expected %d", cfg.Port, s.Port))
+	}
+
+	if len(errs) > 0 {
+		return cfg, errors.Join(append([]error{ErrVerify}, errs...)...)
+	}
+
+	return cfg, nil
+}
+
+// Set the Wi-Fi network the inverter joins.
+func (c *Client) SetWiFi(ctx context.Context, ssid, password string) error {
+	if ssid == "" {
+		return errors.Join(ErrRequest, fmt.Errorf("SSID is empty"))
+	}
+
+	l := c.layout()
+
+	return c.submit(ctx, l.PageSTA, url.Values{l.FieldSTASSID: {ssid}, l.FieldSTAPassword: {password}})
+}
+
+// Set the work mode of the inverter (e.g. WorkModeTCPServer).
+func (c *Client) SetWorkMode(ctx context.Context, mode string) error {
+	switch mode {
+	case WorkModeTCPServer, WorkModeTCPClient, WorkModeUDP:
+	default:
+		return errors.Join(ErrRequest, fmt.Errorf("unsupported work mode: %s", mode))
+	}
+
+	l := c.layout()
+
+	return c.submit(ctx, l.PageNetwork, url.Values{l.FieldWorkMode: {mode}})
+}
+
+// Set the port of the inverter.
+func (c *Client) SetPort(ctx context.Context, port uint16) error {
+	if port == 0 {
+		return errors.Join(ErrRequest, fmt.Errorf("port is zero"))
+	}
+
+	l := c.layout()
+
+	return c.submit(ctx, l.PageOther, url.Values{l.FieldPort: {strconv.Itoa(int(port))}})
+}
+
+// Restart the Wi-Fi module of the inverter, applying saved settings. The admin interface is unavailable for a few
+// seconds while the module restarts.
+func (c *Client) Restart(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, c.layout().PageRestart, url.Values{})
+	return err
+}
+
+// Submit the form of a page, changing the given fields and keeping all others as they are. The form isn't submitted if
+// any of the fields to change is missing from it.
+func (c *Client) submit(ctx context.Context, page string, values url.Values) error {
+	f, err := c.readForm(ctx, page)
+	if err != nil {
+		return err
+	}
+
+	if err := f.expect(page, slices.Sorted(maps.Keys(values))...); err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		f.fields[name] = value
+	}
+
+	action := f.action
+	if action == "" {
+		action = page
+	}
+
+	_, err = c.do(ctx, http.MethodPost, action, f.fields)
+
+	return err
+}
+
+// Check that the form of the page has all of the given fields, or return ErrFieldMissing naming the missing ones.
+func (f *form) expect(page string, names ...string) error {
+	var missing []string
+
+	for _, name := range names {
+		if !f.fields.Has(name) {
+			missing = append(missing, fmt.Sprintf("%s: %s", page, name))
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.Join(ErrFieldMissing, fmt.Errorf("%s", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+// Read the form of a page.
+func (c *Client) readForm(ctx context.Context, page string) (*form, error) {
+	body, err := c.do(ctx, http.MethodGet, page, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseForm(body), nil
+}
+
+// Send a request to the admin interface, and return the response body. Form values are sent URL-encoded.
+func (c *Client) do(ctx context.Context, method, path string, values url.Values) (string, error) {
+	target, err := url.JoinPath(c.url(), path)
+	if err != nil {
+		return "", errors.Join(ErrRequest, err)
+	}
+
+	var body io.Reader
+	if values != nil {
+		body = strings.NewReader(values.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return "", errors.Join(ErrRequest, err)
+	}
+
+	if values != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	req.SetBasicAuth(c.username(), c.password())
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", errors.Join(ErrRequest, err)
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Join(ErrRequest, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return "", ErrUnauthorized
+	case resp.StatusCode != http.StatusOK:
+		return "", errors.Join(ErrRequest, fmt.Errorf("%s %s: %s", method, path, resp.Status))
+	}
+
+	return string(data), nil
+}
+
+func (c *Client) url() string {
+	if c.URL == "" {
+		return DefaultURL
+	}
+
+	return c.URL
+}
+
+func (c *Client) username() string {
+	if c.Username == "" {
+		return DefaultUsername
+	}
+
+	return c.Username
+}
+
+func (c *Client) password() string {
+	if c.Password == "" {
+		return DefaultPassword
+	}
+
+	return c.Password
+}
+
+func (c *Client) layout() *Layout {
+	if c.Layout == nil {
+		return &DefaultLayout
+	}
+
+	return c.Layout
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return &http.Client{Timeout: DefaultTimeout}
+	}
+
+	return c.HTTPClient
+}
@@ -0,0 +1,169 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brandon1024/OpenEVT/internal/sim"
+)
+
+func TestClient(t *testing.T) {
+	standIn := func() (*sim.AdminUI, *Client) {
+		ui := &sim.AdminUI{
+			Username:  "admin",
+			Password:  "secret",
+			SSID:      "Home",
+			IPAddress: "192.0.2.54",
+			WorkMode:  "TCP-Client",
+			Port:      8899,
+		}
+
+		server := httptest.NewServer(ui)
+		t.Cleanup(server.Close)
+
+		return ui, &Client{URL: server.URL, Username: "admin", Password: "secret"}
+	}
+
+	t.Run("should read the configuration", func(t *testing.T) {
+		_, client := standIn()
+
+		cfg, err := client.ReadConfig(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if *cfg != (Config{SSID: "Home", IPAddress: "192.0.2.54", WorkMode: WorkModeTCPClient, Port: 8899}) {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+
+	t.Run("should provision and verify the settings", func(t *testing.T) {
+		ui, client := standIn()
+
+		cfg, err := client.Provision(context.Background(), Settings{
+			SSID:     "Roof",
+			Password: "hunter2",
+			WorkMode: WorkModeTCPServer,
+			Port:     14889,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		switch {
+		case cfg.SSID != "Roof" || cfg.WorkMode != WorkModeTCPServer || cfg.Port != 14889:
+			t.Fatalf("unexpected config: %+v", cfg)
+		case ui.Key != "hunter2":
+			t.Fatalf("unexpected password: %s", ui.Key)
+		}
+
+		if err := client.Restart(context.Background()); err != nil || ui.Restarts() != 1 {
+			t.Fatalf("unexpected restart: %d (%v)", ui.Restarts(), err)
+		}
+	})
+
+	t.Run("should leave unset settings unchanged", func(t *testing.T) {
+		ui, client := standIn()
+
+		if _, err := client.Provision(context.Background(), Settings{Port: 14889}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if ui.SSID != "Home" || ui.WorkMode != "TCP-Client" || ui.Port != 14889 {
+			t.Fatalf("unexpected settings: %+v", ui)
+		}
+	})
+
+	t.Run("should report settings that didn't stick", func(t *testing.T) {
+		// an admin interface that accepts everything, and saves nothing
+		ui := &sim.AdminUI{WorkMode: "TCP-Client", Port: 8899}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.Method = http.MethodGet
+			ui.ServeHTTP(w, req)
+		}))
+		defer server.Close()
+
+		client := &Client{URL: server.URL}
+
+		_, err := client.Provision(context.Background(), Settings{WorkMode: WorkModeTCPServer})
+		if !errors.Is(err, ErrVerify) {
+			t.Fatalf("expected verification failure but was: %v", err)
+		}
+	})
+
+	t.Run("should refuse to submit forms missing expected fields", func(t *testing.T) {
+		ui, client := standIn()
+
+		layout := DefaultLayout
+		layout.FieldSTAPassword = "psk"
+		client.Layout = &layout
+
+		_, err := client.Provision(context.Background(), Settings{SSID: "Roof", Password: "hunter2", Port: 14889})
+		if !errors.Is(err, ErrFieldMissing) {
+			t.Fatalf("expected missing field failure but was: %v", err)
+		}
+
+		// the port is on a page with the expected fields, but nothing is submitted
+		if ui.SSID != "Home" || ui.Port != 8899 {
+			t.Fatalf("unexpected settings: %+v", ui)
+		}
+
+		if err := client.SetWiFi(context.Background(), "Roof", "hunter2"); !errors.Is(err, ErrFieldMissing) {
+			t.Fatalf("expected missing field failure but was: %v", err)
+		}
+	})
+
+	t.Run("should read the configuration with a custom layout", func(t *testing.T) {
+		pages := map[string]string{
+			"/status.cgi":  `<input name="ip" value="192.0.2.54"><input name="ver" value="V2.0">`,
+			"/wlan.cgi":    `<form><input name="ssid" value="Home"><input name="psk" value=""></form>`,
+			"/socket.cgi":  `<form><select name="mode"><option selected>TCP-Server</option></select></form>`,
+			"/advance.cgi": `<form><input name="port" value="14889"></form>`,
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			page, ok := pages[req.URL.Path]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+
+			w.Write([]byte(page))
+		}))
+		defer server.Close()
+
+		client := &Client{URL: server.URL, Layout: &Layout{
+			PageSystem:       "/status.cgi",
+			PageSTA:          "/wlan.cgi",
+			PageNetwork:      "/socket.cgi",
+			PageOther:        "/advance.cgi",
+			FieldSTAIP:       "ip",
+			FieldFirmware:    "ver",
+			FieldSTASSID:     "ssid",
+			FieldSTAPassword: "psk",
+			FieldWorkMode:    "mode",
+			FieldPort:        "port",
+		}}
+
+		cfg, err := client.ReadConfig(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if *cfg != (Config{SSID: "Home", IPAddress: "192.0.2.54", WorkMode: WorkModeTCPServer, Port: 14889}) {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+
+	t.Run("should report wrong credentials", func(t *testing.T) {
+		_, client := standIn()
+		client.Password = "wrong"
+
+		if _, err := client.ReadConfig(context.Background()); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("expected unauthorized but was: %v", err)
+		}
+	})
+}
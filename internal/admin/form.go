@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// opening and closing tags of the elements that make up a form
+	tagPattern = regexp.MustCompile(`(?is)<(/?)(form|input|select|option|textarea)\b([^>]*)>`)
+
+	// attributes of a tag, quoted or not, with or without a value
+	attrPattern = regexp.MustCompile(`(?s)([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+)
+
+// A form of an admin page, with the values a browser would submit.
+type form struct {
+	// Path the form is submitted to. Empty if the form has no action, in which case it's submitted to its page.
+	action string
+
+	fields url.Values
+}
+
+// Parse the first form of an admin page. Fields outside of a form (e.g. read-only status fields) are included too, so
+// that pages without a form can be read the same way.
+//
+// The admin pages are served by the Wi-Fi module of the inverter and are simple enough to be parsed without a full
+// HTML parser: the values of inputs, checked checkboxes and radio buttons, selected options and text areas are
+// extracted, like a browser would submit them.
+func parseForm(page string) *form {
+	f := &form{fields: url.Values{}}
+
+	var (
+		selectName string
+		selected   bool
+		first      string
+		forms      int
+	)
+
+	matches := tagPattern.FindAllStringSubmatchIndex(page, -1)
+
+	for i, m := range matches {
+		closing := page[m[2]:m[3]] == "/"
+		tag := strings.ToLower(page[m[4]:m[5]])
+		attrs := parseAttrs(page[m[6]:m[7]])
+
+		switch {
+		case tag == "form" && !closing:
+			forms++
+			if forms == 1 {
+				f.action = attrs.Get("action")
+			}
+		case forms > 1:
+			// only the first form is of interest
+		case tag == "input" && !closing:
+			name := attrs.Get("name")
+			if name == "" {
+				continue
+			}
+
+			switch strings.ToLower(attrs.Get("type")) {
+			case "submit", "button", "reset", "image", "file":
+				continue
+			case "checkbox", "radio":
+				if !attrs.Has("checked") {
+					continue
+				}
+				if !attrs.Has("value") {
+					attrs.Set("value", "on")
+				}
+			}
+
+			f.fields.Add(name, attrs.Get("value"))
+		case tag == "select" && !closing:
+			selectName, selected, first = attrs.Get("name"), false, ""
+		case tag == "select" && closing:
+			// like browsers, select the first option if none is selected
+			if selectName != "" && !selected && first != "" {
+				f.fields.Add(selectName, first)
+			}
+
+			selectName = ""
+		case tag == "option" && !closing && selectName != "":
+			value := attrs.Get("value")
+			if !attrs.Has("value") && i+1 < len(matches) {
+				// the option has no value, so its value is its text
+				value = strings.TrimSpace(html.UnescapeString(page[m[1]:matches[i+1][0]]))
+			}
+
+			if first == "" {
+				first = value
+			}
+
+			if attrs.Has("selected") && !selected {
+				f.fields.Add(selectName, value)
+				selected = true
+			}
+		case tag == "textarea" && !closing:
+			name := attrs.Get("name")
+			if name == "" || i+1 >= len(matches) {
+				continue
+			}
+
+			f.fields.Add(name, html.UnescapeString(page[m[1]:matches[i+1][0]]))
+		}
+	}
+
+	return f
+}
+
+// Parse the attributes of a tag. Attribute names are lower-cased and values unescaped.
+func parseAttrs(s string) url.Values {
+	attrs := url.Values{}
+
+	for _, m := range attrPattern.FindAllStringSubmatch(s, -1) {
+		name := strings.ToLower(m[1])
+		if attrs.Has(name) {
+			continue
+		}
+
+		attrs.Set(name, html.UnescapeString(m[2]+m[3]+m[4]))
+	}
+
+	return attrs
+}
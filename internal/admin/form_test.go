@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"testing"
+)
+
+func TestParseForm(t *testing.T) {
+	t.Run("should extract the values a browser would submit", func(t *testing.T) {
+		page := `<html><body>
+<form method="POST" action='/save.html'>
+  <INPUT type="text" name="sta_ssid" value="My &amp; Wi-Fi">
+  <input type=password name=sta_key value="secret">
+  <input type="checkbox" name="dhcp" checked>
+  <input type="checkbox" name="dns" value="1">
+  <input type="radio" name="band" value="2g" checked><input type="radio" name="band" value="5g">
+  <select name="work_mode">
+    <option value="TCP-Server">TCP-Server</option>
+    <option value="TCP-Client" selected="selected">TCP-Client</option>
+  </select>
+  <select name="protocol"><option>TCP</option><option>UDP</option></select>
+  <textarea name="note">hello</textarea>
+  <input type="submit" name="save" value="Save">
+</form>
+<form action="/other.html"><input name="port_id" value="8899"></form>
+</body></html>`
+
+		f := parseForm(page)
+
+		expected := map[string]string{
+			"sta_ssid":  "My & Wi-Fi",
+			"sta_key":   "secret",
+			"dhcp":      "on",
+			"band":      "2g",
+			"work_mode": "TCP-Client",
+			"protocol":  "TCP",
+			"note":      "hello",
+		}
+
+		switch {
+		case f.action != "/save.html":
+			t.Fatalf("unexpected action: %s", f.action)
+		case len(f.fields) != len(expected):
+			t.Fatalf("unexpected fields: %v", f.fields)
+		}
+
+		for name, value := range expected {
+			if f.fields.Get(name) != value {
+				t.Fatalf("unexpected value of %s: %q", name, f.fields.Get(name))
+			}
+		}
+	})
+
+	t.Run("should extract fields outside of forms", func(t *testing.T) {
+		f := parseForm(`<table><tr><td>IP</td><td><input name="sta_ip" value="192.0.2.1" readonly></td></tr></table>`)
+
+		if f.action != "" || f.fields.Get("sta_ip") != "192.0.2.1" {
+			t.Fatalf("unexpected form: %+v", f)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Pages written after DefaultLayout, to exercise the status parser. No captures of a real Wi-Fi module exist, so these
+// tests can't tell whether DefaultLayout matches one.
+const (
+	systemPage = `<HTML><BODY><TABLE>
+<TR><TD>Firmware Version</TD><TD><INPUT type="text" name="fw_version" value="V1.0.22-Build 20190314" readonly></TD></TR>
+<TR><TD>SSID</TD><TD><INPUT type="text" name="sta_ssid" value="Home &amp; Garden" readonly></TD></TR>
+<TR><TD>IP Address</TD><TD><INPUT type="text" name="sta_ip" value="192.168.2.54" readonly></TD></TR>
+<TR><TD>Signal Strength</TD><TD><INPUT type="text" name="sta_rssi" value="-71 dBm" readonly></TD></TR>
+</TABLE></BODY></HTML>`
+
+	disconnectedSystemPage = `<HTML><BODY><TABLE>
+<TR><TD>Firmware Version</TD><TD><INPUT type="text" name="fw_version" value="V1.0.22-Build 20190314" readonly></TD></TR>
+<TR><TD>SSID</TD><TD><INPUT type="text" name="sta_ssid" value="" readonly></TD></TR>
+<TR><TD>IP Address</TD><TD><INPUT type="text" name="sta_ip" value="" readonly></TD></TR>
+<TR><TD>Signal Strength</TD><TD><INPUT type="text" name="sta_rssi" value="" readonly></TD></TR>
+</TABLE></BODY></HTML>`
+
+	networkPage = `<HTML><BODY><FORM method="POST" action="network.html">
+<SELECT name="work_mode"><OPTION value="TCP-Server" selected>TCP-Server</OPTION><OPTION value="UDP">UDP</OPTION></SELECT>
+<INPUT type="submit" name="save" value="Save">
+</FORM></BODY></HTML>`
+)
+
+func TestParseStatus(t *testing.T) {
+	// serve the pages in place of the admin interface
+	serve := func(t *testing.T) string {
+		t.Helper()
+
+		pages := map[string]string{
+			DefaultLayout.PageSystem:  systemPage,
+			DefaultLayout.PageNetwork: networkPage,
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			page, ok := pages[req.URL.Path]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+
+			w.Write([]byte(page))
+		}))
+		t.Cleanup(server.Close)
+
+		return server.URL
+	}
+
+	t.Run("should parse the status of a connected inverter", func(t *testing.T) {
+		status, missing, err := DefaultLayout.parseStatus(systemPage)
+		if err != nil || len(missing) != 0 {
+			t.Fatalf("unexpected error: %v (missing %v)", err, missing)
+		}
+
+		expected := Status{SSID: "Home & Garden", IPAddress: "192.168.2.54", RSSI: -71, Firmware: "V1.0.22-Build 20190314"}
+		if *status != expected {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+	})
+
+	t.Run("should parse the status of a disconnected inverter", func(t *testing.T) {
+		status, missing, err := DefaultLayout.parseStatus(disconnectedSystemPage)
+		if err != nil || len(missing) != 0 {
+			t.Fatalf("unexpected error: %v (missing %v)", err, missing)
+		}
+
+		if status.IPAddress != "" || status.RSSI != 0 || status.Firmware != "V1.0.22-Build 20190314" {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+	})
+
+	t.Run("should reject other pages", func(t *testing.T) {
+		if _, _, err := DefaultLayout.parseStatus(networkPage); !errors.Is(err, ErrPage) {
+			t.Fatalf("expected unexpected page but was: %v", err)
+		}
+	})
+
+	t.Run("should read the status and work mode", func(t *testing.T) {
+		client := &Client{URL: serve(t)}
+
+		status, err := client.ReadStatus(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if status.RSSI != -71 || status.WorkMode != WorkModeTCPServer {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+	})
+
+	t.Run("should report missing fields, and keep the fields found", func(t *testing.T) {
+		layout := DefaultLayout
+		layout.FieldSTARSSI = "rssi"
+		layout.FieldWorkMode = "mode"
+
+		client := &Client{URL: serve(t), Layout: &layout}
+
+		status, err := client.ReadStatus(context.Background())
+		if !errors.Is(err, ErrFieldMissing) {
+			t.Fatalf("expected missing fields but was: %v", err)
+		}
+
+		if status == nil || status.IPAddress != "192.168.2.54" || status.RSSI != 0 || status.WorkMode != "" {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+	})
+}
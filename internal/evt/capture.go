@@ -0,0 +1,152 @@
+package evt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/netip"
+
+	"github.com/brandon1024/OpenEVT/internal/pcap"
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// CaptureEvent is an event decoded from a packet capture.
+//
+// The timestamp of the event is the capture time of the packet which completed the frame.
+type CaptureEvent struct {
+	*Event
+
+	Src netip.AddrPort
+	Dst netip.AddrPort
+}
+
+// DecodeCapture decodes the frames exchanged with inverters in a packet capture, calling fn for each frame in capture
+// order. Only traffic to or from the given port is decoded (all traffic if zero). Status frames are decoded with the
+// given profile, or the profile detected from each frame if nil.
+//
+// TCP streams are reassembled, so frames split across segments, retransmitted or captured out of order are decoded
+// once. Polls and acks sent in their ASCII-hex form are decoded like binary frames, with Raw holding the binary form.
+//
+// Errors returned by fn abort decoding and are returned as-is.
+func DecodeCapture(r *pcap.Reader, port uint16, profile *types.Profile, fn func(*CaptureEvent) error) error {
+	var (
+		streams = map[[2]netip.AddrPort]*captureStream{}
+		err     error
+	)
+
+	assembler := pcap.Assembler{OnData: func(p *pcap.Packet) {
+		if err != nil {
+			return
+		}
+
+		flow := [2]netip.AddrPort{p.Src, p.Dst}
+
+		st, ok := streams[flow]
+		if !ok {
+			st = newCaptureStream()
+			streams[flow] = st
+		}
+
+		for _, frame := range st.write(p.Payload) {
+			ev, decodeErr := NewEvent(frame, profile)
+			if decodeErr != nil {
+				continue
+			}
+
+			ev.Timestamp = p.Timestamp
+
+			if err = fn(&CaptureEvent{Event: ev, Src: p.Src, Dst: p.Dst}); err != nil {
+				return
+			}
+		}
+	}}
+
+	for err == nil {
+		p, readErr := r.ReadPacket()
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+
+		if port == 0 || p.Src.Port() == port || p.Dst.Port() == port {
+			assembler.Add(p)
+		}
+	}
+
+	if err == nil {
+		assembler.Flush()
+	}
+
+	return err
+}
+
+// One direction of a captured connection, split into frames.
+type captureStream struct {
+	buf    bytes.Buffer
+	reader *FrameReader
+
+	// whether the stream carries ASCII-hex messages, decided on the first chunk
+	decided bool
+	text    bool
+
+	// odd hex digit left over from the previous chunk
+	nibble []byte
+}
+
+func newCaptureStream() *captureStream {
+	st := &captureStream{}
+	st.reader = NewFrameReader(&st.buf)
+
+	return st
+}
+
+// Append a chunk of stream data, returning the frames completed by it.
+func (st *captureStream) write(data []byte) [][]byte {
+	if !st.decided && len(data) > 0 {
+		st.decided = true
+		st.text = data[0] != types.FrameStart && isHexDigit(data[0])
+	}
+
+	if st.text {
+		data = st.decodeHex(data)
+	}
+
+	st.buf.Write(data)
+
+	var frames [][]byte
+
+	for {
+		frame, err := st.reader.ReadFrame()
+		if err != nil {
+			return frames
+		}
+
+		frames = append(frames, frame)
+	}
+}
+
+// Decode ASCII-hex data, ignoring anything that isn't a hex digit (e.g. line breaks).
+func (st *captureStream) decodeHex(data []byte) []byte {
+	digits := st.nibble
+
+	for _, c := range data {
+		if isHexDigit(c) {
+			digits = append(digits, c)
+		}
+	}
+
+	n := len(digits) / 2 * 2
+	st.nibble = bytes.Clone(digits[n:])
+
+	decoded := make([]byte, n/2)
+	hex.Decode(decoded, digits[:n])
+
+	return decoded
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
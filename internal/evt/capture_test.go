@@ -0,0 +1,80 @@
+package evt
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/pcap"
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+func TestDecodeCapture(t *testing.T) {
+	t.Run("should decode frames of reassembled streams", func(t *testing.T) {
+		var (
+			inverter = netip.MustParseAddrPort("192.0.2.54:14889")
+			client   = netip.MustParseAddrPort("192.0.2.1:50000")
+			other    = netip.MustParseAddrPort("192.0.2.1:443")
+			start    = time.Unix(1750000000, 0)
+		)
+
+		poll, _ := types.NewPollMessage("31583078")
+		ack, _ := types.NewAckMessage("31583078")
+
+		packets := []pcap.Packet{
+			{Src: client, Dst: inverter, Seq: 1, Payload: poll[:10]},
+			{Src: client, Dst: inverter, Seq: 11, Payload: poll[10:]},
+			{Src: inverter, Dst: client, Seq: 1, Payload: statusFrame[:40]},
+			{Src: client, Dst: other, Seq: 1, Payload: statusFrame},
+			{Src: inverter, Dst: client, Seq: 1, Payload: statusFrame[:40]},
+			{Src: inverter, Dst: client, Seq: 41, Payload: statusFrame[40:]},
+			{Src: client, Dst: inverter, Seq: 33, Payload: ack},
+		}
+
+		var buf bytes.Buffer
+
+		w, err := pcap.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for i, p := range packets {
+			p.Protocol = pcap.ProtocolTCP
+			p.Timestamp = start.Add(time.Duration(i) * time.Second)
+
+			if err := w.WritePacket(&p); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		r, err := pcap.NewReader(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var events []*CaptureEvent
+		err = DecodeCapture(r, 14889, nil, func(ev *CaptureEvent) error {
+			events = append(events, ev)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(events) != 3 {
+			t.Fatalf("unexpected number of events: %d", len(events))
+		}
+
+		switch {
+		case events[0].Type != FramePoll || events[0].Src != client || !events[0].Timestamp.Equal(start.Add(time.Second)):
+			t.Fatalf("unexpected poll event: %s %v %v", events[0].Type, events[0].Src, events[0].Timestamp)
+		case events[1].Type != FramePollResponse || events[1].Status.InverterId != "30587612" || !bytes.Equal(events[1].Raw, statusFrame):
+			t.Fatalf("unexpected status event: %s %x", events[1].Type, events[1].Raw)
+		case !events[1].Timestamp.Equal(start.Add(5 * time.Second)):
+			t.Fatalf("unexpected status timestamp: %v", events[1].Timestamp)
+		case events[2].Type != FrameAck || events[2].Dst != inverter:
+			t.Fatalf("unexpected ack event: %s %v", events[2].Type, events[2].Dst)
+		}
+	})
+}
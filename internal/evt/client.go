@@ -1,21 +1,63 @@
 package evt
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/brandon1024/OpenEVT/internal/types"
 )
 
+// Supported transports.
+const (
+	TransportTCP = "tcp"
+	TransportUDP = "udp"
+)
+
+const (
+	// Read timeout used in UDP mode if the client has no 'ReadTimeout', so that lost datagrams are recovered from by
+	// polling again.
+	DefaultUDPReadTimeout = 30 * time.Second
+
+	// Identical frames received within this window in UDP mode are treated as duplicate datagrams and dropped.
+	DuplicateWindow = 2 * time.Second
+)
+
 // Envertec EVT800 microinverter client.
 type Client struct {
-	Address     string
-	InverterID  string
+	Address string
+
+	// Serial number of the inverter, used to poll and acknowledge it. If empty, the serial number can be learned from
+	// the first status frame pushed by the inverter with 'Identify()'. Status frames from other inverters are rejected
+	// with ErrSerialMismatch.
+	//
+	// Set it before the client is shared with other goroutines (e.g. a [Proxy]); from then on, use 'Serial()' and
+	// 'SetSerial()'.
+	InverterID string
+
 	ReadTimeout time.Duration
 
-	conn *net.TCPConn
+	// Transport used to talk to the inverter, TransportTCP (default) or TransportUDP.
+	Transport string
+
+	// Profile used to decode status frames. If nil, the profile is detected from each frame.
+	Profile *types.Profile
+
+	// If set, all traffic exchanged with the inverter is recorded.
+	Recorder Recorder
+
+	conn   net.Conn
+	reader *FrameReader
+
+	// guards conn and InverterID, so that the client can be written to and closed from several goroutines
+	mux sync.Mutex
+
+	// last frame received, for duplicate detection in UDP mode
+	lastFrame     []byte
+	lastFrameTime time.Time
 }
 
 var (
@@ -24,6 +66,9 @@ var (
 	ErrAck            = errors.New("failed to ack inverter")
 	ErrReadFrame      = errors.New("failed to read frame from inverter")
 	ErrFrameDiscarded = errors.New("frame discarded")
+	ErrNotConnected   = errors.New("not connected to inverter")
+	ErrIdentify       = errors.New("failed to identify inverter")
+	ErrSerialMismatch = errors.New("inverter serial number mismatch")
 )
 
 // Setup a connection to the inverter.
@@ -33,32 +78,84 @@ func (c *Client) Connect() error {
 	if c.Address == "" {
 		return errors.Join(ErrConnect, fmt.Errorf("address is empty"))
 	}
-	if c.InverterID == "" {
-		return errors.Join(ErrConnect, fmt.Errorf("inverter ID is empty"))
-	}
+	var (
+		conn net.Conn
+		err  error
+	)
 
-	addr, err := net.ResolveTCPAddr("tcp", c.Address)
+	switch c.Transport {
+	case "", TransportTCP:
+		conn, err = dialTCP(c.Address)
+	case TransportUDP:
+		conn, err = dialUDP(c.Address)
+	default:
+		err = fmt.Errorf("unsupported transport: %s", c.Transport)
+	}
 	if err != nil {
 		return errors.Join(ErrConnect, err)
 	}
 
-	c.conn, err = net.DialTCP("tcp", nil, addr)
+	if c.Recorder != nil {
+		conn = &recordingConn{Conn: conn, recorder: c.Recorder}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.conn = conn
+	c.reader = NewFrameReader(conn)
+
+	return nil
+}
+
+func dialTCP(address string) (net.Conn, error) {
+	addr, err := net.ResolveTCPAddr("tcp", address)
 	if err != nil {
-		return errors.Join(ErrConnect, err)
+		return nil, err
 	}
 
-	err = c.conn.SetKeepAlive(true)
+	conn, err := net.DialTCP("tcp", nil, addr)
 	if err != nil {
-		c.conn.Close()
-		return errors.Join(ErrConnect, err)
+		return nil, err
 	}
 
-	return nil
+	err = conn.SetKeepAlive(true)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func dialUDP(address string) (net.Conn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.DialUDP("udp", nil, addr)
+}
+
+// Serial returns the serial number of the inverter ('InverterID'). Safe for concurrent use.
+func (c *Client) Serial() string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.InverterID
+}
+
+// SetSerial replaces the serial number of the inverter ('InverterID'). Safe for concurrent use.
+func (c *Client) SetSerial(sn string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.InverterID = sn
 }
 
 // Poll the inverter for it's state.
 func (c *Client) Poll() error {
-	poll, err := types.NewPollMessage(c.InverterID)
+	poll, err := types.NewPollMessage(c.Serial())
 	if err != nil {
 		return errors.Join(ErrPoll, err)
 	}
@@ -73,7 +170,7 @@ func (c *Client) Poll() error {
 
 // Acknowledge a message from the inverter.
 func (c *Client) Acknowledge() error {
-	ack, err := types.NewAckMessage(c.InverterID)
+	ack, err := types.NewAckMessage(c.Serial())
 	if err != nil {
 		return errors.Join(ErrAck, err)
 	}
@@ -88,27 +185,26 @@ func (c *Client) Acknowledge() error {
 
 // Read the next inverter status frame. Upon receipt, the message is acknowledged with 'Acknowledge()'.
 //
+// Frames are reassembled from the TCP stream, so frames split across several TCP segments or coalesced into one are
+// handled transparently. Each status frame is acknowledged exactly once.
+//
 // If a 'ReadTimeout' is configured on the client, ReadFrame will return an [os.ErrDeadlineExceeded] if the inverter
 // doesn't send a message after the deadline.
 //
-// May return ErrFrameDiscarded if the message from the inverter is unrecognized, which can be safely ignored.
+// May return ErrFrameDiscarded if the message from the inverter is unrecognized, which can be safely ignored. To handle
+// other types of frames, see 'Dispatch()'.
 func (c *Client) ReadFrame(msg *types.InverterStatus) error {
-	if c.ReadTimeout != time.Duration(0) {
-		err := c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
-		if err != nil {
-			return errors.Join(ErrReadFrame, err)
-		}
+	ev, err := c.ReadEvent()
+	if err != nil {
+		return err
 	}
 
-	frame := make([]byte, 512)
-	w, err := c.Read(frame)
-	if err != nil {
-		return errors.Join(ErrReadFrame, err)
+	if ev.Status == nil {
+		return errors.Join(ErrReadFrame, ErrFrameDiscarded, fmt.Errorf("unexpected %s frame", ev.Type))
 	}
 
-	err = msg.UnmarshalBinary(frame[0:w])
-	if err != nil {
-		return errors.Join(ErrReadFrame, ErrFrameDiscarded, err)
+	if err := c.verify(ev); err != nil {
+		return err
 	}
 
 	err = c.Acknowledge()
@@ -116,21 +212,172 @@ func (c *Client) ReadFrame(msg *types.InverterStatus) error {
 		return errors.Join(ErrReadFrame, err)
 	}
 
+	*msg = *ev.Status
+
 	return nil
 }
 
-// Read raw data from the underlying TCP connection.
+// Read the next frame from the inverter, acknowledge it if the router says so, and dispatch it to the router.
+//
+// Like 'ReadFrame()', returns an [os.ErrDeadlineExceeded] if a 'ReadTimeout' is configured and the inverter doesn't
+// send a message before the deadline. Errors returned by handlers are returned as-is.
+func (c *Client) Dispatch(router *Router) error {
+	ev, err := c.ReadEvent()
+	if err != nil {
+		return err
+	}
+
+	return c.DispatchEvent(router, ev)
+}
+
+// Acknowledge an event already read from the inverter if the router says so, and dispatch it to the router. Status
+// frames from an inverter other than the one the client is configured for are neither acknowledged nor dispatched, and
+// ErrSerialMismatch is returned instead.
+func (c *Client) DispatchEvent(router *Router, ev *Event) error {
+	if err := c.verify(ev); err != nil {
+		return err
+	}
+
+	if router.Acknowledges(ev.Type) {
+		err := c.Acknowledge()
+		if err != nil {
+			return errors.Join(ErrReadFrame, err)
+		}
+	}
+
+	return router.HandleEvent(ev)
+}
+
+// Read and decode the next frame from the inverter, without acknowledging it.
+//
+// In UDP mode, duplicate datagrams are dropped, and reads time out after [DefaultUDPReadTimeout] if no 'ReadTimeout' is
+// configured, so that a lost poll or status datagram can be recovered from by polling again.
+func (c *Client) ReadEvent() (*Event, error) {
+	if timeout := c.readTimeout(); timeout != time.Duration(0) {
+		err := c.conn.SetReadDeadline(time.Now().Add(timeout))
+		if err != nil {
+			return nil, errors.Join(ErrReadFrame, err)
+		}
+	}
+
+	for {
+		frame, err := c.reader.ReadFrame()
+		if err != nil {
+			return nil, errors.Join(ErrReadFrame, err)
+		}
+
+		if c.isDuplicate(frame) {
+			continue
+		}
+
+		ev, err := NewEvent(frame, c.Profile)
+		if err != nil {
+			return nil, errors.Join(ErrReadFrame, ErrFrameDiscarded, err)
+		}
+
+		return ev, nil
+	}
+}
+
+// Read frames until a status frame is received, and learn the inverter serial number from it. Frames received before
+// the inverter is identified can't be acknowledged and are dropped.
+//
+// Inverters only answer polls carrying their own serial number, so this waits for the inverter to push a status frame
+// on its own, which can take a while. The returned event hasn't been acknowledged yet, see 'DispatchEvent()'. If the
+// client already has an 'InverterID', it's replaced by the one reported by the inverter.
+func (c *Client) Identify(timeout time.Duration) (*Event, error) {
+	err := c.conn.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return nil, errors.Join(ErrIdentify, err)
+	}
+
+	for {
+		frame, err := c.reader.ReadFrame()
+		if err != nil {
+			return nil, errors.Join(ErrIdentify, err)
+		}
+
+		ev, err := NewEvent(frame, c.Profile)
+		if err != nil || ev.Status == nil {
+			continue
+		}
+
+		if ev.Status.InverterId == "" {
+			return nil, errors.Join(ErrIdentify, fmt.Errorf("status frame without inverter serial number"))
+		}
+
+		c.SetSerial(ev.Status.InverterId)
+
+		// from here on, the read deadline is managed by 'ReadEvent()'
+		if err := c.conn.SetReadDeadline(time.Time{}); err != nil {
+			return nil, errors.Join(ErrIdentify, err)
+		}
+
+		return ev, nil
+	}
+}
+
+// Check that a status frame was sent by the inverter the client is configured for.
+func (c *Client) verify(ev *Event) error {
+	serial := c.Serial()
+
+	if ev.Status == nil || serial == "" || ev.Status.InverterId == serial {
+		return nil
+	}
+
+	return errors.Join(ErrSerialMismatch, fmt.Errorf("expected serial number %s, but inverter reports %s", serial, ev.Status.InverterId))
+}
+
+func (c *Client) readTimeout() time.Duration {
+	if c.ReadTimeout == time.Duration(0) && c.Transport == TransportUDP {
+		return DefaultUDPReadTimeout
+	}
+
+	return c.ReadTimeout
+}
+
+// Check if the frame is a duplicate datagram of the previous frame (UDP mode only).
+func (c *Client) isDuplicate(frame []byte) bool {
+	if c.Transport != TransportUDP {
+		return false
+	}
+
+	now := time.Now()
+	duplicate := bytes.Equal(frame, c.lastFrame) && now.Sub(c.lastFrameTime) < DuplicateWindow
+
+	c.lastFrame = frame
+	c.lastFrameTime = now
+
+	return duplicate
+}
+
+// Read raw data from the underlying connection. Data read this way bypasses the frame reassembly of 'ReadFrame()',
+// so the two shouldn't be mixed.
 func (c *Client) Read(p []byte) (int, error) {
 	return c.conn.Read(p)
 }
 
-// Write raw data to the underlying TCP connection.
+// Write raw data to the underlying connection. Safe for concurrent use.
 func (c *Client) Write(p []byte) (int, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.conn == nil {
+		return 0, ErrNotConnected
+	}
+
 	return c.conn.Write(p)
 }
 
-// Close the underlying TCP connection.
+// Close the underlying connection. Safe for concurrent use.
 func (c *Client) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+
 	return c.conn.Close()
 }
 
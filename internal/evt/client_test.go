@@ -0,0 +1,344 @@
+package evt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/sim"
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// Start a fake inverter which writes the given chunks to the first client that connects, and returns everything the
+// client sends until the connection is closed.
+func fakeInverter(t *testing.T, chunks ...[]byte) (string, <-chan []byte) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan []byte, 1)
+
+	go func() {
+		defer close(received)
+
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		for _, chunk := range chunks {
+			conn.Write(chunk)
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestClientReadFrame(t *testing.T) {
+	t.Run("should acknowledge each reassembled frame exactly once", func(t *testing.T) {
+		addr, received := fakeInverter(t,
+			concat(statusFrame, statusFrame[:10]),
+			statusFrame[10:50],
+			concat(statusFrame[50:], []byte{0xde, 0xad}, statusFrame),
+		)
+
+		client := Client{Address: addr, InverterID: "30587612"}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for range 3 {
+			var msg types.InverterStatus
+			if err := client.ReadFrame(&msg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msg.InverterId != "30587612" {
+				t.Fatalf("unexpected inverter serial: %s", msg.InverterId)
+			}
+		}
+
+		client.Close()
+
+		ack, _ := types.NewAckMessage("30587612")
+		if acks := bytes.Count(<-received, ack); acks != 3 {
+			t.Fatalf("unexpected number of acks: %d", acks)
+		}
+	})
+
+	t.Run("should discard frames that aren't status frames", func(t *testing.T) {
+		addr, received := fakeInverter(t, pollFrame)
+
+		client := Client{Address: addr, InverterID: "30587612"}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var msg types.InverterStatus
+		if err := client.ReadFrame(&msg); !errors.Is(err, ErrFrameDiscarded) {
+			t.Fatalf("expected frame discarded but was: %v", err)
+		}
+
+		client.Close()
+
+		if data := <-received; len(data) != 0 {
+			t.Fatalf("unexpected data sent to inverter: %s", data)
+		}
+	})
+}
+
+func TestClientIdentify(t *testing.T) {
+	t.Run("should learn the serial number from a pushed status frame", func(t *testing.T) {
+		addr, received := fakeInverter(t, concat(pollFrame, statusFrame))
+
+		client := Client{Address: addr}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ev, err := client.Identify(time.Second)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if client.InverterID != "30587612" || ev.Status.InverterId != "30587612" {
+			t.Fatalf("unexpected inverter serial: %s", client.InverterID)
+		}
+
+		if err := client.DispatchEvent(NewRouter(), ev); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		client.Close()
+
+		ack, _ := types.NewAckMessage("30587612")
+		if data := <-received; !bytes.Equal(data, ack) {
+			t.Fatalf("unexpected data sent to inverter: %s", data)
+		}
+	})
+
+	t.Run("should learn the serial number while other goroutines poll", func(t *testing.T) {
+		addr, _ := fakeInverter(t, statusFrame)
+
+		client := Client{Address: addr}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		defer client.Close()
+
+		// e.g. polls forwarded by a proxy on behalf of downstream clients
+		polling, done := make(chan struct{}), make(chan struct{})
+		defer close(done)
+
+		go func() {
+			client.Poll()
+			close(polling)
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					client.Poll()
+				}
+			}
+		}()
+
+		<-polling
+
+		if _, err := client.Identify(time.Second); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if serial := client.Serial(); serial != "30587612" {
+			t.Fatalf("unexpected inverter serial: %s", serial)
+		}
+	})
+
+	t.Run("should reject status frames from another inverter", func(t *testing.T) {
+		addr, received := fakeInverter(t, statusFrame)
+
+		client := Client{Address: addr, InverterID: "31583078"}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		router := NewRouter()
+		router.HandleFunc(FramePollResponse, func(ev *Event) error {
+			t.Fatalf("unexpected event dispatched: %v", ev.Type)
+			return nil
+		})
+
+		if err := client.Dispatch(router); !errors.Is(err, ErrSerialMismatch) {
+			t.Fatalf("expected serial mismatch but was: %v", err)
+		}
+
+		client.Close()
+
+		if data := <-received; len(data) != 0 {
+			t.Fatalf("unexpected data sent to inverter: %s", data)
+		}
+	})
+}
+
+// Start a fake inverter in UDP mode, which replies to each poll with the next group of datagrams.
+func fakeUDPInverter(t *testing.T, replies ...[][]byte) (string, <-chan []byte) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	received := make(chan []byte, 64)
+
+	go func() {
+		defer close(received)
+
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			received <- bytes.Clone(buf[:n])
+
+			poll, _ := types.NewPollMessage("30587612")
+			if !bytes.Equal(buf[:n], poll) || len(replies) == 0 {
+				continue
+			}
+
+			for _, datagram := range replies[0] {
+				conn.WriteTo(datagram, addr)
+			}
+
+			replies = replies[1:]
+		}
+	}()
+
+	return conn.LocalAddr().String(), received
+}
+
+func TestClientSimulated(t *testing.T) {
+	t.Run("should keep the connection to a faulty inverter by acknowledging status frames", func(t *testing.T) {
+		server := sim.NewServer(&sim.Inverter{
+			Serial:     "31583078",
+			Interval:   20 * time.Millisecond,
+			AckTimeout: 100 * time.Millisecond,
+			NoStandby:  true,
+			Faults:     sim.Faults{Garbage: 0.5, Split: 0.5, Seed: 1},
+		})
+		defer server.Close()
+
+		client := Client{Address: server.Addr, InverterID: "31583078"}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		defer client.Close()
+
+		if err := client.Poll(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		statuses := 0
+
+		router := NewRouter()
+		router.HandleFunc(FrameStatus, func(ev *Event) error {
+			statuses++
+			return nil
+		})
+		router.HandleFunc(FramePollResponse, func(ev *Event) error {
+			statuses++
+			return nil
+		})
+
+		for statuses < 10 {
+			if err := client.Dispatch(router); err != nil && !errors.Is(err, ErrFrameDiscarded) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if stats := server.Inverter.Stats(); stats.Hangups != 0 || stats.Polls != 1 {
+			t.Fatalf("unexpected simulator stats: %+v", stats)
+		}
+	})
+}
+
+func TestClientUDP(t *testing.T) {
+	t.Run("should drop duplicate datagrams and recover from lost datagrams", func(t *testing.T) {
+		other := bytes.Clone(statusFrame)
+		other[27]++
+		other[84]++
+
+		addr, received := fakeUDPInverter(t,
+			nil, // first poll is lost
+			[][]byte{statusFrame, statusFrame, other},
+		)
+
+		client := Client{Address: addr, InverterID: "30587612", Transport: TransportUDP, ReadTimeout: 100 * time.Millisecond}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		defer client.Close()
+
+		if err := client.Poll(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var msg types.InverterStatus
+		if err := client.ReadFrame(&msg); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded but was: %v", err)
+		}
+
+		if err := client.Poll(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, expected := range []float64{34.511719, 34.513672} {
+			if err := client.ReadFrame(&msg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(msg.Modules[0].InputVoltageDC-expected) > 0.0001 {
+				t.Fatalf("unexpected module1 input voltage dc: %f", msg.Modules[0].InputVoltageDC)
+			}
+		}
+
+		if err := client.ReadFrame(&msg); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded but was: %v", err)
+		}
+
+		ack, _ := types.NewAckMessage("30587612")
+
+		acks := 0
+		for len(received) > 0 {
+			if bytes.Equal(<-received, ack) {
+				acks++
+			}
+		}
+
+		if acks != 2 {
+			t.Fatalf("unexpected number of acks: %d", acks)
+		}
+	})
+}
@@ -0,0 +1,496 @@
+package evt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+const (
+	// Time allowed to connect to the inverter, if the doctor has no 'DialTimeout'.
+	DefaultDiagnoseDialTimeout = 5 * time.Second
+
+	// Time to wait for the inverter to answer a poll, if the doctor has no 'ReadTimeout'. Without a serial number, the
+	// inverter can't be polled and [DefaultIdentifyTimeout] is used instead.
+	DefaultDiagnoseReadTimeout = 30 * time.Second
+
+	// Time the session is kept open to check that acknowledgements keep it alive, if the doctor has no
+	// 'SessionDuration'.
+	DefaultDiagnoseSessionDuration = time.Minute
+
+	// Interval between polls while the session is kept open, if the doctor has no 'PollInterval'.
+	DefaultDiagnosePollInterval = 15 * time.Second
+)
+
+// Checks run by the [Doctor], in order.
+const (
+	CheckResolve  = "resolve"
+	CheckConnect  = "connect"
+	CheckProtocol = "protocol"
+	CheckPoll     = "poll"
+	CheckSerial   = "serial"
+	CheckSession  = "session"
+)
+
+// Outcomes of a [Check].
+const (
+	CheckPassed  = "PASS"
+	CheckFailed  = "FAIL"
+	CheckSkipped = "SKIP"
+)
+
+var (
+	ErrDiagnose = errors.New("inverter diagnosis failed")
+)
+
+// Check is the outcome of a single step of a diagnosis.
+type Check struct {
+	// Name of the check (e.g. CheckConnect).
+	Name string
+
+	// Outcome of the check: CheckPassed, CheckFailed or CheckSkipped.
+	Outcome string
+
+	// What was observed (e.g. 'connected in 3ms').
+	Detail string
+
+	// How to fix the problem, for failed checks.
+	Hint string
+}
+
+// Doctor troubleshoots the connection to an inverter in 'TCP-Server' mode, step by step:
+//
+//   - CheckResolve: the host name of the inverter resolves,
+//   - CheckConnect: the port of the inverter accepts connections,
+//   - CheckProtocol: the inverter sends EVT frames,
+//   - CheckPoll: the inverter answers polls,
+//   - CheckSerial: the inverter reports the expected serial number,
+//   - CheckSession: acknowledging status frames keeps the session alive.
+//
+// Since inverters accept only one client at a time, other clients should be stopped while diagnosing.
+type Doctor struct {
+	// Serial number of the inverter. If empty, the inverter can't be polled, so the doctor waits for the inverter to
+	// push a status frame and learns the serial number from it.
+	InverterID string
+
+	// Time allowed to connect to the inverter. Defaults to DefaultDiagnoseDialTimeout.
+	DialTimeout time.Duration
+
+	// Time to wait for the inverter to answer a poll, or to push a status frame if there's no 'InverterID'. Defaults to
+	// DefaultDiagnoseReadTimeout, or DefaultIdentifyTimeout without 'InverterID'.
+	ReadTimeout time.Duration
+
+	// Time the session is kept open to check that acknowledgements keep it alive. Defaults to
+	// DefaultDiagnoseSessionDuration.
+	SessionDuration time.Duration
+
+	// Interval between polls while the session is kept open. Defaults to DefaultDiagnosePollInterval.
+	PollInterval time.Duration
+
+	// Profile used to decode status frames. If nil, the profile is detected from each frame.
+	Profile *types.Profile
+}
+
+// Diagnose the connection to the inverter at the given address (e.g. 192.0.2.1:14889), invoking report with the
+// outcome of each check as soon as it's known. Once a check fails, the checks depending on it are skipped.
+//
+// Returns ErrDiagnose if any check failed, or the context error if the context is cancelled.
+func (d *Doctor) Diagnose(ctx context.Context, addr string, report func(*Check)) error {
+	dg := &diagnosis{doctor: d, addr: addr, serial: d.InverterID}
+	defer dg.close()
+
+	checks := []struct {
+		name string
+		run  func(context.Context) *Check
+	}{
+		{CheckResolve, dg.resolve},
+		{CheckConnect, dg.connect},
+		{CheckProtocol, dg.protocol},
+		{CheckPoll, dg.poll},
+		{CheckSerial, dg.checkSerial},
+		{CheckSession, dg.session},
+	}
+
+	var failed []string
+
+	for _, check := range checks {
+		var c *Check
+
+		if len(failed) > 0 {
+			c = &Check{Outcome: CheckSkipped, Detail: fmt.Sprintf("skipped, since check '%s' failed", failed[0])}
+		} else {
+			c = check.run(ctx)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		c.Name = check.name
+		if c.Outcome == CheckFailed {
+			failed = append(failed, c.Name)
+		}
+
+		report(c)
+	}
+
+	if len(failed) > 0 {
+		return errors.Join(ErrDiagnose, fmt.Errorf("%d of %d checks failed", len(failed), len(checks)))
+	}
+
+	return nil
+}
+
+// State of a diagnosis, shared by its checks.
+type diagnosis struct {
+	doctor *Doctor
+	addr   string
+
+	// resolved address of the inverter
+	target string
+
+	conn   net.Conn
+	client *Client
+
+	// stops closing the connection when the context is cancelled
+	stop func() bool
+
+	// bytes received from the inverter
+	received atomic.Int64
+
+	// serial number of the inverter, given or learned from the inverter
+	serial string
+
+	// first frame received, the first status frame received, and when it was received after polling
+	frame   *Event
+	status  *Event
+	latency time.Duration
+	polled  bool
+
+	// the error which ended the exchange, if any
+	err error
+}
+
+// Record counts the bytes received from the inverter, to tell a silent inverter apart from something else listening
+// on its port.
+func (dg *diagnosis) Record(conn net.Conn, inbound bool, data []byte) {
+	if inbound {
+		dg.received.Add(int64(len(data)))
+	}
+}
+
+func (dg *diagnosis) resolve(ctx context.Context) *Check {
+	host, port, err := net.SplitHostPort(dg.addr)
+	if err != nil {
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  fmt.Sprintf("illegal address %q: %v", dg.addr, err),
+			Hint:    "give the address and port of the inverter (e.g. 192.0.2.1:14889)",
+		}
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		dg.target = net.JoinHostPort(ip.String(), port)
+		return &Check{Outcome: CheckPassed, Detail: fmt.Sprintf("%s is an IP address", host)}
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(ips) == 0 {
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  fmt.Sprintf("failed to resolve %s: %v", host, err),
+			Hint:    "check the host name, or use the IP address of the inverter (shown on the 'System' page of its admin interface, or found with 'openevt discover')",
+		}
+	}
+
+	dg.target = net.JoinHostPort(ips[0].Unmap().String(), port)
+
+	return &Check{Outcome: CheckPassed, Detail: fmt.Sprintf("%s resolves to %s", host, ips[0].Unmap())}
+}
+
+func (dg *diagnosis) connect(ctx context.Context) *Check {
+	dialer := net.Dialer{Timeout: dg.doctor.dialTimeout()}
+
+	start := time.Now()
+
+	conn, err := dialer.DialContext(ctx, "tcp", dg.target)
+	if err != nil {
+		c := &Check{Outcome: CheckFailed, Detail: fmt.Sprintf("failed to connect to %s: %v", dg.target, err)}
+
+		var ne net.Error
+
+		switch {
+		case errors.Is(err, syscall.ECONNREFUSED):
+			c.Hint = "the inverter is up, but nothing accepts connections on this port: check that the inverter is in " +
+				"'TCP-Server' mode ('Network Parameter Settings') and the port ('Other Settings'), see 'openevt provision'"
+		case errors.As(err, &ne) && ne.Timeout(), errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+			c.Hint = "the inverter doesn't respond: it's in standby when there's no sunlight (e.g. at night), so try " +
+				"again in daylight; otherwise check that it joined your Wi-Fi network and that its address didn't change " +
+				"(see 'openevt discover')"
+		default:
+			c.Hint = "check the address of the inverter, and that this machine can reach its network"
+		}
+
+		return c
+	}
+
+	dg.conn = conn
+	dg.stop = context.AfterFunc(ctx, func() { conn.Close() })
+
+	return &Check{Outcome: CheckPassed, Detail: fmt.Sprintf("connected to %s in %s", dg.target, time.Since(start).Round(time.Microsecond))}
+}
+
+func (dg *diagnosis) protocol(ctx context.Context) *Check {
+	dg.client = acceptedClient(&recordingConn{Conn: dg.conn, recorder: dg}, dg.doctor.Profile)
+	dg.client.Address = dg.target
+	dg.client.InverterID = dg.serial
+
+	dg.exchange()
+
+	switch {
+	case dg.frame != nil:
+		return &Check{Outcome: CheckPassed, Detail: fmt.Sprintf("received %s frame", dg.frame.Type)}
+	case dg.received.Load() > 0:
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  fmt.Sprintf("received %d bytes, but no EVT frames", dg.received.Load()),
+			Hint:    "something other than the inverter listens on this port: check the port ('Other Settings') of the inverter",
+		}
+	case errors.Is(dg.err, io.EOF) || errors.Is(dg.err, syscall.ECONNRESET):
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  "the inverter closed the connection without sending anything",
+			Hint: "inverters accept only one client at a time: stop other clients (e.g. the EnverView app, or another " +
+				"OpenEVT instance); the inverter may also be going into standby (e.g. at dusk)",
+		}
+	case dg.serial != "":
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  fmt.Sprintf("no answer to poll within %s", dg.doctor.readTimeout()),
+			Hint: "inverters only answer polls carrying their own serial number: check '--serial-number', or omit it to " +
+				"wait for the inverter to push a status frame; also stop other clients of the inverter",
+		}
+	default:
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  fmt.Sprintf("no status frame pushed within %s", dg.doctor.readTimeout()),
+			Hint:    "give the serial number of the inverter to poll it, or wait longer for the inverter to push a status frame",
+		}
+	}
+}
+
+func (dg *diagnosis) poll(ctx context.Context) *Check {
+	switch {
+	case !dg.polled:
+		return &Check{Outcome: CheckSkipped, Detail: "no serial number given, so the inverter can't be polled"}
+	case dg.status == nil:
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  "the inverter sent frames, but no status",
+			Hint:    "the inverter may not be supported yet; record its traffic with '--record' and open an issue",
+		}
+	case dg.status.Status.InverterId != dg.serial:
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  fmt.Sprintf("the poll wasn't answered, but the inverter pushed a status frame reporting serial number %s", dg.status.Status.InverterId),
+			Hint:    fmt.Sprintf("inverters only answer polls carrying their own serial number: use '--serial-number %s', or check that the address belongs to the right inverter", dg.status.Status.InverterId),
+		}
+	}
+
+	return &Check{Outcome: CheckPassed, Detail: fmt.Sprintf("%s frame received %s after polling", dg.status.Type, dg.latency.Round(time.Microsecond))}
+}
+
+func (dg *diagnosis) checkSerial(ctx context.Context) *Check {
+	switch reported := dg.status.Status.InverterId; {
+	case dg.serial == "":
+		dg.serial = reported
+		dg.client.SetSerial(reported)
+
+		return &Check{Outcome: CheckPassed, Detail: fmt.Sprintf("inverter reports serial number %s", reported)}
+	case reported != dg.serial:
+		return &Check{
+			Outcome: CheckFailed,
+			Detail:  fmt.Sprintf("expected serial number %s, but inverter reports %s", dg.serial, reported),
+			Hint:    fmt.Sprintf("use '--serial-number %s', or check that the address belongs to the right inverter", reported),
+		}
+	}
+
+	return &Check{Outcome: CheckPassed, Detail: fmt.Sprintf("inverter reports serial number %s", dg.serial)}
+}
+
+// Keep the session open, polling the inverter and acknowledging every status frame.
+func (dg *diagnosis) session(ctx context.Context) *Check {
+	start := time.Now()
+	deadline := start.Add(dg.doctor.sessionDuration())
+	acked := 0
+
+	if err := dg.client.Acknowledge(); err != nil {
+		return dg.sessionLost(err, start, acked)
+	}
+
+	acked++
+
+	for time.Now().Before(deadline) {
+		if err := dg.client.Poll(); err != nil {
+			return dg.sessionLost(err, start, acked)
+		}
+
+		answered := false
+		next := time.Now().Add(dg.doctor.pollInterval())
+
+		// acknowledge whatever the inverter sends until the next poll is due
+		for time.Now().Before(next) && time.Now().Before(deadline) {
+			if err := dg.conn.SetReadDeadline(earliest(next, deadline)); err != nil {
+				return dg.sessionLost(err, start, acked)
+			}
+
+			ev, err := dg.client.ReadEvent()
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			if errors.Is(err, ErrFrameDiscarded) {
+				continue
+			}
+			if err != nil {
+				return dg.sessionLost(err, start, acked)
+			}
+
+			if ev.Status == nil || ev.Status.InverterId != dg.serial {
+				continue
+			}
+
+			if err := dg.client.Acknowledge(); err != nil {
+				return dg.sessionLost(err, start, acked)
+			}
+
+			acked++
+			answered = true
+		}
+
+		if !answered && time.Now().Before(deadline) {
+			return &Check{
+				Outcome: CheckFailed,
+				Detail:  fmt.Sprintf("the inverter stopped answering polls after %s", time.Since(start).Round(time.Second)),
+				Hint:    "the connection is open but idle: the Wi-Fi link may be unreliable (see '--wifi.scrape'), or the inverter is going into standby",
+			}
+		}
+	}
+
+	return &Check{
+		Outcome: CheckPassed,
+		Detail:  fmt.Sprintf("session alive for %s, %d status frames acknowledged", dg.doctor.sessionDuration(), acked),
+	}
+}
+
+func (dg *diagnosis) sessionLost(err error, start time.Time, acked int) *Check {
+	return &Check{
+		Outcome: CheckFailed,
+		Detail:  fmt.Sprintf("connection lost after %s, %d status frames acknowledged: %v", time.Since(start).Round(time.Second), acked, err),
+		Hint: "the inverter hangs up on clients which don't acknowledge status frames in time, or when another client " +
+			"takes over: stop other clients (e.g. the EnverView app, or another OpenEVT instance)",
+	}
+}
+
+// Poll the inverter (if the serial number is known) and read frames until a status frame arrives, the read timeout
+// expires, or the connection is closed.
+func (dg *diagnosis) exchange() {
+	start := time.Now()
+
+	if err := dg.conn.SetReadDeadline(start.Add(dg.doctor.readTimeout())); err != nil {
+		dg.err = err
+		return
+	}
+
+	if dg.serial != "" {
+		if dg.err = dg.client.Poll(); dg.err != nil {
+			return
+		}
+
+		dg.polled = true
+	}
+
+	for {
+		ev, err := dg.client.ReadEvent()
+		if errors.Is(err, ErrFrameDiscarded) {
+			// a well-formed frame that couldn't be decoded, still proof of the protocol
+			if dg.frame == nil {
+				dg.frame = &Event{Type: FrameUnknown}
+			}
+
+			continue
+		}
+		if err != nil {
+			dg.err = err
+			return
+		}
+
+		if dg.frame == nil {
+			dg.frame = ev
+		}
+
+		if ev.Status != nil {
+			dg.status = ev
+			dg.latency = time.Since(start)
+			return
+		}
+	}
+}
+
+func (dg *diagnosis) close() {
+	if dg.conn != nil {
+		dg.stop()
+		dg.conn.Close()
+	}
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+
+	return b
+}
+
+func (d *Doctor) dialTimeout() time.Duration {
+	if d.DialTimeout == time.Duration(0) {
+		return DefaultDiagnoseDialTimeout
+	}
+
+	return d.DialTimeout
+}
+
+func (d *Doctor) readTimeout() time.Duration {
+	switch {
+	case d.ReadTimeout != time.Duration(0):
+		return d.ReadTimeout
+	case d.InverterID == "":
+		return DefaultIdentifyTimeout
+	default:
+		return DefaultDiagnoseReadTimeout
+	}
+}
+
+func (d *Doctor) sessionDuration() time.Duration {
+	if d.SessionDuration == time.Duration(0) {
+		return DefaultDiagnoseSessionDuration
+	}
+
+	return d.SessionDuration
+}
+
+func (d *Doctor) pollInterval() time.Duration {
+	if d.PollInterval == time.Duration(0) {
+		return DefaultDiagnosePollInterval
+	}
+
+	return d.PollInterval
+}
@@ -0,0 +1,141 @@
+package evt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/sim"
+)
+
+func TestDoctor(t *testing.T) {
+	diagnose := func(t *testing.T, doctor *Doctor, addr string) (map[string]*Check, error) {
+		t.Helper()
+
+		checks := map[string]*Check{}
+
+		err := doctor.Diagnose(context.Background(), addr, func(c *Check) {
+			checks[c.Name] = c
+		})
+
+		if len(checks) != 6 {
+			t.Fatalf("unexpected number of checks reported: %d", len(checks))
+		}
+
+		return checks, err
+	}
+
+	t.Run("should pass all checks for a healthy inverter", func(t *testing.T) {
+		// the inverter hangs up unless status frames are acknowledged within the session
+		server := sim.NewServer(&sim.Inverter{Serial: "31583078", AckTimeout: 100 * time.Millisecond, NoStandby: true})
+		defer server.Close()
+
+		checks, err := diagnose(t, &Doctor{
+			InverterID:      "31583078",
+			ReadTimeout:     time.Second,
+			SessionDuration: 300 * time.Millisecond,
+			PollInterval:    50 * time.Millisecond,
+		}, server.Addr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for name, c := range checks {
+			if c.Outcome != CheckPassed {
+				t.Fatalf("unexpected outcome of check %s: %+v", name, c)
+			}
+		}
+
+		if stats := server.Inverter.Stats(); stats.Hangups != 0 || stats.Acks < 2 {
+			t.Fatalf("unexpected inverter stats: %+v", stats)
+		}
+	})
+
+	t.Run("should learn the serial number without polling", func(t *testing.T) {
+		server := sim.NewServer(&sim.Inverter{Serial: "30587612", Modules: 1, Interval: 20 * time.Millisecond, NoStandby: true})
+		defer server.Close()
+
+		checks, err := diagnose(t, &Doctor{
+			ReadTimeout:     time.Second,
+			SessionDuration: 100 * time.Millisecond,
+			PollInterval:    50 * time.Millisecond,
+		}, server.Addr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		switch {
+		case checks[CheckPoll].Outcome != CheckSkipped:
+			t.Fatalf("unexpected poll check: %+v", checks[CheckPoll])
+		case !strings.Contains(checks[CheckSerial].Detail, "30587612"):
+			t.Fatalf("unexpected serial check: %+v", checks[CheckSerial])
+		case checks[CheckSession].Outcome != CheckPassed:
+			t.Fatalf("unexpected session check: %+v", checks[CheckSession])
+		}
+	})
+
+	t.Run("should suggest the serial number reported by the inverter", func(t *testing.T) {
+		server := sim.NewServer(&sim.Inverter{Serial: "30587612", Modules: 1, Interval: 20 * time.Millisecond, NoStandby: true})
+		defer server.Close()
+
+		checks, err := diagnose(t, &Doctor{InverterID: "31583078", ReadTimeout: time.Second}, server.Addr)
+		if !errors.Is(err, ErrDiagnose) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		switch {
+		case checks[CheckProtocol].Outcome != CheckPassed:
+			t.Fatalf("unexpected protocol check: %+v", checks[CheckProtocol])
+		case checks[CheckPoll].Outcome != CheckFailed || !strings.Contains(checks[CheckPoll].Hint, "--serial-number 30587612"):
+			t.Fatalf("unexpected poll check: %+v", checks[CheckPoll])
+		case checks[CheckSession].Outcome != CheckSkipped:
+			t.Fatalf("unexpected session check: %+v", checks[CheckSession])
+		}
+	})
+
+	t.Run("should report closed ports", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		addr := ln.Addr().String()
+		ln.Close()
+
+		checks, err := diagnose(t, &Doctor{InverterID: "31583078"}, addr)
+		if !errors.Is(err, ErrDiagnose) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if c := checks[CheckConnect]; c.Outcome != CheckFailed || !strings.Contains(c.Hint, "TCP-Server") {
+			t.Fatalf("unexpected connect check: %+v", c)
+		}
+	})
+
+	t.Run("should report ports which don't speak the protocol", func(t *testing.T) {
+		addr, _ := fakeInverter(t, []byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+
+		checks, err := diagnose(t, &Doctor{InverterID: "31583078", ReadTimeout: 200 * time.Millisecond}, addr)
+		if !errors.Is(err, ErrDiagnose) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if c := checks[CheckProtocol]; c.Outcome != CheckFailed || !strings.Contains(c.Detail, "no EVT frames") {
+			t.Fatalf("unexpected protocol check: %+v", c)
+		}
+	})
+
+	t.Run("should report illegal addresses", func(t *testing.T) {
+		checks, err := diagnose(t, &Doctor{}, "192.0.2.1")
+		if !errors.Is(err, ErrDiagnose) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if c := checks[CheckResolve]; c.Outcome != CheckFailed || checks[CheckConnect].Outcome != CheckSkipped {
+			t.Fatalf("unexpected checks: %+v %+v", c, checks[CheckConnect])
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package evt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+const (
+	// Port inverters listen on in 'TCP-Server' mode, out of the box.
+	DefaultPort = 14889
+
+	// Time allowed to connect to each address, if the scanner has no 'DialTimeout'.
+	DefaultScanDialTimeout = time.Second
+
+	// Time to wait for a status frame from an inverter, if the scanner has no 'ReadTimeout'.
+	DefaultScanReadTimeout = 2 * time.Minute
+
+	// Number of addresses scanned at once, if the scanner has no 'Concurrency'.
+	DefaultScanConcurrency = 64
+
+	// Number of addresses in the largest network the scanner accepts, so that nobody scans the internet by accident.
+	MaxScanAddresses = 1 << 16
+)
+
+var (
+	ErrScan             = errors.New("failed to scan for inverters")
+	ErrInverterNotFound = errors.New("inverter not found")
+)
+
+// Discovery is an inverter found by the [Scanner].
+type Discovery struct {
+	// Address and port of the inverter (e.g. 192.0.2.1:14889).
+	Address string
+
+	// Status received from the inverter, identifying it. Nil if no status frame was received.
+	Status *types.InverterStatus
+
+	// The reason no status was received, if any.
+	Err error
+}
+
+// Scanner finds inverters in 'TCP-Server' mode on the LAN, by connecting to the local mode port of every address in
+// a network and waiting for a status frame.
+//
+// Inverters only answer polls carrying their own serial number, so unless 'InverterID' is known, the scanner waits
+// for the inverter to push a status frame, which can take a while. Since inverters accept only one client at a time,
+// inverters which already have a client (e.g. another OpenEVT instance) can't be identified.
+type Scanner struct {
+	// Port to scan. Defaults to DefaultPort.
+	Port uint16
+
+	// Time allowed to connect to each address. Defaults to DefaultScanDialTimeout.
+	DialTimeout time.Duration
+
+	// Time to wait for a status frame from each inverter. Defaults to DefaultScanReadTimeout.
+	ReadTimeout time.Duration
+
+	// Number of addresses scanned at once. Defaults to DefaultScanConcurrency.
+	Concurrency int
+
+	// If set, inverters are polled with this serial number, so that a matching inverter answers right away.
+	InverterID string
+
+	// Profile used to decode status frames. If nil, the profile is detected from each frame.
+	Profile *types.Profile
+}
+
+// Scan the addresses of the network concurrently, invoking found for every address where the port is open, one at a
+// time. Returns once all addresses have been scanned, or the context is cancelled.
+func (s *Scanner) Scan(ctx context.Context, network netip.Prefix, found func(*Discovery)) error {
+	network = network.Masked()
+
+	if bits := network.Addr().BitLen() - network.Bits(); bits > 16 {
+		return errors.Join(ErrScan, fmt.Errorf("network too large: %s (at most %d addresses)", network, MaxScanAddresses))
+	}
+
+	var (
+		wg  sync.WaitGroup
+		mux sync.Mutex
+		sem = make(chan struct{}, s.concurrency())
+	)
+
+	for addr := range hosts(network) {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			d, ok := s.Probe(ctx, netip.AddrPortFrom(addr, s.port()).String())
+			if !ok {
+				return
+			}
+
+			mux.Lock()
+			defer mux.Unlock()
+
+			found(d)
+		}()
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// Locate the inverter with the given serial number on the network, and return its address. The scan stops as soon as
+// the inverter is found. Returns ErrInverterNotFound if no inverter with this serial number answered.
+func (s *Scanner) Locate(ctx context.Context, network netip.Prefix, serial string) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	scanner := *s
+	scanner.InverterID = serial
+
+	var addr string
+
+	err := scanner.Scan(ctx, network, func(d *Discovery) {
+		if addr == "" && d.Status != nil && d.Status.InverterId == serial {
+			addr = d.Address
+			cancel()
+		}
+	})
+
+	switch {
+	case addr != "":
+		return addr, nil
+	case err != nil:
+		return "", err
+	default:
+		return "", errors.Join(ErrInverterNotFound, fmt.Errorf("no inverter with serial number %s in %s", serial, network))
+	}
+}
+
+// Probe a single address for an inverter. Returns false if nothing is listening on the address.
+func (s *Scanner) Probe(ctx context.Context, addr string) (*Discovery, bool) {
+	dialer := net.Dialer{Timeout: s.dialTimeout()}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, false
+	}
+
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client := acceptedClient(conn, s.Profile)
+	client.Address = addr
+	client.InverterID = s.InverterID
+
+	d := &Discovery{Address: addr}
+
+	if client.InverterID != "" {
+		if err := client.Poll(); err != nil {
+			d.Err = err
+			return d, true
+		}
+	}
+
+	ev, err := client.Identify(s.readTimeout())
+	if err != nil {
+		d.Err = err
+		return d, true
+	}
+
+	// be polite, the inverter is known to hang up on clients which don't acknowledge status frames
+	client.Acknowledge()
+
+	d.Status = ev.Status
+
+	return d, true
+}
+
+// Enumerate the host addresses of the network. For IPv4 networks with more than two addresses, the network and
+// broadcast addresses are skipped.
+func hosts(network netip.Prefix) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		skip := network.Addr().Is4() && network.Bits() < 31
+
+		for addr := network.Addr(); network.Contains(addr); addr = addr.Next() {
+			if skip && (addr == network.Addr() || !network.Contains(addr.Next())) {
+				continue
+			}
+
+			if !yield(addr) {
+				return
+			}
+		}
+	}
+}
+
+func (s *Scanner) port() uint16 {
+	if s.Port == 0 {
+		return DefaultPort
+	}
+
+	return s.Port
+}
+
+func (s *Scanner) dialTimeout() time.Duration {
+	if s.DialTimeout == time.Duration(0) {
+		return DefaultScanDialTimeout
+	}
+
+	return s.DialTimeout
+}
+
+func (s *Scanner) readTimeout() time.Duration {
+	if s.ReadTimeout == time.Duration(0) {
+		return DefaultScanReadTimeout
+	}
+
+	return s.ReadTimeout
+}
+
+func (s *Scanner) concurrency() int {
+	if s.Concurrency <= 0 {
+		return DefaultScanConcurrency
+	}
+
+	return s.Concurrency
+}
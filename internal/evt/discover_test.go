@@ -0,0 +1,122 @@
+package evt
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/sim"
+)
+
+func TestScanner(t *testing.T) {
+	// the simulated inverter listens on 127.0.0.1, other loopback addresses refuse connections
+	scan := func(t *testing.T, scanner *Scanner, network string) []*Discovery {
+		t.Helper()
+
+		var found []*Discovery
+
+		err := scanner.Scan(context.Background(), netip.MustParsePrefix(network), func(d *Discovery) {
+			found = append(found, d)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return found
+	}
+
+	port := func(addr string) uint16 {
+		p, _ := strconv.Atoi(addr[len("127.0.0.1:"):])
+		return uint16(p)
+	}
+
+	t.Run("should poll inverters with a known serial number", func(t *testing.T) {
+		server := sim.NewServer(&sim.Inverter{Serial: "31583078", NoStandby: true})
+		defer server.Close()
+
+		found := scan(t, &Scanner{Port: port(server.Addr), InverterID: "31583078", ReadTimeout: time.Second}, "127.0.0.0/30")
+
+		switch {
+		case len(found) != 1:
+			t.Fatalf("unexpected number of inverters found: %d", len(found))
+		case found[0].Address != server.Addr:
+			t.Fatalf("unexpected address: %s", found[0].Address)
+		case found[0].Err != nil:
+			t.Fatalf("unexpected error: %v", found[0].Err)
+		case found[0].Status.InverterId != "31583078" || len(found[0].Status.Modules) != 2:
+			t.Fatalf("unexpected status: %+v", found[0].Status)
+		}
+	})
+
+	t.Run("should wait for status frames pushed by the inverter", func(t *testing.T) {
+		server := sim.NewServer(&sim.Inverter{Serial: "30587612", Modules: 1, Interval: 20 * time.Millisecond, NoStandby: true})
+		defer server.Close()
+
+		found := scan(t, &Scanner{Port: port(server.Addr), ReadTimeout: time.Second}, "127.0.0.1/32")
+
+		if len(found) != 1 || found[0].Status == nil || found[0].Status.InverterId != "30587612" {
+			t.Fatalf("unexpected inverters found: %+v", found)
+		}
+	})
+
+	t.Run("should report open ports without status frames", func(t *testing.T) {
+		server := sim.NewServer(&sim.Inverter{Serial: "31583078", NoStandby: true})
+		defer server.Close()
+
+		found := scan(t, &Scanner{Port: port(server.Addr), ReadTimeout: 50 * time.Millisecond}, "127.0.0.1/32")
+
+		if len(found) != 1 || found[0].Status != nil || !errors.Is(found[0].Err, ErrIdentify) {
+			t.Fatalf("unexpected inverters found: %+v", found)
+		}
+	})
+
+	t.Run("should locate an inverter by serial number", func(t *testing.T) {
+		server := sim.NewServer(&sim.Inverter{Serial: "31583078", NoStandby: true})
+		defer server.Close()
+
+		scanner := &Scanner{Port: port(server.Addr), ReadTimeout: time.Second}
+		network := netip.MustParsePrefix("127.0.0.0/30")
+
+		addr, err := scanner.Locate(context.Background(), network, "31583078")
+		if err != nil || addr != server.Addr {
+			t.Fatalf("unexpected address: %s (%v)", addr, err)
+		}
+
+		if _, err := scanner.Locate(context.Background(), network, "30587612"); !errors.Is(err, ErrInverterNotFound) {
+			t.Fatalf("expected inverter not found but was: %v", err)
+		}
+	})
+
+	t.Run("should refuse to scan large networks", func(t *testing.T) {
+		err := (&Scanner{}).Scan(context.Background(), netip.MustParsePrefix("10.0.0.0/8"), func(*Discovery) {})
+		if !errors.Is(err, ErrScan) {
+			t.Fatalf("expected scan failure but was: %v", err)
+		}
+	})
+}
+
+func TestHosts(t *testing.T) {
+	t.Run("should skip network and broadcast addresses", func(t *testing.T) {
+		cases := map[string][]string{
+			"192.0.2.0/30":   {"192.0.2.1", "192.0.2.2"},
+			"192.0.2.7/31":   {"192.0.2.6", "192.0.2.7"},
+			"192.0.2.1/32":   {"192.0.2.1"},
+			"2001:db8::/127": {"2001:db8::", "2001:db8::1"},
+		}
+
+		for network, expected := range cases {
+			var addrs []string
+			for addr := range hosts(netip.MustParsePrefix(network).Masked()) {
+				addrs = append(addrs, addr.String())
+			}
+
+			if !slices.Equal(addrs, expected) {
+				t.Fatalf("unexpected hosts of %s: %v", network, addrs)
+			}
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package evt
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+const (
+	// Time allowed to write a frame to a downstream client of the [Proxy] before it's disconnected.
+	ProxyWriteTimeout = 5 * time.Second
+
+	// Number of frames queued for a downstream client of the [Proxy]. Clients which fall further behind are
+	// disconnected.
+	ProxyQueueLen = 16
+)
+
+var (
+	ErrProxyPoll    = errors.New("proxy: failed to forward poll to inverter")
+	ErrProxyTooSlow = errors.New("proxy: downstream client too slow, disconnected")
+	ErrProxyWrite   = errors.New("proxy: failed to write to downstream client")
+)
+
+// Proxy shares a single inverter connection with several downstream clients (e.g. the EnverView app, another OpenEVT
+// instance or a debugging session), since the inverter only accepts one client at a time.
+//
+// Downstream clients connect to the proxy as if it was the inverter. Every frame received from the inverter is
+// forwarded to all downstream clients; register the proxy with 'Router.Tap()' to do so. Polls sent by downstream
+// clients are forwarded to the inverter. The proxy owns acknowledgements: acks and any other messages sent by downstream
+// clients are dropped, so that they can't cause the inverter connection to drop.
+//
+// Frames are written to each downstream client by a goroutine of its own, so that a stalled client never holds up the
+// inverter connection (and with it, the acknowledgements the inverter expects in time).
+type Proxy struct {
+	// Address to listen on for downstream clients (e.g. ':14890').
+	Addr string
+
+	// Client connected to the inverter, used to forward polls.
+	Client *Client
+
+	// If set, called when a poll can't be forwarded to the inverter, or a downstream client is disconnected because it
+	// can't keep up or a write to it failed.
+	OnError func(err error)
+
+	mux   sync.Mutex
+	conns map[net.Conn]chan []byte
+}
+
+// Listen on the proxy address and serve downstream clients until the context is cancelled.
+func (p *Proxy) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", p.Addr)
+	if err != nil {
+		return errors.Join(ErrListen, err)
+	}
+
+	return p.Serve(ctx, ln)
+}
+
+// Serve downstream clients accepted on the listener until the context is cancelled. The listener is closed when Serve
+// returns.
+func (p *Proxy) Serve(ctx context.Context, ln net.Listener) error {
+	return serve(ctx, ln, p.serveConn)
+}
+
+// HandleEvent forwards the frame of the event to all downstream clients.
+func (p *Proxy) HandleEvent(ev *Event) error {
+	p.Broadcast(ev.Raw)
+
+	return nil
+}
+
+// Broadcast queues the frame for all downstream clients, without waiting for it to be written. Clients whose queue is
+// full are disconnected.
+func (p *Proxy) Broadcast(frame []byte) {
+	var errs []error
+
+	p.mux.Lock()
+
+	for conn, queue := range p.conns {
+		select {
+		case queue <- frame:
+		default:
+			p.drop(conn)
+			errs = append(errs, errors.Join(ErrProxyTooSlow, fmt.Errorf("client %s", conn.RemoteAddr())))
+		}
+	}
+
+	p.mux.Unlock()
+
+	for _, err := range errs {
+		p.report(err)
+	}
+}
+
+// Connected returns the number of downstream clients currently connected.
+func (p *Proxy) Connected() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return len(p.conns)
+}
+
+// Read messages from the downstream client until it disconnects, forwarding polls to the inverter.
+func (p *Proxy) serveConn(conn net.Conn) {
+	go p.write(conn, p.register(conn))
+	defer p.unregister(conn)
+
+	reader := newMessageReader(conn)
+
+	for {
+		data, err := reader.ReadFrame()
+		if err != nil {
+			return
+		}
+
+		var frame types.Frame
+		if err := frame.UnmarshalBinary(data); err != nil {
+			continue
+		}
+
+		// a poll is sent with our own serial number, the inverter only answers to its own anyway
+		if frame.Control == types.FrameControl && frame.Command == types.CommandPoll && p.Client != nil {
+			if err := p.Client.Poll(); err != nil {
+				p.report(errors.Join(ErrProxyPoll, err))
+			}
+		}
+	}
+}
+
+// Write frames queued for the downstream client until its queue is closed. The client is disconnected if a write fails.
+func (p *Proxy) write(conn net.Conn, queue <-chan []byte) {
+	for frame := range queue {
+		conn.SetWriteDeadline(time.Now().Add(ProxyWriteTimeout))
+
+		if _, err := conn.Write(frame); err != nil {
+			// clients already disconnected (e.g. for being too slow) have been reported
+			if p.unregister(conn) {
+				p.report(errors.Join(ErrProxyWrite, err))
+			}
+
+			// drain the queue until it's closed
+			for range queue {
+			}
+
+			return
+		}
+	}
+}
+
+func (p *Proxy) register(conn net.Conn) <-chan []byte {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.conns == nil {
+		p.conns = map[net.Conn]chan []byte{}
+	}
+
+	queue := make(chan []byte, ProxyQueueLen)
+	p.conns[conn] = queue
+
+	return queue
+}
+
+// Disconnect the downstream client. Returns false if it was disconnected already.
+func (p *Proxy) unregister(conn net.Conn) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.drop(conn)
+}
+
+// Disconnect the downstream client, and stop its writer. The caller must hold the lock.
+func (p *Proxy) drop(conn net.Conn) bool {
+	queue, ok := p.conns[conn]
+	if !ok {
+		return false
+	}
+
+	delete(p.conns, conn)
+	close(queue)
+	conn.Close()
+
+	return true
+}
+
+func (p *Proxy) report(err error) {
+	if p.OnError != nil {
+		p.OnError(err)
+	}
+}
+
+// Build a frame reader for messages sent by a client to the inverter. Clients send polls and acks in their ASCII-hex
+// form (see [types.PollMessage]), but may also send them in binary form. The form is detected from the first byte.
+func newMessageReader(r io.Reader) *FrameReader {
+	br := bufio.NewReader(r)
+
+	if first, err := br.Peek(1); err == nil && first[0] != types.FrameStart {
+		return NewFrameReader(hex.NewDecoder(br))
+	}
+
+	return NewFrameReader(br)
+}
@@ -0,0 +1,141 @@
+package evt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+func TestProxy(t *testing.T) {
+	t.Run("should share the inverter connection with downstream clients", func(t *testing.T) {
+		addr, received := fakeInverter(t, statusFrame)
+
+		client := Client{Address: addr, InverterID: "30587612"}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		proxy := &Proxy{Client: &client}
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go proxy.Serve(ctx, ln)
+
+		// one downstream client speaks ASCII-hex (like the vendor app), the other binary
+		poll, _ := types.NewPollMessage("30587612")
+		ack, _ := types.NewAckMessage("30587612")
+		var binaryPoll types.Frame
+		binaryPoll.UnmarshalText(poll)
+
+		var downstream []net.Conn
+		for _, msg := range [][]byte{concat(poll, ack), mustMarshalFrame(binaryPoll)} {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			defer conn.Close()
+
+			conn.Write(msg)
+			downstream = append(downstream, conn)
+		}
+
+		for proxy.Connected() != 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		router := NewRouter()
+		router.Tap(proxy)
+
+		if err := client.Dispatch(router); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, conn := range downstream {
+			frame := make([]byte, len(statusFrame))
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			if _, err := io.ReadFull(conn, frame); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(frame, statusFrame) {
+				t.Fatalf("unexpected frame forwarded downstream: %x", frame)
+			}
+		}
+
+		// give the proxy a moment to forward polls
+		time.Sleep(100 * time.Millisecond)
+		client.Close()
+
+		// the downstream ack must not be forwarded, only the client's own
+		data := <-received
+
+		switch {
+		case bytes.Count(data, poll) != 2:
+			t.Fatalf("unexpected number of polls: %s", data)
+		case bytes.Count(data, ack) != 1:
+			t.Fatalf("unexpected number of acks: %s", data)
+		}
+	})
+
+	t.Run("should disconnect downstream clients which can't keep up, without blocking", func(t *testing.T) {
+		var (
+			mux  sync.Mutex
+			errs []error
+		)
+
+		proxy := &Proxy{OnError: func(err error) {
+			mux.Lock()
+			defer mux.Unlock()
+
+			errs = append(errs, err)
+		}}
+
+		// a pipe has no buffer, so a client which never reads stalls the first write
+		conn, stalled := net.Pipe()
+		defer stalled.Close()
+
+		go proxy.serveConn(conn)
+
+		for proxy.Connected() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+
+		start := time.Now()
+
+		for range ProxyQueueLen + 2 {
+			proxy.Broadcast(statusFrame)
+		}
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("broadcast blocked for %s", elapsed)
+		}
+
+		if proxy.Connected() != 0 {
+			t.Fatalf("stalled client wasn't disconnected")
+		}
+
+		// give the writer a moment to notice the connection was closed, which must not be reported again
+		time.Sleep(50 * time.Millisecond)
+
+		mux.Lock()
+		defer mux.Unlock()
+
+		if len(errs) != 1 || !errors.Is(errs[0], ErrProxyTooSlow) {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+	})
+}
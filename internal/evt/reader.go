@@ -0,0 +1,111 @@
+package evt
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// Upper bound on the length of frames accepted by the [FrameReader]. Headers advertising larger frames are treated as
+// garbage, which prevents a corrupted length field from stalling the stream.
+const MaxFrameLen = 512
+
+// FrameReader splits a byte stream from the inverter into frames.
+//
+// Frames may arrive split across several reads or coalesced into a single read. The reader uses the length field in the
+// frame header to reassemble them, and resynchronizes on the next start marker when it encounters bytes that don't
+// form a valid frame.
+type FrameReader struct {
+	r     io.Reader
+	buf   []byte
+	chunk []byte
+
+	// Number of bytes skipped while resynchronizing the stream.
+	Discarded int
+}
+
+// NewFrameReader returns a [FrameReader] reading from r.
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{
+		r:     r,
+		chunk: make([]byte, MaxFrameLen),
+	}
+}
+
+// ReadFrame returns the next valid frame from the stream, blocking until a complete frame is available.
+//
+// Errors from the underlying reader are returned as-is. Any partial frame remains buffered, so ReadFrame can be called
+// again after a read deadline is exceeded.
+func (r *FrameReader) ReadFrame() ([]byte, error) {
+	for {
+		if frame, ok := r.next(); ok {
+			return frame, nil
+		}
+
+		n, err := r.r.Read(r.chunk)
+		r.buf = append(r.buf, r.chunk[:n]...)
+
+		if err != nil {
+			if frame, ok := r.next(); ok {
+				return frame, nil
+			}
+
+			return nil, err
+		}
+	}
+}
+
+// Buffered returns the number of bytes buffered but not yet returned as part of a frame.
+func (r *FrameReader) Buffered() int {
+	return len(r.buf)
+}
+
+// Extract the next valid frame from the buffer, if there is one.
+func (r *FrameReader) next() ([]byte, bool) {
+	for {
+		i := bytes.IndexByte(r.buf, types.FrameStart)
+		if i < 0 {
+			r.discard(len(r.buf))
+			return nil, false
+		}
+
+		r.discard(i)
+
+		if len(r.buf) < types.FrameHeaderLen {
+			return nil, false
+		}
+
+		size, err := types.FrameLen(r.buf)
+		if err != nil || size > MaxFrameLen {
+			r.discard(1)
+			continue
+		}
+
+		if len(r.buf) < size {
+			return nil, false
+		}
+
+		var frame types.Frame
+		if err := frame.UnmarshalBinary(r.buf[:size]); err != nil {
+			r.discard(1)
+			continue
+		}
+
+		data := bytes.Clone(r.buf[:size])
+		r.buf = r.buf[size:]
+
+		return data, true
+	}
+}
+
+// Drop n bytes from the front of the buffer.
+func (r *FrameReader) discard(n int) {
+	r.Discarded += n
+
+	if n == len(r.buf) {
+		r.buf = r.buf[:0]
+	} else {
+		r.buf = r.buf[n:]
+	}
+}
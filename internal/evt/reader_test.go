@@ -0,0 +1,125 @@
+package evt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"testing/iotest"
+)
+
+var (
+	statusFrame = mustDecodeHex("680056681051305876127001790000000000000030587612707945060a4c0003cfda21003a96320500000000" +
+		"00000000000000003058761370794794084a00032db021333a9632050000000000000000000000005116")
+	pollFrame = mustDecodeHex("68001068107731583078000000009816")
+)
+
+func mustDecodeHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func concat(chunks ...[]byte) []byte {
+	return bytes.Join(chunks, nil)
+}
+
+func TestFrameReader(t *testing.T) {
+	t.Run("should read a single frame", func(t *testing.T) {
+		r := NewFrameReader(bytes.NewReader(statusFrame))
+
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(frame, statusFrame) {
+			t.Fatalf("unexpected frame: %x", frame)
+		}
+
+		if _, err := r.ReadFrame(); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected EOF but was: %v", err)
+		}
+	})
+
+	t.Run("should reassemble frames split across reads", func(t *testing.T) {
+		r := NewFrameReader(iotest.OneByteReader(bytes.NewReader(concat(statusFrame, pollFrame))))
+
+		for _, expected := range [][]byte{statusFrame, pollFrame} {
+			frame, err := r.ReadFrame()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(frame, expected) {
+				t.Fatalf("unexpected frame: %x", frame)
+			}
+		}
+	})
+
+	t.Run("should split frames coalesced into one read", func(t *testing.T) {
+		r := NewFrameReader(bytes.NewReader(concat(pollFrame, statusFrame, pollFrame)))
+
+		for _, expected := range [][]byte{pollFrame, statusFrame, pollFrame} {
+			frame, err := r.ReadFrame()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(frame, expected) {
+				t.Fatalf("unexpected frame: %x", frame)
+			}
+		}
+	})
+
+	t.Run("should resynchronize after garbage", func(t *testing.T) {
+		corrupted := bytes.Clone(statusFrame)
+		corrupted[30] ^= 0xff
+
+		stream := concat(
+			[]byte{0x00, 0x68, 0x68, 0x01},
+			corrupted,
+			[]byte{0x68, 0xff, 0xff, 0x68, 0x10, 0x51},
+			statusFrame,
+		)
+
+		r := NewFrameReader(bytes.NewReader(stream))
+
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(frame, statusFrame) {
+			t.Fatalf("unexpected frame: %x", frame)
+		}
+		if r.Discarded != len(stream)-len(statusFrame) {
+			t.Fatalf("unexpected number of discarded bytes: %d", r.Discarded)
+		}
+	})
+
+	t.Run("should retain partial frames across read errors", func(t *testing.T) {
+		r := NewFrameReader(io.MultiReader(
+			bytes.NewReader(statusFrame[:40]),
+			iotest.ErrReader(os.ErrDeadlineExceeded),
+		))
+
+		if _, err := r.ReadFrame(); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded but was: %v", err)
+		}
+		if r.Buffered() != 40 {
+			t.Fatalf("unexpected number of buffered bytes: %d", r.Buffered())
+		}
+
+		r.r = bytes.NewReader(statusFrame[40:])
+
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(frame, statusFrame) {
+			t.Fatalf("unexpected frame: %x", frame)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package evt
+
+import (
+	"net"
+)
+
+// Recorder records raw traffic exchanged with the inverter, e.g. to a packet capture (see package pcap).
+type Recorder interface {
+	// Record data received from (inbound) or sent to (outbound) the remote end of the connection.
+	Record(conn net.Conn, inbound bool, data []byte)
+}
+
+// A connection which records everything read from and written to it.
+type recordingConn struct {
+	net.Conn
+
+	recorder Recorder
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.recorder.Record(c.Conn, true, p[:n])
+	}
+
+	return n, err
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.recorder.Record(c.Conn, false, p[:n])
+	}
+
+	return n, err
+}
@@ -0,0 +1,66 @@
+package evt
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+type recorded struct {
+	inbound bool
+	data    []byte
+}
+
+type fakeRecorder struct {
+	mux     sync.Mutex
+	records []recorded
+}
+
+func (r *fakeRecorder) Record(conn net.Conn, inbound bool, data []byte) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.records = append(r.records, recorded{inbound, bytes.Clone(data)})
+}
+
+func TestClientRecorder(t *testing.T) {
+	t.Run("should record inbound and outbound traffic", func(t *testing.T) {
+		addr, _ := fakeInverter(t, statusFrame)
+
+		recorder := &fakeRecorder{}
+
+		client := Client{Address: addr, InverterID: "30587612", Recorder: recorder}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		defer client.Close()
+
+		if err := client.Poll(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var msg types.InverterStatus
+		if err := client.ReadFrame(&msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		poll, _ := types.NewPollMessage("30587612")
+		ack, _ := types.NewAckMessage("30587612")
+
+		expected := []recorded{{false, poll}, {true, statusFrame}, {false, ack}}
+
+		if len(recorder.records) != len(expected) {
+			t.Fatalf("unexpected number of records: %d", len(recorder.records))
+		}
+
+		for i, r := range recorder.records {
+			if r.inbound != expected[i].inbound || !bytes.Equal(r.data, expected[i].data) {
+				t.Fatalf("unexpected record %d: %v %x", i, r.inbound, r.data)
+			}
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package evt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// Time allowed to connect to the upstream server, if the relay has no 'DialTimeout'.
+const DefaultDialTimeout = 10 * time.Second
+
+var (
+	ErrRelay = errors.New("failed to relay inverter connection")
+)
+
+// Relay accepts connections from inverters in 'TCP-Client' mode and forwards them transparently to an upstream server
+// (e.g. the Envertec cloud), so that the vendor app keeps working while status frames are collected locally.
+//
+// All bytes are forwarded unchanged in both directions. Frames sent by the inverter are decoded in transit and
+// dispatched to the router returned by 'Handler', but the relay never acknowledges frames itself: that's left to the
+// upstream server, and the acknowledgement policy of the router is ignored. Errors returned by handlers are ignored
+// too, so that decoding never interferes with the relayed traffic.
+type Relay struct {
+	// Address to listen on (e.g. ':14889').
+	Addr string
+
+	// Address of the upstream server to forward connections to.
+	Upstream string
+
+	// Time allowed to connect to the upstream server. Defaults to DefaultDialTimeout.
+	DialTimeout time.Duration
+
+	// Profile used to decode status frames. If nil, the profile is detected from each frame.
+	Profile *types.Profile
+
+	// Handler returns the router for events of a connected inverter. The inverter serial number is learned from the
+	// first status frame, so 'InverterID' of the client is empty until then.
+	Handler func(*Client) *Router
+
+	// If set, OnConnect is invoked once an inverter is identified.
+	OnConnect func(*Client)
+
+	// If set, OnDisconnect is invoked with the reason the connection ended.
+	OnDisconnect func(*Client, error)
+}
+
+// Listen on the relay address and relay inverter connections until the context is cancelled.
+func (r *Relay) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", r.Addr)
+	if err != nil {
+		return errors.Join(ErrListen, err)
+	}
+
+	return r.Serve(ctx, ln)
+}
+
+// Relay inverter connections accepted on the listener until the context is cancelled. The listener is closed when
+// Serve returns.
+func (r *Relay) Serve(ctx context.Context, ln net.Listener) error {
+	return serve(ctx, ln, func(conn net.Conn) {
+		client, err := r.relay(conn)
+		if r.OnDisconnect != nil {
+			r.OnDisconnect(client, err)
+		}
+	})
+}
+
+// Forward the inverter connection to the upstream server until either side closes the connection.
+func (r *Relay) relay(conn net.Conn) (*Client, error) {
+	client := acceptedClient(conn, r.Profile)
+
+	upstream, err := net.DialTimeout("tcp", r.Upstream, r.dialTimeout())
+	if err != nil {
+		return client, errors.Join(ErrRelay, err)
+	}
+
+	defer upstream.Close()
+
+	// inbound traffic is teed into the frame reader of the client for decoding
+	pr, pw := io.Pipe()
+	client.reader = NewFrameReader(pr)
+
+	errs := make(chan error, 2)
+
+	// inverter -> upstream
+	go func() {
+		_, err := io.Copy(upstream, io.TeeReader(conn, pw))
+		pw.CloseWithError(err)
+		upstream.Close()
+		errs <- err
+	}()
+
+	// upstream -> inverter
+	go func() {
+		_, err := io.Copy(conn, upstream)
+		conn.Close()
+		errs <- err
+	}()
+
+	router := NewRouter()
+	if r.Handler != nil {
+		router = r.Handler(client)
+	}
+
+	// decode until the inbound stream ends, draining the pipe so that forwarding is never blocked
+	for {
+		frame, err := client.reader.ReadFrame()
+		if err != nil {
+			break
+		}
+
+		ev, err := NewEvent(frame, client.Profile)
+		if err != nil {
+			continue
+		}
+
+		if ev.Status != nil && client.Serial() == "" {
+			client.SetSerial(ev.Status.InverterId)
+
+			if r.OnConnect != nil {
+				r.OnConnect(client)
+			}
+		}
+
+		router.HandleEvent(ev)
+	}
+
+	// the first error ends the session, the second is the consequence of closing the other side
+	err = <-errs
+	<-errs
+
+	if err != nil {
+		return client, errors.Join(ErrRelay, err)
+	}
+
+	return client, nil
+}
+
+func (r *Relay) dialTimeout() time.Duration {
+	if r.DialTimeout == time.Duration(0) {
+		return DefaultDialTimeout
+	}
+
+	return r.DialTimeout
+}
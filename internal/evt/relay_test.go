@@ -0,0 +1,175 @@
+package evt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// Start a stand-in for the upstream (cloud) server, which acknowledges every status frame it receives and returns
+// everything it received once the connection is closed.
+func fakeUpstream(t *testing.T) (string, <-chan []byte) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan []byte, 1)
+
+	go func() {
+		defer close(received)
+
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		var data []byte
+
+		reader := NewFrameReader(io.TeeReader(conn, writerFunc(func(p []byte) (int, error) {
+			data = append(data, p...)
+			return len(p), nil
+		})))
+
+		for {
+			frame, err := reader.ReadFrame()
+			if err != nil {
+				break
+			}
+
+			var status types.InverterStatus
+			if err := status.UnmarshalBinary(frame); err != nil {
+				continue
+			}
+
+			ack, _ := types.NewAckMessage(status.InverterId)
+			conn.Write(ack)
+		}
+
+		received <- data
+	}()
+
+	return ln.Addr().String(), received
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestRelay(t *testing.T) {
+	t.Run("should forward traffic unchanged and decode status frames in transit", func(t *testing.T) {
+		upstream, upstreamReceived := fakeUpstream(t)
+
+		var (
+			mux    sync.Mutex
+			events []FrameType
+		)
+
+		relay := &Relay{
+			Upstream: upstream,
+			Handler: func(client *Client) *Router {
+				record := func(ev *Event) error {
+					mux.Lock()
+					defer mux.Unlock()
+
+					events = append(events, ev.Type)
+					return nil
+				}
+
+				router := NewRouter()
+				router.HandleFunc(FramePollResponse, record)
+				router.HandleFunc(FrameUnknown, record)
+
+				return router
+			},
+		}
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+
+		go func() {
+			done <- relay.Serve(ctx, ln)
+		}()
+
+		garbage := []byte{0xde, 0xad}
+		unknown := mustMarshalFrame(types.Frame{Control: 0x10, Command: 0x42, Payload: []byte{0x01}})
+		sent := concat(statusFrame[:30], statusFrame[30:], garbage, unknown, statusFrame)
+
+		inverter := dialInverter(t, ln.Addr().String(), statusFrame[:30], statusFrame[30:], garbage, unknown, statusFrame)
+
+		ack, _ := types.NewAckMessage("30587612")
+		if data := <-inverter; !bytes.Equal(data, concat(ack, ack)) {
+			t.Fatalf("unexpected data sent to inverter: %s", data)
+		}
+
+		if data := <-upstreamReceived; !bytes.Equal(data, sent) {
+			t.Fatalf("unexpected data forwarded upstream: %x", data)
+		}
+
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(events) != 3 || events[0] != FramePollResponse || events[1] != FrameUnknown || events[2] != FramePollResponse {
+			t.Fatalf("unexpected events dispatched: %v", events)
+		}
+	})
+
+	t.Run("should drop inverter connections if the upstream is unreachable", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// grab a free port, then release it so nothing is listening
+		unreachable := ln.Addr().String()
+		ln.Close()
+
+		disconnected := make(chan error, 1)
+
+		relay := &Relay{
+			Upstream:    unreachable,
+			DialTimeout: 100 * time.Millisecond,
+			OnDisconnect: func(client *Client, err error) {
+				disconnected <- err
+			},
+		}
+
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go relay.Serve(ctx, ln)
+
+		dialInverter(t, ln.Addr().String(), statusFrame)
+
+		if err := <-disconnected; !errors.Is(err, ErrRelay) {
+			t.Fatalf("expected relay failure but was: %v", err)
+		}
+	})
+}
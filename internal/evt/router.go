@@ -0,0 +1,194 @@
+package evt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// FrameType identifies the kind of frame received from the inverter, based on its control and command bytes.
+type FrameType int
+
+const (
+	// A frame with a command we don't (yet) understand, such as alarms.
+	FrameUnknown FrameType = iota
+
+	// A status frame pushed periodically by the inverter.
+	FrameStatus
+
+	// A status frame sent by the inverter in response to a poll.
+	FramePollResponse
+
+	// A poll message, e.g. when another client polls the inverter.
+	FramePoll
+
+	// An acknowledge message, e.g. when another client acknowledges a status frame.
+	FrameAck
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case FrameStatus:
+		return "status"
+	case FramePollResponse:
+		return "poll-response"
+	case FramePoll:
+		return "poll"
+	case FrameAck:
+		return "ack"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a frame received from the inverter, decoded according to its type.
+type Event struct {
+	Type  FrameType
+	Frame types.Frame
+
+	// Time the frame was received (or captured, for events decoded from packet captures).
+	Timestamp time.Time
+
+	// The raw frame, as received.
+	Raw []byte
+
+	// Decoded status, for FrameStatus and FramePollResponse events.
+	Status *types.InverterStatus
+
+	// For FrameUnknown events, the reason the frame couldn't be decoded as a known type (if any).
+	Err error
+}
+
+// NewEvent identifies the type of the raw frame data and decodes it. Status frames are decoded with the given profile,
+// or the profile detected from the frame if nil.
+func NewEvent(data []byte, profile *types.Profile) (*Event, error) {
+	ev := &Event{Raw: data, Timestamp: time.Now()}
+
+	if err := ev.Frame.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+
+	if ev.Frame.Control != types.FrameControl {
+		ev.Err = fmt.Errorf("unexpected control byte [0x%x]", ev.Frame.Control)
+		return ev, nil
+	}
+
+	switch ev.Frame.Command {
+	case types.CommandStatus, types.CommandPollResponse:
+		var status types.InverterStatus
+
+		if err := types.DecodeStatus(data, profile, &status); err != nil {
+			ev.Err = err
+			return ev, nil
+		}
+
+		ev.Status = &status
+		ev.Type = FrameStatus
+		if ev.Frame.Command == types.CommandPollResponse {
+			ev.Type = FramePollResponse
+		}
+	case types.CommandPoll:
+		ev.Type = FramePoll
+	case types.CommandAck:
+		ev.Type = FrameAck
+	}
+
+	return ev, nil
+}
+
+// InverterID returns the inverter serial number carried by the frame, for status, poll and ack frames. Returns an empty
+// string for other frames.
+func (ev *Event) InverterID() string {
+	switch ev.Type {
+	case FrameStatus, FramePollResponse:
+		return ev.Status.InverterId
+	case FramePoll:
+		var msg types.PollMessage
+		if err := msg.UnmarshalBinary(ev.Raw); err == nil {
+			return msg.InverterId
+		}
+	case FrameAck:
+		var msg types.AckMessage
+		if err := msg.UnmarshalBinary(ev.Raw); err == nil {
+			return msg.InverterId
+		}
+	}
+
+	return ""
+}
+
+// Handler responds to events received from the inverter.
+type Handler interface {
+	HandleEvent(*Event) error
+}
+
+// HandlerFunc adapts an ordinary function to a [Handler].
+type HandlerFunc func(*Event) error
+
+func (f HandlerFunc) HandleEvent(ev *Event) error {
+	return f(ev)
+}
+
+// Router dispatches events to handlers registered for their frame type, and decides which frame types are
+// acknowledged.
+//
+// By default, status frames (FrameStatus and FramePollResponse) are acknowledged and all other frames are not. Events
+// without a registered handler are dropped.
+type Router struct {
+	handlers map[FrameType]Handler
+	taps     []Handler
+	acks     map[FrameType]bool
+}
+
+// NewRouter creates a [Router] with the default acknowledgement policy and no handlers.
+func NewRouter() *Router {
+	return &Router{
+		handlers: map[FrameType]Handler{},
+		acks: map[FrameType]bool{
+			FrameStatus:       true,
+			FramePollResponse: true,
+		},
+	}
+}
+
+// Handle registers the handler for events of type t, replacing any existing handler.
+func (r *Router) Handle(t FrameType, h Handler) {
+	r.handlers[t] = h
+}
+
+// HandleFunc registers the handler function for events of type t, replacing any existing handler.
+func (r *Router) HandleFunc(t FrameType, f func(*Event) error) {
+	r.Handle(t, HandlerFunc(f))
+}
+
+// Tap registers a handler invoked for every event, regardless of its type, before the handler registered for its type.
+func (r *Router) Tap(h Handler) {
+	r.taps = append(r.taps, h)
+}
+
+// SetAcknowledge configures whether frames of type t are acknowledged.
+func (r *Router) SetAcknowledge(t FrameType, ack bool) {
+	r.acks[t] = ack
+}
+
+// Acknowledges returns true if frames of type t should be acknowledged.
+func (r *Router) Acknowledges(t FrameType) bool {
+	return r.acks[t]
+}
+
+// HandleEvent dispatches the event to the taps, then to the handler registered for its type.
+func (r *Router) HandleEvent(ev *Event) error {
+	for _, tap := range r.taps {
+		if err := tap.HandleEvent(ev); err != nil {
+			return err
+		}
+	}
+
+	h, ok := r.handlers[ev.Type]
+	if !ok {
+		return nil
+	}
+
+	return h.HandleEvent(ev)
+}
@@ -0,0 +1,171 @@
+package evt
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+func mustMarshalFrame(frame types.Frame) []byte {
+	data, err := frame.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func TestNewEvent(t *testing.T) {
+	pushFrame := bytes.Clone(statusFrame)
+	pushFrame[5] = types.CommandStatus
+	pushFrame[84] -= types.CommandPollResponse - types.CommandStatus
+
+	tests := []struct {
+		name     string
+		frame    []byte
+		expected FrameType
+		status   bool
+		serial   string
+	}{
+		{"status", pushFrame, FrameStatus, true, "30587612"},
+		{"poll-response", statusFrame, FramePollResponse, true, "30587612"},
+		{"poll", pollFrame, FramePoll, false, "31583078"},
+		{"ack", mustDecodeHex("68001068105031583078000000007116"), FrameAck, false, "31583078"},
+		{"unknown command", mustMarshalFrame(types.Frame{Control: 0x10, Command: 0x42, Payload: []byte{0x01}}), FrameUnknown, false, ""},
+		{"unknown control", mustMarshalFrame(types.Frame{Control: 0x11, Command: types.CommandStatus}), FrameUnknown, false, ""},
+		{"short status", mustMarshalFrame(types.Frame{Control: 0x10, Command: types.CommandStatus}), FrameUnknown, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run("should identify "+test.name+" frames", func(t *testing.T) {
+			ev, err := NewEvent(test.frame, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			switch {
+			case ev.Type != test.expected:
+				t.Fatalf("unexpected frame type: %s", ev.Type)
+			case (ev.Status != nil) != test.status:
+				t.Fatalf("unexpected status: %v", ev.Status)
+			case !bytes.Equal(ev.Raw, test.frame):
+				t.Fatalf("unexpected raw frame: %x", ev.Raw)
+			case ev.InverterID() != test.serial:
+				t.Fatalf("unexpected inverter serial: %s", ev.InverterID())
+			}
+		})
+	}
+
+	t.Run("should reject malformed frames", func(t *testing.T) {
+		if _, err := NewEvent(statusFrame[:40], nil); !errors.Is(err, types.ErrFrameDecodeFailure) {
+			t.Fatalf("expected decode failure but was: %v", err)
+		}
+	})
+}
+
+func TestRouter(t *testing.T) {
+	t.Run("should acknowledge status frames by default", func(t *testing.T) {
+		router := NewRouter()
+
+		for ft, expected := range map[FrameType]bool{
+			FrameStatus:       true,
+			FramePollResponse: true,
+			FramePoll:         false,
+			FrameAck:          false,
+			FrameUnknown:      false,
+		} {
+			if router.Acknowledges(ft) != expected {
+				t.Fatalf("unexpected ack policy for %s frames", ft)
+			}
+		}
+	})
+
+	t.Run("should dispatch events to the registered handler", func(t *testing.T) {
+		router := NewRouter()
+
+		var received []FrameType
+		for _, ft := range []FrameType{FrameStatus, FrameUnknown} {
+			router.HandleFunc(ft, func(ev *Event) error {
+				received = append(received, ft)
+				return nil
+			})
+		}
+
+		for _, ft := range []FrameType{FrameUnknown, FramePoll, FrameStatus} {
+			if err := router.HandleEvent(&Event{Type: ft}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if len(received) != 2 || received[0] != FrameUnknown || received[1] != FrameStatus {
+			t.Fatalf("unexpected events dispatched: %v", received)
+		}
+	})
+
+	t.Run("should dispatch every event to taps", func(t *testing.T) {
+		router := NewRouter()
+
+		var received []string
+		router.Tap(HandlerFunc(func(ev *Event) error {
+			received = append(received, "tap:"+ev.Type.String())
+			return nil
+		}))
+		router.HandleFunc(FrameStatus, func(ev *Event) error {
+			received = append(received, ev.Type.String())
+			return nil
+		})
+
+		for _, ft := range []FrameType{FramePoll, FrameStatus} {
+			if err := router.HandleEvent(&Event{Type: ft}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if !slices.Equal(received, []string{"tap:poll", "tap:status", "status"}) {
+			t.Fatalf("unexpected events dispatched: %v", received)
+		}
+	})
+}
+
+func TestClientDispatch(t *testing.T) {
+	t.Run("should acknowledge frames according to the router", func(t *testing.T) {
+		unknown := mustMarshalFrame(types.Frame{Control: 0x10, Command: 0x42, Payload: []byte{0x01}})
+		addr, received := fakeInverter(t, concat(unknown, statusFrame, pollFrame))
+
+		client := Client{Address: addr, InverterID: "30587612"}
+		if err := client.Connect(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		router := NewRouter()
+		router.SetAcknowledge(FramePoll, true)
+
+		var events []*Event
+		for _, ft := range []FrameType{FrameUnknown, FramePollResponse, FramePoll} {
+			router.HandleFunc(ft, func(ev *Event) error {
+				events = append(events, ev)
+				return nil
+			})
+		}
+
+		for range 3 {
+			if err := client.Dispatch(router); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		client.Close()
+
+		if len(events) != 3 || events[0].Type != FrameUnknown || events[1].Type != FramePollResponse {
+			t.Fatalf("unexpected events dispatched: %v", events)
+		}
+
+		ack, _ := types.NewAckMessage("30587612")
+		if acks := bytes.Count(<-received, ack); acks != 2 {
+			t.Fatalf("unexpected number of acks: %d", acks)
+		}
+	})
+}
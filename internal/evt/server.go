@@ -0,0 +1,238 @@
+package evt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// Time allowed for a newly connected inverter to identify itself with a status frame, if the server has no
+// 'IdentifyTimeout'.
+const DefaultIdentifyTimeout = 5 * time.Minute
+
+var (
+	ErrListen = errors.New("failed to listen for inverters")
+)
+
+// Server accepts connections from inverters configured in 'TCP-Client' mode, which dial in to a configured server
+// rather than waiting for clients to connect.
+//
+// Each inverter is identified by the serial number in the first status frame it sends. Once identified, the session is
+// handled much like a [Client] connection: frames are acknowledged and dispatched according to the router returned by
+// 'Handler'.
+type Server struct {
+	// Address to listen on (e.g. ':14889').
+	Addr string
+
+	// Profile used to decode status frames. If nil, the profile is detected from each frame.
+	Profile *types.Profile
+
+	// If set, the inverter is polled if it doesn't send a message within this interval.
+	ReadTimeout time.Duration
+
+	// Time allowed for a newly connected inverter to send its first status frame. Defaults to DefaultIdentifyTimeout.
+	IdentifyTimeout time.Duration
+
+	// Handler returns the router for events of an identified inverter.
+	Handler func(*Client) *Router
+
+	// If set, OnConnect is invoked once an inverter is identified.
+	OnConnect func(*Client)
+
+	// If set, OnDisconnect is invoked with the reason the connection ended. It's also invoked if the inverter couldn't
+	// be identified, in which case the client has no 'InverterID'. It isn't invoked for connections closed because the
+	// inverter reconnected, since the inverter is still connected.
+	OnDisconnect func(*Client, error)
+
+	mux     sync.Mutex
+	clients map[string]*Client
+}
+
+// Listen on the server address and serve inverter connections until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return errors.Join(ErrListen, err)
+	}
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve inverter connections accepted on the listener until the context is cancelled. The listener is closed when
+// Serve returns.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	return serve(ctx, ln, func(conn net.Conn) {
+		client, superseded, err := s.serveConn(conn)
+		if s.OnDisconnect != nil && !superseded {
+			s.OnDisconnect(client, err)
+		}
+	})
+}
+
+// Accept connections on the listener until the context is cancelled, handling each in its own goroutine. Connections
+// are closed once the handler returns or the context is cancelled, and serve waits for all handlers to return.
+func serve(ctx context.Context, ln net.Listener, handle func(net.Conn)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return errors.Join(ErrListen, err)
+		}
+
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.SetKeepAlive(true)
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+
+			handle(conn)
+		}()
+	}
+}
+
+// Identify the inverter on the connection and handle its session until the connection is closed. If the session ended
+// because the inverter reconnected, 'superseded' is set.
+func (s *Server) serveConn(conn net.Conn) (client *Client, superseded bool, err error) {
+	client = acceptedClient(conn, s.Profile)
+	client.ReadTimeout = s.ReadTimeout
+
+	ev, err := client.Identify(s.identifyTimeout())
+	if err != nil {
+		return client, false, err
+	}
+
+	s.register(client)
+	defer func() { superseded = !s.unregister(client) }()
+
+	if s.OnConnect != nil {
+		s.OnConnect(client)
+	}
+
+	router := NewRouter()
+	if s.Handler != nil {
+		router = s.Handler(client)
+	}
+
+	if err := client.DispatchEvent(router, ev); err != nil {
+		return client, false, err
+	}
+
+	for {
+		err := client.Dispatch(router)
+
+		// if we reached the deadline, poll
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if err := client.Poll(); err != nil {
+				return client, false, err
+			}
+
+			continue
+		}
+
+		// we received something we cant parse, skip over it
+		if errors.Is(err, ErrFrameDiscarded) {
+			continue
+		}
+
+		if err != nil {
+			return client, false, err
+		}
+	}
+}
+
+// Build a client for an inverter which connected to us.
+func acceptedClient(conn net.Conn, profile *types.Profile) *Client {
+	// the inverter connects from an ephemeral port, so only the host identifies where it connected from
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		host = conn.RemoteAddr().String()
+	}
+
+	return &Client{
+		Address: host,
+		Profile: profile,
+		conn:    conn,
+		reader:  NewFrameReader(conn),
+	}
+}
+
+func (s *Server) identifyTimeout() time.Duration {
+	if s.IdentifyTimeout == time.Duration(0) {
+		return DefaultIdentifyTimeout
+	}
+
+	return s.IdentifyTimeout
+}
+
+// Track the connected client. If the inverter reconnects before the previous connection is torn down (e.g. after a
+// Wi-Fi dropout), the stale connection is closed.
+func (s *Server) register(client *Client) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.clients == nil {
+		s.clients = map[string]*Client{}
+	}
+
+	serial := client.Serial()
+
+	if stale, ok := s.clients[serial]; ok {
+		stale.Close()
+	}
+
+	s.clients[serial] = client
+}
+
+// Stop tracking the client, reporting false if it was already replaced by a newer connection from the same inverter.
+func (s *Server) unregister(client *Client) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	serial := client.Serial()
+	if s.clients[serial] != client {
+		return false
+	}
+
+	delete(s.clients, serial)
+
+	return true
+}
+
+// Connected returns the serial numbers of the inverters currently connected to the server.
+func (s *Server) Connected() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var serials []string
+	for sn := range s.clients {
+		serials = append(serials, sn)
+	}
+
+	return serials
+}
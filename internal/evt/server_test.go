@@ -0,0 +1,217 @@
+package evt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// Start a server on a random local port, returning its address and a function which stops it and waits for it to
+// return.
+func startServer(t *testing.T, server *Server) (string, func()) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- server.Serve(ctx, ln)
+	}()
+
+	stop := func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	return ln.Addr().String(), stop
+}
+
+// Dial the server like an inverter in 'TCP-Client' mode, sending the given frames and returning everything the server
+// sends back until the connection is closed.
+func dialInverter(t *testing.T, addr string, frames ...[]byte) <-chan []byte {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	received := make(chan []byte, 1)
+
+	go func() {
+		defer close(received)
+		defer conn.Close()
+
+		for _, frame := range frames {
+			conn.Write(frame)
+		}
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	return received
+}
+
+func TestServer(t *testing.T) {
+	t.Run("should identify inverters by serial number and acknowledge their frames", func(t *testing.T) {
+		other, err := (&types.InverterStatus{
+			InverterId: "31583078",
+			Modules:    []types.InverterModuleStatus{{ModuleId: "31583078", FirmwareVersion: "1/1"}},
+		}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var (
+			mux       sync.Mutex
+			serials   []string
+			connected []string
+		)
+
+		server := &Server{
+			Handler: func(client *Client) *Router {
+				record := func(ev *Event) error {
+					mux.Lock()
+					defer mux.Unlock()
+
+					serials = append(serials, client.InverterID+"/"+ev.Status.InverterId)
+					return nil
+				}
+
+				router := NewRouter()
+				router.HandleFunc(FrameStatus, record)
+				router.HandleFunc(FramePollResponse, record)
+
+				return router
+			},
+			OnConnect: func(client *Client) {
+				mux.Lock()
+				defer mux.Unlock()
+
+				connected = append(connected, client.InverterID)
+			},
+		}
+
+		addr, stop := startServer(t, server)
+
+		first := dialInverter(t, addr, pollFrame, statusFrame, statusFrame)
+		second := dialInverter(t, addr, other)
+
+		ack, _ := types.NewAckMessage("30587612")
+		if acks := bytes.Count(<-first, ack); acks != 2 {
+			t.Fatalf("unexpected number of acks: %d", acks)
+		}
+
+		ack, _ = types.NewAckMessage("31583078")
+		if acks := bytes.Count(<-second, ack); acks != 1 {
+			t.Fatalf("unexpected number of acks: %d", acks)
+		}
+
+		stop()
+
+		slices.Sort(serials)
+		slices.Sort(connected)
+
+		switch {
+		case !slices.Equal(connected, []string{"30587612", "31583078"}):
+			t.Fatalf("unexpected inverters connected: %v", connected)
+		case !slices.Equal(serials, []string{"30587612/30587612", "30587612/30587612", "31583078/31583078"}):
+			t.Fatalf("unexpected events dispatched: %v", serials)
+		}
+	})
+
+	t.Run("should drop connections that don't identify themselves", func(t *testing.T) {
+		disconnected := make(chan error, 1)
+
+		server := &Server{
+			IdentifyTimeout: 50 * time.Millisecond,
+			OnDisconnect: func(client *Client, err error) {
+				disconnected <- err
+			},
+		}
+
+		addr, stop := startServer(t, server)
+		defer stop()
+
+		received := dialInverter(t, addr, pollFrame)
+
+		if err := <-disconnected; !errors.Is(err, ErrIdentify) {
+			t.Fatalf("expected identify failure but was: %v", err)
+		}
+		if data := <-received; len(data) != 0 {
+			t.Fatalf("unexpected data sent to inverter: %x", data)
+		}
+	})
+	t.Run("should not report connections superseded by a reconnection as disconnected", func(t *testing.T) {
+		events := make(chan string, 8)
+
+		server := &Server{
+			OnConnect: func(client *Client) {
+				events <- "connect " + client.Serial()
+			},
+			OnDisconnect: func(client *Client, err error) {
+				events <- "disconnect " + client.Serial()
+			},
+		}
+
+		addr, stop := startServer(t, server)
+
+		stale, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		defer stale.Close()
+
+		stale.Write(statusFrame)
+
+		if ev := <-events; ev != "connect 30587612" {
+			t.Fatalf("unexpected callback: %s", ev)
+		}
+
+		// the inverter reconnects before the stale connection is torn down
+		received := dialInverter(t, addr, statusFrame)
+
+		if ev := <-events; ev != "connect 30587612" {
+			t.Fatalf("unexpected callback: %s", ev)
+		}
+
+		// the stale connection is closed by the server
+		stale.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadAll(stale); err != nil {
+			t.Fatalf("expected stale connection to be closed but was: %v", err)
+		}
+
+		<-received
+
+		if ev := <-events; ev != "disconnect 30587612" {
+			t.Fatalf("unexpected callback: %s", ev)
+		}
+
+		stop()
+
+		select {
+		case ev := <-events:
+			t.Fatalf("unexpected callback: %s", ev)
+		default:
+		}
+	})
+}
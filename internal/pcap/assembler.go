@@ -0,0 +1,129 @@
+package pcap
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// Number of out-of-order TCP segments buffered per stream. Beyond this, the missing data is assumed to be lost (e.g.
+// dropped by the capture) and the stream skips ahead.
+const MaxPendingSegments = 64
+
+// Assembler reassembles the TCP streams of captured packets, so that data is delivered in order and exactly once,
+// despite retransmissions and out-of-order segments. UDP packets are delivered as-is.
+type Assembler struct {
+	// Invoked with each contiguous chunk of stream data, in order. The payload of the packet is trimmed to the data
+	// not delivered before.
+	OnData func(*Packet)
+
+	streams map[[2]netip.AddrPort]*tcpStream
+}
+
+type tcpStream struct {
+	next    uint32
+	pending []*Packet
+}
+
+// Add a captured packet.
+func (a *Assembler) Add(p *Packet) {
+	if p.Protocol != ProtocolTCP {
+		if len(p.Payload) > 0 {
+			a.OnData(p)
+		}
+
+		return
+	}
+
+	if a.streams == nil {
+		a.streams = map[[2]netip.AddrPort]*tcpStream{}
+	}
+
+	flow := [2]netip.AddrPort{p.Src, p.Dst}
+
+	if p.Flags&FlagRST != 0 {
+		delete(a.streams, flow)
+		return
+	}
+
+	st, ok := a.streams[flow]
+	if !ok || p.Flags&FlagSYN != 0 {
+		// without the handshake, the stream starts at the first segment seen
+		st = &tcpStream{next: p.Seq}
+		if p.Flags&FlagSYN != 0 {
+			st.next++
+		}
+
+		a.streams[flow] = st
+	}
+
+	if len(p.Payload) == 0 {
+		return
+	}
+
+	if int32(p.Seq-st.next) > 0 {
+		st.pending = append(st.pending, p)
+
+		if len(st.pending) > MaxPendingSegments {
+			a.skip(st)
+		}
+
+		return
+	}
+
+	a.deliver(st, p)
+	a.drain(st)
+}
+
+// Flush delivers the data still buffered for all streams, skipping over missing segments.
+func (a *Assembler) Flush() {
+	for flow, st := range a.streams {
+		for len(st.pending) > 0 {
+			a.skip(st)
+		}
+
+		delete(a.streams, flow)
+	}
+}
+
+// Deliver the part of the segment which wasn't delivered before.
+func (a *Assembler) deliver(st *tcpStream, p *Packet) {
+	overlap := int(st.next - p.Seq)
+	if int32(st.next-p.Seq) < 0 || overlap >= len(p.Payload) {
+		return
+	}
+
+	chunk := *p
+	chunk.Seq = st.next
+	chunk.Payload = p.Payload[overlap:]
+
+	st.next += uint32(len(chunk.Payload))
+
+	a.OnData(&chunk)
+}
+
+// Deliver pending segments which have become contiguous.
+func (a *Assembler) drain(st *tcpStream) {
+	for {
+		i := slices.IndexFunc(st.pending, func(p *Packet) bool {
+			return int32(p.Seq-st.next) <= 0
+		})
+		if i < 0 {
+			return
+		}
+
+		p := st.pending[i]
+		st.pending = slices.Delete(st.pending, i, i+1)
+
+		a.deliver(st, p)
+	}
+}
+
+// Skip ahead to the earliest pending segment, giving up on the missing data before it.
+func (a *Assembler) skip(st *tcpStream) {
+	slices.SortFunc(st.pending, func(x, y *Packet) int {
+		return int(int32(x.Seq - y.Seq))
+	})
+
+	st.next = st.pending[0].Seq
+	a.drain(st)
+}
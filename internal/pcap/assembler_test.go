@@ -0,0 +1,52 @@
+package pcap
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestAssembler(t *testing.T) {
+	t.Run("should reassemble streams in order and exactly once", func(t *testing.T) {
+		var (
+			inverter = netip.MustParseAddrPort("192.0.2.54:14889")
+			client   = netip.MustParseAddrPort("192.0.2.1:50000")
+		)
+
+		segment := func(seq uint32, flags byte, payload string) *Packet {
+			return &Packet{Protocol: ProtocolTCP, Src: inverter, Dst: client, Seq: seq, Flags: flags, Payload: []byte(payload)}
+		}
+
+		var received []string
+
+		a := &Assembler{OnData: func(p *Packet) {
+			received = append(received, p.Src.String()+":"+string(p.Payload))
+		}}
+
+		for _, p := range []*Packet{
+			segment(99, FlagSYN, ""),
+			segment(100, FlagACK, "abc"),
+			segment(106, FlagACK, "ghi"), // out of order
+			segment(100, FlagACK, "abc"), // retransmission
+			segment(103, FlagACK, "def"),
+			segment(104, FlagACK, "efgh"), // overlapping retransmission
+			{Protocol: ProtocolUDP, Src: client, Dst: inverter, Payload: []byte("udp")},
+			segment(115, FlagACK, "xyz"), // after a gap which is never filled
+		} {
+			a.Add(p)
+		}
+
+		a.Flush()
+
+		expected := []string{"192.0.2.54:14889:abc", "192.0.2.54:14889:def", "192.0.2.54:14889:ghi", "192.0.2.1:50000:udp", "192.0.2.54:14889:xyz"}
+
+		if len(received) != len(expected) {
+			t.Fatalf("unexpected data: %v", received)
+		}
+
+		for i := range expected {
+			if received[i] != expected[i] {
+				t.Fatalf("unexpected data: %v", received)
+			}
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Protocols of captured packets.
+const (
+	ProtocolTCP = 6
+	ProtocolUDP = 17
+)
+
+// TCP flags.
+const (
+	FlagFIN = 0x01
+	FlagSYN = 0x02
+	FlagRST = 0x04
+	FlagPSH = 0x08
+	FlagACK = 0x10
+)
+
+var (
+	ErrPacketEncodeFailure = errors.New("pcap: failed to encode packet")
+)
+
+// Packet is a chunk of data exchanged between two endpoints, as seen on the wire.
+type Packet struct {
+	Timestamp time.Time
+
+	// ProtocolTCP or ProtocolUDP.
+	Protocol int
+
+	Src netip.AddrPort
+	Dst netip.AddrPort
+
+	// For TCP packets, the sequence and acknowledgement numbers, and flags. When writing, flags default to PSH and
+	// ACK.
+	Seq   uint32
+	Ack   uint32
+	Flags byte
+
+	// Whether the packet was received (true) or sent (false) by the capturing host, if known.
+	Inbound bool
+
+	Payload []byte
+}
+
+// Build the raw IP packet (LINKTYPE_RAW) for the packet, with synthesized IP and TCP/UDP headers.
+func (p *Packet) marshalIP() ([]byte, error) {
+	transport, err := p.marshalTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	src, dst := p.Src.Addr().Unmap(), p.Dst.Addr().Unmap()
+
+	if src.Is4() && dst.Is4() {
+		hdr := make([]byte, 20)
+		hdr[0] = 0x45
+		binary.BigEndian.PutUint16(hdr[2:], uint16(len(hdr)+len(transport)))
+		binary.BigEndian.PutUint16(hdr[6:], 0x4000) // don't fragment
+		hdr[8] = 64
+		hdr[9] = byte(p.Protocol)
+		copy(hdr[12:16], src.AsSlice())
+		copy(hdr[16:20], dst.AsSlice())
+		binary.BigEndian.PutUint16(hdr[10:], checksum(hdr))
+
+		return append(hdr, transport...), nil
+	}
+
+	hdr := make([]byte, 40)
+	hdr[0] = 0x60
+	binary.BigEndian.PutUint16(hdr[4:], uint16(len(transport)))
+	hdr[6] = byte(p.Protocol)
+	hdr[7] = 64
+
+	s16, d16 := p.Src.Addr().As16(), p.Dst.Addr().As16()
+	copy(hdr[8:24], s16[:])
+	copy(hdr[24:40], d16[:])
+
+	return append(hdr, transport...), nil
+}
+
+// Build the TCP or UDP segment for the packet, including the checksum.
+func (p *Packet) marshalTransport() ([]byte, error) {
+	var hdr []byte
+
+	switch p.Protocol {
+	case ProtocolTCP:
+		hdr = make([]byte, 20)
+		binary.BigEndian.PutUint32(hdr[4:], p.Seq)
+		binary.BigEndian.PutUint32(hdr[8:], p.Ack)
+		hdr[12] = 5 << 4
+		hdr[13] = p.Flags
+		if p.Flags == 0 {
+			hdr[13] = FlagPSH | FlagACK
+		}
+		binary.BigEndian.PutUint16(hdr[14:], 0xffff)
+	case ProtocolUDP:
+		hdr = make([]byte, 8)
+		binary.BigEndian.PutUint16(hdr[4:], uint16(len(hdr)+len(p.Payload)))
+	default:
+		return nil, errors.Join(ErrPacketEncodeFailure, fmt.Errorf("unsupported protocol: %d", p.Protocol))
+	}
+
+	binary.BigEndian.PutUint16(hdr[0:], p.Src.Port())
+	binary.BigEndian.PutUint16(hdr[2:], p.Dst.Port())
+
+	segment := append(hdr, p.Payload...)
+
+	// checksum over the pseudo header and the segment
+	var pseudo []byte
+
+	src, dst := p.Src.Addr().Unmap(), p.Dst.Addr().Unmap()
+	if src.Is4() && dst.Is4() {
+		pseudo = append(src.AsSlice(), dst.AsSlice()...)
+		pseudo = append(pseudo, 0, byte(p.Protocol))
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(segment)))
+	} else {
+		s16, d16 := p.Src.Addr().As16(), p.Dst.Addr().As16()
+		pseudo = append(s16[:], d16[:]...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(segment)))
+		pseudo = append(pseudo, 0, 0, 0, byte(p.Protocol))
+	}
+
+	offset := 16
+	if p.Protocol == ProtocolUDP {
+		offset = 6
+	}
+
+	binary.BigEndian.PutUint16(segment[offset:], checksum(append(pseudo, segment...)))
+
+	return segment, nil
+}
+
+// Compute the internet checksum (RFC 1071) of data.
+func checksum(data []byte) uint16 {
+	var sum uint32
+
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return ^uint16(sum)
+}
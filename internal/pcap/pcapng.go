@@ -0,0 +1,132 @@
+// Package pcap reads and writes packet captures in the pcap and pcapng formats, which can be opened with Wireshark.
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// pcapng block types.
+const (
+	blockSectionHeader  = 0x0a0d0d0a
+	blockInterfaceDesc  = 0x00000001
+	blockEnhancedPacket = 0x00000006
+	blockByteOrderMagic = 0x1a2b3c4d
+	optEndOfOpt         = 0
+	optShbUserAppl      = 4
+	optIfName           = 2
+	optEpbFlags         = 2
+	epbFlagInbound      = 0x1
+	epbFlagOutbound     = 0x2
+)
+
+// Link types.
+const (
+	LinkTypeRaw = 101
+)
+
+var (
+	ErrWriteFailure = errors.New("pcap: failed to write capture")
+)
+
+// Writer writes packets to a pcapng capture. Packets are written as raw IP packets (LINKTYPE_RAW), with IP and
+// TCP/UDP headers synthesized from the packet endpoints, so that Wireshark can follow the streams.
+type Writer struct {
+	w io.Writer
+	n int64
+}
+
+// NewWriter starts a pcapng capture on w, writing the section header and interface description blocks.
+func NewWriter(w io.Writer) (*Writer, error) {
+	pw := &Writer{w: w}
+
+	shb := binary.LittleEndian.AppendUint32(nil, blockByteOrderMagic)
+	shb = binary.LittleEndian.AppendUint16(shb, 1) // major version
+	shb = binary.LittleEndian.AppendUint16(shb, 0) // minor version
+	shb = binary.LittleEndian.AppendUint64(shb, 0xffffffffffffffff)
+	shb = appendOption(shb, optShbUserAppl, []byte("openevt"))
+	shb = appendOption(shb, optEndOfOpt, nil)
+
+	if err := pw.writeBlock(blockSectionHeader, shb); err != nil {
+		return nil, err
+	}
+
+	idb := binary.LittleEndian.AppendUint16(nil, LinkTypeRaw)
+	idb = binary.LittleEndian.AppendUint16(idb, 0) // reserved
+	idb = binary.LittleEndian.AppendUint32(idb, 0) // no snap length
+	idb = appendOption(idb, optIfName, []byte("openevt"))
+	idb = appendOption(idb, optEndOfOpt, nil)
+
+	if err := pw.writeBlock(blockInterfaceDesc, idb); err != nil {
+		return nil, err
+	}
+
+	return pw, nil
+}
+
+// WritePacket writes the packet to the capture as an enhanced packet block, recording its direction.
+func (w *Writer) WritePacket(p *Packet) error {
+	data, err := p.marshalIP()
+	if err != nil {
+		return errors.Join(ErrWriteFailure, err)
+	}
+
+	ts := uint64(p.Timestamp.UnixMicro())
+
+	epb := binary.LittleEndian.AppendUint32(nil, 0) // interface id
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data)))
+	epb = append(epb, pad(data)...)
+
+	flags := uint32(epbFlagOutbound)
+	if p.Inbound {
+		flags = epbFlagInbound
+	}
+
+	epb = appendOption(epb, optEpbFlags, binary.LittleEndian.AppendUint32(nil, flags))
+	epb = appendOption(epb, optEndOfOpt, nil)
+
+	return w.writeBlock(blockEnhancedPacket, epb)
+}
+
+// Size returns the number of bytes written to the capture so far.
+func (w *Writer) Size() int64 {
+	return w.n
+}
+
+func (w *Writer) writeBlock(blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+
+	block := binary.LittleEndian.AppendUint32(nil, blockType)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, length)
+
+	n, err := w.w.Write(block)
+	w.n += int64(n)
+	if err != nil {
+		return errors.Join(ErrWriteFailure, err)
+	}
+
+	return nil
+}
+
+// Append a pcapng option, padded to 32 bits.
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+
+	return append(b, pad(value)...)
+}
+
+// Pad data with zeros to a multiple of 32 bits.
+func pad(data []byte) []byte {
+	if len(data)%4 == 0 {
+		return data
+	}
+
+	return append(data[:len(data):len(data)], make([]byte, 4-len(data)%4)...)
+}
@@ -0,0 +1,123 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+type block struct {
+	blockType uint32
+	body      []byte
+}
+
+// Split a pcapng capture into blocks, validating the block lengths.
+func splitBlocks(t *testing.T, data []byte) []block {
+	t.Helper()
+
+	var blocks []block
+
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("truncated block: %x", data)
+		}
+
+		length := binary.LittleEndian.Uint32(data[4:])
+		if length%4 != 0 || int(length) > len(data) {
+			t.Fatalf("illegal block length: %d", length)
+		}
+		if trailer := binary.LittleEndian.Uint32(data[length-4:]); trailer != length {
+			t.Fatalf("mismatched block length: %d != %d", trailer, length)
+		}
+
+		blocks = append(blocks, block{binary.LittleEndian.Uint32(data), data[8 : length-4]})
+		data = data[length:]
+	}
+
+	return blocks
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("should write packets as enhanced packet blocks", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		w, err := NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		packets := []Packet{
+			{
+				Timestamp: time.UnixMicro(1750000000123456),
+				Protocol:  ProtocolTCP,
+				Src:       netip.MustParseAddrPort("192.0.2.1:50000"),
+				Dst:       netip.MustParseAddrPort("192.0.2.54:14889"),
+				Seq:       1,
+				Payload:   []byte("68001068107731583078000000009816"),
+			},
+			{
+				Timestamp: time.UnixMicro(1750000000223456),
+				Protocol:  ProtocolUDP,
+				Src:       netip.MustParseAddrPort("[2001:db8::54]:14889"),
+				Dst:       netip.MustParseAddrPort("[2001:db8::1]:50000"),
+				Inbound:   true,
+				Payload:   []byte{0x68, 0x00, 0x56},
+			},
+		}
+
+		for _, p := range packets {
+			if err := w.WritePacket(&p); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if w.Size() != int64(buf.Len()) {
+			t.Fatalf("unexpected size: %d", w.Size())
+		}
+
+		blocks := splitBlocks(t, buf.Bytes())
+		if len(blocks) != 4 {
+			t.Fatalf("unexpected number of blocks: %d", len(blocks))
+		}
+
+		switch {
+		case blocks[0].blockType != blockSectionHeader || binary.LittleEndian.Uint32(blocks[0].body) != blockByteOrderMagic:
+			t.Fatalf("unexpected section header block: %x", blocks[0].body)
+		case blocks[1].blockType != blockInterfaceDesc || binary.LittleEndian.Uint16(blocks[1].body) != LinkTypeRaw:
+			t.Fatalf("unexpected interface description block: %x", blocks[1].body)
+		}
+
+		// IPv4 + TCP
+		epb := blocks[2].body
+		ts := uint64(binary.LittleEndian.Uint32(epb[4:]))<<32 | uint64(binary.LittleEndian.Uint32(epb[8:]))
+		ip := epb[20 : 20+binary.LittleEndian.Uint32(epb[12:])]
+
+		switch {
+		case ts != 1750000000123456:
+			t.Fatalf("unexpected timestamp: %d", ts)
+		case len(ip) != 20+20+32:
+			t.Fatalf("unexpected packet length: %d", len(ip))
+		case checksum(ip[:20]) != 0:
+			t.Fatalf("invalid ip header checksum")
+		case !bytes.Equal(ip[40:], packets[0].Payload):
+			t.Fatalf("unexpected payload: %x", ip[40:])
+		case binary.LittleEndian.Uint32(epb[20+len(ip)+4:]) != epbFlagOutbound:
+			t.Fatalf("unexpected direction flags")
+		}
+
+		// IPv6 + UDP, with the payload padded to 32 bits
+		epb = blocks[3].body
+		ip = epb[20 : 20+binary.LittleEndian.Uint32(epb[12:])]
+
+		switch {
+		case ip[0]>>4 != 6 || ip[6] != ProtocolUDP:
+			t.Fatalf("unexpected ip header: %x", ip[:40])
+		case !bytes.Equal(ip[48:], packets[1].Payload):
+			t.Fatalf("unexpected payload: %x", ip[48:])
+		case binary.LittleEndian.Uint32(epb[20+len(pad(ip))+4:]) != epbFlagInbound:
+			t.Fatalf("unexpected direction flags")
+		}
+	})
+}
@@ -0,0 +1,416 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+)
+
+// More link types understood by the [Reader].
+const (
+	LinkTypeNull      = 0
+	LinkTypeEthernet  = 1
+	LinkTypeRawAlt    = 12
+	LinkTypeLoop      = 108
+	LinkTypeLinuxSLL  = 113
+	LinkTypeIPv4      = 228
+	LinkTypeIPv6      = 229
+	LinkTypeLinuxSLL2 = 276
+)
+
+// More pcapng block types understood by the [Reader].
+const (
+	blockPacket       = 0x00000002
+	blockSimplePacket = 0x00000003
+	optIfTsResol      = 9
+)
+
+// pcap file header magic numbers.
+const (
+	magicMicros = 0xa1b2c3d4
+	magicNanos  = 0xa1b23c4d
+)
+
+var (
+	ErrReadFailure = errors.New("pcap: failed to read capture")
+)
+
+// Reader reads TCP and UDP packets from a pcap or pcapng capture. The format is detected from the file header.
+//
+// Packets of other protocols, and packets with link types the reader doesn't understand, are skipped.
+type Reader struct {
+	r *bufio.Reader
+
+	ng         bool
+	order      binary.ByteOrder
+	linkType   uint32
+	resolution time.Duration
+
+	// pcapng interfaces of the current section
+	interfaces []iface
+}
+
+type iface struct {
+	linkType   uint32
+	resolution time.Duration
+}
+
+// NewReader starts reading the capture in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{r: bufio.NewReader(r)}
+
+	magic, err := pr.r.Peek(4)
+	if err != nil {
+		return nil, errors.Join(ErrReadFailure, err)
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic) == blockSectionHeader:
+		pr.ng = true
+	case binary.LittleEndian.Uint32(magic) == magicMicros, binary.LittleEndian.Uint32(magic) == magicNanos:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic) == magicMicros, binary.BigEndian.Uint32(magic) == magicNanos:
+		pr.order = binary.BigEndian
+	default:
+		return nil, errors.Join(ErrReadFailure, fmt.Errorf("unrecognized capture format [%x]", magic))
+	}
+
+	if !pr.ng {
+		hdr := make([]byte, 24)
+		if _, err := io.ReadFull(pr.r, hdr); err != nil {
+			return nil, errors.Join(ErrReadFailure, err)
+		}
+
+		pr.resolution = time.Microsecond
+		if pr.order.Uint32(hdr) == magicNanos {
+			pr.resolution = time.Nanosecond
+		}
+
+		pr.linkType = pr.order.Uint32(hdr[20:]) & 0x0fffffff
+	}
+
+	return pr, nil
+}
+
+// ReadPacket returns the next TCP or UDP packet of the capture, or [io.EOF] at the end of the capture.
+func (r *Reader) ReadPacket() (*Packet, error) {
+	for {
+		var (
+			p        *Packet
+			linkType uint32
+			data     []byte
+			err      error
+		)
+
+		if r.ng {
+			p, linkType, data, err = r.readBlock()
+		} else {
+			p, linkType, data, err = r.readRecord()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			continue
+		}
+
+		if ok := decodeLink(p, linkType, data); ok {
+			return p, nil
+		}
+	}
+}
+
+// Read the next record of a pcap capture.
+func (r *Reader) readRecord() (*Packet, uint32, []byte, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r.r, hdr); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, nil, io.EOF
+		}
+
+		return nil, 0, nil, errors.Join(ErrReadFailure, err)
+	}
+
+	length := r.order.Uint32(hdr[8:])
+	if length > 1<<18 {
+		return nil, 0, nil, errors.Join(ErrReadFailure, fmt.Errorf("illegal record length: %d", length))
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, 0, nil, errors.Join(ErrReadFailure, err)
+	}
+
+	ts := time.Unix(int64(r.order.Uint32(hdr)), int64(r.order.Uint32(hdr[4:]))*int64(r.resolution))
+
+	return &Packet{Timestamp: ts}, r.linkType, data, nil
+}
+
+// Read the next block of a pcapng capture. Blocks other than packet blocks are consumed and return a nil packet.
+func (r *Reader) readBlock() (*Packet, uint32, []byte, error) {
+	hdr := make([]byte, 8)
+	if _, err := io.ReadFull(r.r, hdr); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, nil, io.EOF
+		}
+
+		return nil, 0, nil, errors.Join(ErrReadFailure, err)
+	}
+
+	// the byte order is defined by each section header
+	if binary.LittleEndian.Uint32(hdr) == blockSectionHeader {
+		bom, err := r.r.Peek(4)
+		if err != nil {
+			return nil, 0, nil, errors.Join(ErrReadFailure, err)
+		}
+
+		r.order = binary.LittleEndian
+		if binary.BigEndian.Uint32(bom) == blockByteOrderMagic {
+			r.order = binary.BigEndian
+		}
+
+		r.interfaces = nil
+	}
+
+	if r.order == nil {
+		return nil, 0, nil, errors.Join(ErrReadFailure, fmt.Errorf("missing section header block"))
+	}
+
+	blockType, length := r.order.Uint32(hdr), r.order.Uint32(hdr[4:])
+	if length < 12 || length%4 != 0 || length > 1<<20 {
+		return nil, 0, nil, errors.Join(ErrReadFailure, fmt.Errorf("illegal block length: %d", length))
+	}
+
+	body := make([]byte, length-8)
+	if _, err := io.ReadFull(r.r, body); err != nil {
+		return nil, 0, nil, errors.Join(ErrReadFailure, err)
+	}
+
+	body = body[:len(body)-4]
+
+	switch blockType {
+	case blockInterfaceDesc:
+		if len(body) < 8 {
+			return nil, 0, nil, errors.Join(ErrReadFailure, fmt.Errorf("truncated interface description block"))
+		}
+
+		ifc := iface{linkType: uint32(r.order.Uint16(body)), resolution: time.Microsecond}
+
+		if value, ok := r.option(body[8:], optIfTsResol); ok && len(value) > 0 {
+			ifc.resolution = tsResolution(value[0])
+		}
+
+		r.interfaces = append(r.interfaces, ifc)
+
+	case blockEnhancedPacket, blockPacket:
+		if len(body) < 20 {
+			return nil, 0, nil, errors.Join(ErrReadFailure, fmt.Errorf("truncated packet block"))
+		}
+
+		id := r.order.Uint32(body)
+		if blockType == blockPacket {
+			id = uint32(r.order.Uint16(body))
+		}
+		if int(id) >= len(r.interfaces) {
+			return nil, 0, nil, errors.Join(ErrReadFailure, fmt.Errorf("unknown interface: %d", id))
+		}
+
+		ifc := r.interfaces[id]
+
+		captured := r.order.Uint32(body[12:])
+		if int(captured) > len(body)-20 {
+			return nil, 0, nil, errors.Join(ErrReadFailure, fmt.Errorf("truncated packet block"))
+		}
+
+		ts := uint64(r.order.Uint32(body[4:]))<<32 | uint64(r.order.Uint32(body[8:]))
+		p := &Packet{Timestamp: timestamp(ts, ifc.resolution)}
+
+		if blockType == blockEnhancedPacket {
+			if flags, ok := r.option(body[20+len(pad(body[20:20+captured])):], optEpbFlags); ok && len(flags) == 4 {
+				p.Inbound = r.order.Uint32(flags)&0x3 == epbFlagInbound
+			}
+		}
+
+		return p, ifc.linkType, body[20 : 20+captured], nil
+
+	case blockSimplePacket:
+		if len(r.interfaces) == 0 || len(body) < 4 {
+			return nil, 0, nil, errors.Join(ErrReadFailure, fmt.Errorf("illegal simple packet block"))
+		}
+
+		data := body[4:]
+		if length := r.order.Uint32(body); int(length) < len(data) {
+			data = data[:length]
+		}
+
+		return &Packet{}, r.interfaces[0].linkType, data, nil
+	}
+
+	return nil, 0, nil, nil
+}
+
+// Find the value of an option in a pcapng option list.
+func (r *Reader) option(opts []byte, code uint16) ([]byte, bool) {
+	for len(opts) >= 4 {
+		c, length := r.order.Uint16(opts), int(r.order.Uint16(opts[2:]))
+		if c == optEndOfOpt || 4+length > len(opts) {
+			return nil, false
+		}
+
+		if c == code {
+			return opts[4 : 4+length], true
+		}
+
+		opts = opts[4+length+(4-length%4)%4:]
+	}
+
+	return nil, false
+}
+
+// Decode the if_tsresol option: a power of 10 (or of 2, if the most significant bit is set) of fractions of a second.
+func tsResolution(v byte) time.Duration {
+	var units uint64 = 1
+
+	for range v & 0x7f {
+		if v&0x80 != 0 {
+			units *= 2
+		} else {
+			units *= 10
+		}
+	}
+
+	if units > uint64(time.Second) {
+		return time.Nanosecond
+	}
+
+	return time.Duration(uint64(time.Second) / units)
+}
+
+func timestamp(ts uint64, resolution time.Duration) time.Time {
+	return time.Unix(0, 0).Add(time.Duration(ts) * resolution)
+}
+
+// Decode the link layer, IP and TCP/UDP headers of the packet data into p. Returns false if the packet isn't a TCP or
+// UDP packet.
+func decodeLink(p *Packet, linkType uint32, data []byte) bool {
+	var ethertype uint16
+
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return false
+		}
+
+		ethertype, data = binary.BigEndian.Uint16(data[12:]), data[14:]
+
+		// skip VLAN tags
+		for (ethertype == 0x8100 || ethertype == 0x88a8) && len(data) >= 4 {
+			ethertype, data = binary.BigEndian.Uint16(data[2:]), data[4:]
+		}
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return false
+		}
+
+		ethertype, data = binary.BigEndian.Uint16(data[14:]), data[16:]
+	case LinkTypeLinuxSLL2:
+		if len(data) < 20 {
+			return false
+		}
+
+		ethertype, data = binary.BigEndian.Uint16(data), data[20:]
+	case LinkTypeNull, LinkTypeLoop:
+		// 4-byte address family in host byte order, the IP version is detected below
+		if len(data) < 4 {
+			return false
+		}
+
+		data = data[4:]
+	case LinkTypeRaw, LinkTypeRawAlt, LinkTypeIPv4, LinkTypeIPv6:
+	default:
+		return false
+	}
+
+	if ethertype != 0 && ethertype != 0x0800 && ethertype != 0x86dd {
+		return false
+	}
+
+	return decodeIP(p, data)
+}
+
+// Decode the IP and TCP/UDP headers of the packet data into p.
+func decodeIP(p *Packet, data []byte) bool {
+	if len(data) < 1 {
+		return false
+	}
+
+	var (
+		src, dst netip.Addr
+		protocol byte
+	)
+
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return false
+		}
+
+		ihl := int(data[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(data[2:]))
+
+		// fragments aren't reassembled
+		if ihl < 20 || total < ihl || len(data) < ihl || binary.BigEndian.Uint16(data[6:])&0x3fff != 0 {
+			return false
+		}
+
+		src, dst = netip.AddrFrom4([4]byte(data[12:16])), netip.AddrFrom4([4]byte(data[16:20]))
+		protocol = data[9]
+		data = data[ihl:min(total, len(data))]
+	case 6:
+		if len(data) < 40 {
+			return false
+		}
+
+		total := 40 + int(binary.BigEndian.Uint16(data[4:]))
+
+		// extension headers aren't supported
+		src, dst = netip.AddrFrom16([16]byte(data[8:24])), netip.AddrFrom16([16]byte(data[24:40]))
+		protocol = data[6]
+		data = data[40:min(total, len(data))]
+	default:
+		return false
+	}
+
+	switch protocol {
+	case ProtocolTCP:
+		if len(data) < 20 {
+			return false
+		}
+
+		offset := int(data[12]>>4) * 4
+		if offset < 20 || offset > len(data) {
+			return false
+		}
+
+		p.Seq, p.Ack, p.Flags = binary.BigEndian.Uint32(data[4:]), binary.BigEndian.Uint32(data[8:]), data[13]
+		p.Payload = data[offset:]
+	case ProtocolUDP:
+		if len(data) < 8 {
+			return false
+		}
+
+		p.Payload = data[8:]
+	default:
+		return false
+	}
+
+	p.Protocol = int(protocol)
+	p.Src = netip.AddrPortFrom(src, binary.BigEndian.Uint16(data))
+	p.Dst = netip.AddrPortFrom(dst, binary.BigEndian.Uint16(data[2:]))
+
+	return true
+}
@@ -0,0 +1,143 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	t.Run("should read back pcapng captures written by the writer", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		w, err := NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		written := []Packet{
+			{
+				Timestamp: time.UnixMicro(1750000000123456),
+				Protocol:  ProtocolTCP,
+				Src:       netip.MustParseAddrPort("192.0.2.1:50000"),
+				Dst:       netip.MustParseAddrPort("192.0.2.54:14889"),
+				Seq:       1,
+				Ack:       1,
+				Payload:   []byte("68001068107731583078000000009816"),
+			},
+			{
+				Timestamp: time.UnixMicro(1750000000223456),
+				Protocol:  ProtocolUDP,
+				Src:       netip.MustParseAddrPort("[2001:db8::54]:14889"),
+				Dst:       netip.MustParseAddrPort("[2001:db8::1]:50000"),
+				Inbound:   true,
+				Payload:   []byte{0x68, 0x00, 0x56},
+			},
+		}
+
+		for _, p := range written {
+			if err := w.WritePacket(&p); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, expected := range written {
+			p, err := r.ReadPacket()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			switch {
+			case !p.Timestamp.Equal(expected.Timestamp):
+				t.Fatalf("unexpected timestamp: %v", p.Timestamp)
+			case p.Protocol != expected.Protocol || p.Src != expected.Src || p.Dst != expected.Dst:
+				t.Fatalf("unexpected endpoints: %d %v -> %v", p.Protocol, p.Src, p.Dst)
+			case p.Seq != expected.Seq || p.Inbound != expected.Inbound:
+				t.Fatalf("unexpected seq or direction: %d %v", p.Seq, p.Inbound)
+			case !bytes.Equal(p.Payload, expected.Payload):
+				t.Fatalf("unexpected payload: %x", p.Payload)
+			}
+		}
+
+		if _, err := r.ReadPacket(); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected EOF but was: %v", err)
+		}
+	})
+
+	t.Run("should read pcap captures of ethernet frames", func(t *testing.T) {
+		p := Packet{
+			Protocol: ProtocolTCP,
+			Src:      netip.MustParseAddrPort("192.0.2.54:14889"),
+			Dst:      netip.MustParseAddrPort("192.0.2.1:50000"),
+			Seq:      42,
+			Payload:  []byte{0x68, 0x00, 0x10},
+		}
+
+		ip, err := p.marshalIP()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// big endian pcap with nanosecond timestamps
+		capture := binary.BigEndian.AppendUint32(nil, magicNanos)
+		capture = binary.BigEndian.AppendUint16(capture, 2)
+		capture = binary.BigEndian.AppendUint16(capture, 4)
+		capture = append(capture, make([]byte, 8)...)
+		capture = binary.BigEndian.AppendUint32(capture, 65535)
+		capture = binary.BigEndian.AppendUint32(capture, LinkTypeEthernet)
+
+		// an ARP frame, which is skipped
+		capture = appendRecord(capture, append(make([]byte, 12), 0x08, 0x06, 0x00))
+
+		// a VLAN tagged IPv4 frame
+		frame := append(make([]byte, 12), 0x81, 0x00, 0x00, 0x01, 0x08, 0x00)
+		capture = appendRecord(capture, append(frame, ip...))
+
+		r, err := NewReader(bytes.NewReader(capture))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		result, err := r.ReadPacket()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		switch {
+		case !result.Timestamp.Equal(time.Unix(1750000000, 500)):
+			t.Fatalf("unexpected timestamp: %v", result.Timestamp)
+		case result.Src != p.Src || result.Dst != p.Dst || result.Seq != 42:
+			t.Fatalf("unexpected packet: %v -> %v (%d)", result.Src, result.Dst, result.Seq)
+		case !bytes.Equal(result.Payload, p.Payload):
+			t.Fatalf("unexpected payload: %x", result.Payload)
+		}
+
+		if _, err := r.ReadPacket(); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected EOF but was: %v", err)
+		}
+	})
+
+	t.Run("should reject unrecognized formats", func(t *testing.T) {
+		if _, err := NewReader(bytes.NewReader([]byte("not a capture"))); !errors.Is(err, ErrReadFailure) {
+			t.Fatalf("expected read failure but was: %v", err)
+		}
+	})
+}
+
+func appendRecord(capture, data []byte) []byte {
+	capture = binary.BigEndian.AppendUint32(capture, 1750000000)
+	capture = binary.BigEndian.AppendUint32(capture, 500)
+	capture = binary.BigEndian.AppendUint32(capture, uint32(len(data)))
+	capture = binary.BigEndian.AppendUint32(capture, uint32(len(data)))
+
+	return append(capture, data...)
+}
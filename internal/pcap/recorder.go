@@ -0,0 +1,226 @@
+package pcap
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layout of the timestamp in the names of rotated capture files.
+const rotatedLayout = "20060102T150405.000000"
+
+// Recorder records the traffic of network connections to a pcapng file, rotating the file once it reaches a maximum
+// size. Recorder is safe for concurrent use.
+//
+// Rotated files are renamed with the time of rotation (e.g. 'capture-20250601T120000.000000.pcapng' for
+// 'capture.pcapng'). If the file exists when recording starts, a new section is appended to it.
+type Recorder struct {
+	// Path of the capture file.
+	Path string
+
+	// Size in bytes after which the capture file is rotated. Zero disables rotation.
+	MaxSize int64
+
+	// Number of rotated files to keep. Zero keeps all rotated files.
+	MaxFiles int
+
+	// If set, OnError is invoked when a packet can't be recorded.
+	OnError func(error)
+
+	mux    sync.Mutex
+	file   *os.File
+	writer *Writer
+	seq    map[[2]netip.AddrPort]uint32
+}
+
+// Record data received from (inbound) or sent to (outbound) the remote end of the connection.
+func (r *Recorder) Record(conn net.Conn, inbound bool, data []byte) {
+	p := Packet{
+		Timestamp: time.Now(),
+		Inbound:   inbound,
+		Payload:   data,
+	}
+
+	local, remote := addrPort(conn.LocalAddr()), addrPort(conn.RemoteAddr())
+
+	p.Src, p.Dst = local, remote
+	if inbound {
+		p.Src, p.Dst = remote, local
+	}
+
+	p.Protocol = ProtocolTCP
+	if _, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		p.Protocol = ProtocolUDP
+	}
+
+	if err := r.WritePacket(&p); err != nil && r.OnError != nil {
+		r.OnError(err)
+	}
+}
+
+// WritePacket writes the packet to the capture file, rotating the file if needed. For TCP packets, sequence and
+// acknowledgement numbers are filled in from the amount of data recorded in each direction.
+func (r *Recorder) WritePacket(p *Packet) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.writer != nil && r.MaxSize > 0 && r.writer.Size() >= r.MaxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if r.writer == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+
+	if p.Protocol == ProtocolTCP {
+		if r.seq == nil {
+			r.seq = map[[2]netip.AddrPort]uint32{}
+		}
+
+		flow, reverse := [2]netip.AddrPort{p.Src, p.Dst}, [2]netip.AddrPort{p.Dst, p.Src}
+
+		p.Seq, p.Ack = max(r.seq[flow], 1), max(r.seq[reverse], 1)
+		r.seq[flow] = p.Seq + uint32(len(p.Payload))
+	}
+
+	return r.writer.WritePacket(p)
+}
+
+// Close the capture file.
+func (r *Recorder) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file, r.writer = nil, nil
+
+	return err
+}
+
+func (r *Recorder) open() error {
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Join(ErrWriteFailure, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Join(ErrWriteFailure, err)
+	}
+
+	w, err := NewWriter(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.n += info.Size()
+
+	r.file, r.writer = f, w
+
+	return nil
+}
+
+// Rename the current capture file and remove the oldest rotated files beyond 'MaxFiles'.
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return errors.Join(ErrWriteFailure, err)
+	}
+
+	r.file, r.writer = nil, nil
+
+	ext := filepath.Ext(r.Path)
+	base := strings.TrimSuffix(r.Path, ext)
+
+	// rotated files are named after the time of rotation, which must be unique
+	ts := time.Now()
+	rotated := base + "-" + ts.Format(rotatedLayout) + ext
+
+	for _, err := os.Stat(rotated); err == nil; _, err = os.Stat(rotated) {
+		ts = ts.Add(time.Microsecond)
+		rotated = base + "-" + ts.Format(rotatedLayout) + ext
+	}
+
+	if err := os.Rename(r.Path, rotated); err != nil {
+		return errors.Join(ErrWriteFailure, err)
+	}
+
+	if r.MaxFiles <= 0 {
+		return nil
+	}
+
+	matches, err := r.rotated()
+	if err != nil {
+		return errors.Join(ErrWriteFailure, err)
+	}
+
+	for len(matches) > r.MaxFiles {
+		if err := os.Remove(matches[0]); err != nil {
+			return errors.Join(ErrWriteFailure, err)
+		}
+
+		matches = matches[1:]
+	}
+
+	return nil
+}
+
+// List the files rotated by the recorder, oldest first. Other files next to the capture file (e.g.
+// 'capture-notes.pcapng') are left alone, since their names don't carry a timestamp.
+func (r *Recorder) rotated() ([]string, error) {
+	ext := filepath.Ext(r.Path)
+	prefix := strings.TrimSuffix(filepath.Base(r.Path), ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(r.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	var rotated []string
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if _, err := time.Parse(rotatedLayout, ts); err != nil {
+			continue
+		}
+
+		rotated = append(rotated, filepath.Join(filepath.Dir(r.Path), name))
+	}
+
+	// timestamps sort lexically
+	slices.Sort(rotated)
+
+	return rotated, nil
+}
+
+func addrPort(addr net.Addr) netip.AddrPort {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.AddrPort()
+	case *net.UDPAddr:
+		return a.AddrPort()
+	default:
+		ap, _ := netip.ParseAddrPort(addr.String())
+		return ap
+	}
+}
@@ -0,0 +1,110 @@
+package pcap
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Run("should rotate capture files and keep the most recent", func(t *testing.T) {
+		dir := t.TempDir()
+
+		rec := &Recorder{
+			Path:     filepath.Join(dir, "capture.pcapng"),
+			MaxSize:  512,
+			MaxFiles: 2,
+			OnError: func(err error) {
+				t.Fatalf("unexpected error: %v", err)
+			},
+		}
+
+		defer rec.Close()
+
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		conn := &fakeConn{Conn: client}
+
+		for i := range 40 {
+			rec.Record(conn, i%2 == 0, make([]byte, 64))
+		}
+
+		rotated, _ := filepath.Glob(filepath.Join(dir, "capture-*.pcapng"))
+		if len(rotated) != 2 {
+			t.Fatalf("unexpected rotated files: %v", rotated)
+		}
+
+		for _, path := range append(rotated, rec.Path) {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Size() > rec.MaxSize+256 {
+				t.Fatalf("unexpected size of %s: %d", path, info.Size())
+			}
+		}
+	})
+
+	t.Run("should only remove rotated capture files", func(t *testing.T) {
+		dir := t.TempDir()
+
+		unrelated := []string{
+			filepath.Join(dir, "capture-notes.pcapng"),
+			filepath.Join(dir, "capture-20250601.pcapng"),
+		}
+
+		for _, path := range unrelated {
+			if err := os.WriteFile(path, []byte("keep me"), 0o644); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		rec := &Recorder{
+			Path:     filepath.Join(dir, "capture.pcapng"),
+			MaxSize:  512,
+			MaxFiles: 1,
+			OnError: func(err error) {
+				t.Fatalf("unexpected error: %v", err)
+			},
+		}
+
+		defer rec.Close()
+
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		conn := &fakeConn{Conn: client}
+
+		for i := range 40 {
+			rec.Record(conn, i%2 == 0, make([]byte, 64))
+		}
+
+		for _, path := range unrelated {
+			if _, err := os.Stat(path); err != nil {
+				t.Fatalf("unrelated file removed: %v", err)
+			}
+		}
+
+		rotated, _ := filepath.Glob(filepath.Join(dir, "capture-*.pcapng"))
+		if len(rotated) != len(unrelated)+1 {
+			t.Fatalf("unexpected files: %v", rotated)
+		}
+	})
+}
+
+// A connection with TCP endpoints.
+type fakeConn struct {
+	net.Conn
+}
+
+func (c *fakeConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50000}
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 54), Port: 14889}
+}
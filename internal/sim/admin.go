@@ -0,0 +1,163 @@
+package sim
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// AdminUI stands in for the admin web interface of the inverter's Wi-Fi module, with the pages and form fields of
+// 'admin.DefaultLayout'. Like that layout, the pages are unverified: they aren't modelled after captures of a real Wi-Fi
+// module. Settings submitted through the forms are saved, and read back by subsequent requests.
+//
+// AdminUI is an [http.Handler], so it can be served with [net/http/httptest.NewServer] in tests.
+type AdminUI struct {
+	// Credentials of the admin interface. If empty, requests aren't authenticated.
+	Username string
+	Password string
+
+	// Wi-Fi network the inverter joins, and its password.
+	SSID      string
+	Key       string
+	IPAddress string
+
+	// Work mode (e.g. 'TCP-Server') and port.
+	WorkMode string
+	Port     uint16
+
+	// Signal strength of the Wi-Fi network in dBm, and firmware build of the Wi-Fi module, shown on the 'System' page.
+	RSSI     int
+	Firmware string
+
+	mux      sync.Mutex
+	restarts int
+}
+
+var adminPages = template.Must(template.New("").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.}}</title></head>
+<body><h1>{{.}}</h1>{{end}}
+{{define "footer"}}</body></html>{{end}}
+
+{{define "/system.html"}}{{template "header" "System"}}
+<table>
+<tr><td>STA Mode</td><td><input type="text" name="sta_ssid" value="{{.SSID}}" readonly></td></tr>
+<tr><td>IP Address</td><td><input type="text" name="sta_ip" value="{{.IPAddress}}" readonly></td></tr>
+<tr><td>Signal Strength</td><td><input type="text" name="sta_rssi" value="{{if .RSSI}}{{.RSSI}} dBm{{end}}" readonly></td></tr>
+<tr><td>Firmware Version</td><td><input type="text" name="fw_version" value="{{.Firmware}}" readonly></td></tr>
+</table>
+{{template "footer"}}{{end}}
+
+{{define "/sta.html"}}{{template "header" "STA Settings"}}
+<form method="post" action="/sta.html">
+<label>SSID <input type="text" name="sta_ssid" value="{{.SSID}}"></label>
+<label>Password <input type="password" name="sta_key" value="{{.Key}}"></label>
+<input type="submit" name="save" value="Save">
+</form>
+{{template "footer"}}{{end}}
+
+{{define "/network.html"}}{{template "header" "Network Parameter Settings"}}
+<form method="post" action="/network.html">
+<select name="work_mode">
+{{range .WorkModes}}<option value="{{.}}"{{if eq . $.WorkMode}} selected{{end}}>{{.}}</option>
+{{end}}</select>
+<input type="submit" name="save" value="Save">
+</form>
+{{template "footer"}}{{end}}
+
+{{define "/other.html"}}{{template "header" "Other Settings"}}
+<form method="post" action="/other.html">
+<label>Port ID <input type="text" name="port_id" value="{{.Port}}"></label>
+<input type="submit" name="save" value="Save">
+</form>
+{{template "footer"}}{{end}}
+
+{{define "/restart.html"}}{{template "header" "Restart"}}
+<p>Restarting...</p>
+{{template "footer"}}{{end}}
+`))
+
+// Work modes offered by the 'Network Parameter Settings' page.
+var adminWorkModes = []string{"TCP-Server", "TCP-Client", "UDP"}
+
+func (ui *AdminUI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if ui.Username != "" || ui.Password != "" {
+		username, password, ok := req.BasicAuth()
+		if !ok || username != ui.Username || password != ui.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	page := req.URL.Path
+	if page == "/" {
+		page = "/system.html"
+	}
+
+	if adminPages.Lookup(page) == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := req.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := ui.save(page, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ui.mux.Lock()
+	defer ui.mux.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	adminPages.ExecuteTemplate(w, page, struct {
+		*AdminUI
+		WorkModes []string
+	}{ui, adminWorkModes})
+}
+
+// Save the form submitted to the page.
+func (ui *AdminUI) save(page string, req *http.Request) error {
+	ui.mux.Lock()
+	defer ui.mux.Unlock()
+
+	switch page {
+	case "/sta.html":
+		ui.SSID = req.PostForm.Get("sta_ssid")
+		ui.Key = req.PostForm.Get("sta_key")
+	case "/network.html":
+		ui.WorkMode = req.PostForm.Get("work_mode")
+	case "/other.html":
+		port, err := strconv.ParseUint(req.PostForm.Get("port_id"), 10, 16)
+		if err != nil {
+			return err
+		}
+
+		ui.Port = uint16(port)
+	case "/restart.html":
+		ui.restarts++
+	}
+
+	return nil
+}
+
+// Restarts returns the number of times the Wi-Fi module was restarted.
+func (ui *AdminUI) Restarts() int {
+	ui.mux.Lock()
+	defer ui.mux.Unlock()
+
+	return ui.restarts
+}
@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Default time of sunrise, as a time of day.
+	DefaultSunrise = 6 * time.Hour
+
+	// Default time of sunset, as a time of day.
+	DefaultSunset = 20 * time.Hour
+)
+
+// Curve describes the power generated by each module over the course of a day: zero at night, rising after sunrise
+// to a peak at solar noon, and falling towards sunset, with some variability from passing clouds.
+type Curve struct {
+	// Peak output power (AC) of a module, in W. Defaults to DefaultPeakPower.
+	PeakPower float64
+
+	// Time of day of sunrise and sunset. Default to DefaultSunrise and DefaultSunset.
+	Sunrise time.Duration
+	Sunset  time.Duration
+
+	// Fraction of power lost to passing clouds, at most (0 for a clear sky).
+	Clouds float64
+}
+
+// Power returns the output power (AC) of a module at time t, in W. Variability from clouds is derived from t, so the
+// curve is deterministic.
+func (c *Curve) Power(t time.Time) float64 {
+	x := c.daylight(t)
+	if x <= 0 || x >= 1 {
+		return 0
+	}
+
+	// a bell shape, flat at sunrise and sunset
+	power := c.peakPower() * math.Pow(math.Sin(math.Pi*x), 2)
+
+	// clouds pass by over a few minutes
+	minutes := float64(t.Unix()) / 60
+	clouds := (math.Sin(minutes/7) + math.Sin(minutes/3+1)) / 4 // [-0.5, 0.5]
+
+	return power * (1 - c.Clouds*(clouds+0.5))
+}
+
+// Daylight reports whether the sun is up at time t.
+func (c *Curve) Daylight(t time.Time) bool {
+	x := c.daylight(t)
+	return x > 0 && x < 1
+}
+
+// Position of t between sunrise (0) and sunset (1).
+func (c *Curve) daylight(t time.Time) float64 {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	tod := t.Sub(midnight)
+
+	sunrise, sunset := c.Sunrise, c.Sunset
+	if sunrise == 0 && sunset == 0 {
+		sunrise, sunset = DefaultSunrise, DefaultSunset
+	}
+
+	return float64(tod-sunrise) / float64(sunset-sunrise)
+}
+
+func (c *Curve) peakPower() float64 {
+	if c.PeakPower == 0 {
+		return DefaultPeakPower
+	}
+
+	return c.PeakPower
+}
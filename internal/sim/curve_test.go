@@ -0,0 +1,53 @@
+package sim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCurve(t *testing.T) {
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should generate no power at night", func(t *testing.T) {
+		var curve Curve
+
+		for _, hour := range []int{0, 3, 5, 20, 23} {
+			if power := curve.Power(day.Add(time.Duration(hour) * time.Hour)); power != 0 {
+				t.Fatalf("unexpected power at %02d:00: %f", hour, power)
+			}
+		}
+	})
+
+	t.Run("should peak at solar noon", func(t *testing.T) {
+		curve := Curve{PeakPower: 400}
+
+		var (
+			peak     float64
+			peakHour int
+		)
+
+		for hour := range 24 {
+			power := curve.Power(day.Add(time.Duration(hour) * time.Hour))
+			if power > peak {
+				peak, peakHour = power, hour
+			}
+		}
+
+		if peakHour != 13 || peak > 400 || peak < 390 {
+			t.Fatalf("unexpected peak: %fW at %02d:00", peak, peakHour)
+		}
+	})
+
+	t.Run("should lose power to clouds", func(t *testing.T) {
+		clear := Curve{}
+		cloudy := Curve{Clouds: 0.5}
+
+		for minute := range 60 {
+			tm := day.Add(12*time.Hour + time.Duration(minute)*time.Minute)
+
+			if c, p := cloudy.Power(tm), clear.Power(tm); c > p || c < p/2 {
+				t.Fatalf("unexpected power with clouds: %f (clear sky %f)", c, p)
+			}
+		}
+	})
+}
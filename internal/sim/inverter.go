@@ -0,0 +1,623 @@
+// Package sim simulates the inverter side of the protocol, to test clients, dashboards and integrations without
+// hardware (or at night).
+package sim
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+const (
+	// Default number of inverter modules.
+	DefaultModules = 2
+
+	// Default interval between status frames pushed to clients.
+	DefaultInterval = time.Minute
+
+	// Default time allowed for clients to acknowledge a status frame before the inverter hangs up.
+	DefaultAckTimeout = 10 * time.Second
+
+	// Default peak output power (AC) of a module, in W.
+	DefaultPeakPower = 300.0
+
+	// Default duration of standby periods injected with Faults.Standby.
+	DefaultStandbyDuration = 5 * time.Minute
+)
+
+var (
+	ErrListen = errors.New("sim: failed to listen for clients")
+)
+
+// Length of the largest message clients send: polls and acks carry the inverter serial number and padding.
+const maxMessageLen = types.FrameMinLen + 8
+
+// Inverter simulates an inverter in 'TCP-Server' mode: clients connect to it, and it pushes status frames periodically
+// and in response to polls. Like real inverters, it hangs up on clients which don't acknowledge status frames, and
+// goes into standby (refusing clients) while the sun is down.
+//
+// Status frames report the output of a daily power curve, evaluated at the time of the inverter clock. Use 'Clock' to
+// simulate daylight at night, or to speed up the day.
+type Inverter struct {
+	// Serial number of the inverter (e.g. 31583078). Module IDs are derived from it.
+	Serial string
+
+	// Number of inverter modules. Defaults to DefaultModules.
+	Modules int
+
+	// Profile used to encode status frames. If nil, the profile is selected by the number of modules.
+	Profile *types.Profile
+
+	// Interval between status frames pushed to clients. Defaults to DefaultInterval.
+	Interval time.Duration
+
+	// Time allowed for clients to acknowledge a status frame. Defaults to DefaultAckTimeout.
+	AckTimeout time.Duration
+
+	// Power curve of each module.
+	Curve Curve
+
+	// Total energy of each module when the simulation starts, in kWh.
+	Energy float64
+
+	// Clock of the simulation, used to evaluate the power curve. Defaults to time.Now.
+	Clock func() time.Time
+
+	// If set, the inverter stays out of standby at night.
+	NoStandby bool
+
+	// Faults injected into the simulation.
+	Faults Faults
+
+	mux          sync.Mutex
+	rand         *rand.Rand
+	energy       []float64
+	last         time.Time
+	standbyUntil time.Time
+	conns        map[net.Conn]struct{}
+	stats        Stats
+}
+
+// Faults injected into the simulation, to test how clients cope with misbehaving inverters. Probabilities are in the
+// range [0, 1].
+type Faults struct {
+	// Probability of dropping the connection instead of sending a status frame.
+	Drop float64
+
+	// Probability of sending garbage bytes before a status frame.
+	Garbage float64
+
+	// Probability of splitting a status frame across two writes.
+	Split float64
+
+	// Probability of going into standby at each push interval, for StandbyDuration.
+	Standby float64
+
+	// Duration of injected standby periods. Defaults to DefaultStandbyDuration.
+	StandbyDuration time.Duration
+
+	// Seed for fault injection. If zero, faults are random.
+	Seed uint64
+}
+
+// Stats counts what happened during the simulation.
+type Stats struct {
+	// Connections accepted, including those refused in standby.
+	Connections int
+
+	// Polls and acks received for this inverter.
+	Polls int
+	Acks  int
+
+	// Status frames sent, including poll responses.
+	Frames int
+
+	// Connections dropped because a status frame wasn't acknowledged in time.
+	Hangups int
+}
+
+// Listen on the address and simulate the inverter for clients until the context is cancelled.
+func (inv *Inverter) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Join(ErrListen, err)
+	}
+
+	return inv.Serve(ctx, ln)
+}
+
+// Serve clients accepted on the listener until the context is cancelled. The listener is closed when Serve returns.
+func (inv *Inverter) Serve(ctx context.Context, ln net.Listener) error {
+	if _, err := inv.moduleIds(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return errors.Join(ErrListen, err)
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+
+			inv.serveConn(conn)
+		}()
+	}
+}
+
+// Stats returns what happened during the simulation so far.
+func (inv *Inverter) Stats() Stats {
+	inv.mux.Lock()
+	defer inv.mux.Unlock()
+
+	return inv.stats
+}
+
+// Standby reports whether the inverter is in standby, either because the sun is down or because of an injected fault.
+func (inv *Inverter) Standby() bool {
+	inv.mux.Lock()
+	defer inv.mux.Unlock()
+
+	return inv.standby()
+}
+
+// Status returns the status of the inverter at the time of its clock, accumulating the energy generated since the
+// previous status.
+func (inv *Inverter) Status() types.InverterStatus {
+	inv.mux.Lock()
+	defer inv.mux.Unlock()
+
+	return inv.status()
+}
+
+// Handle a client until it disconnects, or the inverter hangs up.
+func (inv *Inverter) serveConn(conn net.Conn) {
+	inv.mux.Lock()
+	inv.stats.Connections++
+
+	if inv.standby() {
+		inv.mux.Unlock()
+		return
+	}
+
+	if inv.conns == nil {
+		inv.conns = map[net.Conn]struct{}{}
+	}
+
+	inv.conns[conn] = struct{}{}
+	inv.mux.Unlock()
+
+	defer func() {
+		inv.mux.Lock()
+		defer inv.mux.Unlock()
+
+		delete(inv.conns, conn)
+	}()
+
+	session := &session{inv: inv, conn: conn}
+	defer session.close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// push status frames periodically, until the client disconnects
+	go func() {
+		ticker := time.NewTicker(inv.interval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if inv.injectStandby() {
+					return
+				}
+
+				session.sendStatus(types.CommandStatus)
+			}
+		}
+	}()
+
+	reader := bufio.NewReader(conn)
+
+	for {
+		frame, err := readMessage(reader)
+		if err != nil {
+			return
+		}
+
+		var sn string
+		if len(frame.Payload) >= 4 {
+			sn = hex.EncodeToString(frame.Payload[:4])
+		}
+
+		// like real inverters, only answer messages carrying our serial number
+		if frame.Control != types.FrameControl || sn != inv.Serial {
+			continue
+		}
+
+		switch frame.Command {
+		case types.CommandPoll:
+			inv.count(func(s *Stats) { s.Polls++ })
+			session.sendStatus(types.CommandPollResponse)
+		case types.CommandAck:
+			inv.count(func(s *Stats) { s.Acks++ })
+			session.ack()
+		}
+	}
+}
+
+// A client connection. Writes are serialized, since status frames are pushed and sent in response to polls
+// concurrently.
+type session struct {
+	inv  *Inverter
+	conn net.Conn
+
+	// guards writes to the connection
+	wmux sync.Mutex
+
+	// guards the acknowledgement timer
+	mux     sync.Mutex
+	pending *time.Timer
+	closed  bool
+}
+
+// Send a status frame with the given command, injecting faults. The client must acknowledge it in time, or the
+// connection is closed.
+func (s *session) sendStatus(command byte) {
+	s.wmux.Lock()
+	defer s.wmux.Unlock()
+
+	data, err := s.inv.frame(command)
+	if err != nil {
+		s.conn.Close()
+		return
+	}
+
+	if s.inv.chance(s.inv.Faults.Drop) {
+		s.conn.Close()
+		return
+	}
+
+	if s.inv.chance(s.inv.Faults.Garbage) {
+		s.conn.Write(s.inv.garbage())
+	}
+
+	if s.inv.chance(s.inv.Faults.Split) {
+		half := len(data) / 2
+		s.conn.Write(data[:half])
+		time.Sleep(50 * time.Millisecond)
+		data = data[half:]
+	}
+
+	if _, err := s.conn.Write(data); err != nil {
+		return
+	}
+
+	s.inv.count(func(s *Stats) { s.Frames++ })
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.pending == nil && !s.closed {
+		s.pending = time.AfterFunc(s.inv.ackTimeout(), func() {
+			s.inv.count(func(s *Stats) { s.Hangups++ })
+			s.conn.Close()
+		})
+	}
+}
+
+// Record that the client acknowledged the status frames sent so far.
+func (s *session) ack() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.pending != nil {
+		s.pending.Stop()
+		s.pending = nil
+	}
+}
+
+func (s *session) close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.closed = true
+
+	if s.pending != nil {
+		s.pending.Stop()
+	}
+}
+
+// Encode the current status as a frame with the given command (status or poll response).
+func (inv *Inverter) frame(command byte) ([]byte, error) {
+	inv.mux.Lock()
+	status := inv.status()
+	inv.mux.Unlock()
+
+	data, err := types.EncodeStatus(&status, inv.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var frame types.Frame
+	if err := frame.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+
+	frame.Command = command
+
+	return frame.MarshalBinary()
+}
+
+// Compute the status at the time of the clock. Must be called with the lock held.
+func (inv *Inverter) status() types.InverterStatus {
+	now := inv.now()
+	ids, _ := inv.moduleIds()
+
+	if inv.energy == nil {
+		inv.energy = make([]float64, len(ids))
+		for i := range inv.energy {
+			inv.energy[i] = inv.Energy
+		}
+
+		inv.last = now
+	}
+
+	elapsed := max(now.Sub(inv.last), 0)
+	inv.last = now
+
+	// slow variations of the grid, in minutes
+	minutes := float64(now.Unix()) / 60
+
+	status := types.InverterStatus{InverterId: inv.Serial}
+
+	for i, id := range ids {
+		// modules never perform exactly alike
+		power := inv.Curve.Power(now) * (1 - 0.03*float64(i))
+		load := power / inv.Curve.peakPower()
+
+		inv.energy[i] += power * elapsed.Hours() / 1000
+
+		module := types.InverterModuleStatus{
+			ModuleId:          id,
+			FirmwareVersion:   "112/121",
+			OutputPowerAC:     power,
+			TotalEnergy:       inv.energy[i],
+			Temperature:       15 + 25*load + 0.4*float64(i),
+			OutputVoltageAC:   231 + 3*math.Sin(minutes/11),
+			OutputFrequencyAC: 50 + 0.03*math.Sin(minutes/5),
+		}
+
+		if power > 0 {
+			module.InputVoltageDC = 30 + 8*load
+		}
+
+		status.Modules = append(status.Modules, module)
+	}
+
+	return status
+}
+
+// Must be called with the lock held.
+func (inv *Inverter) standby() bool {
+	if inv.now().Before(inv.standbyUntil) {
+		return true
+	}
+
+	return !inv.NoStandby && !inv.Curve.Daylight(inv.now())
+}
+
+// Enter a standby period if the fault triggers, or the sun went down, dropping all clients. Returns true if the
+// inverter is in standby.
+func (inv *Inverter) injectStandby() bool {
+	if inv.chance(inv.Faults.Standby) {
+		inv.mux.Lock()
+
+		duration := inv.Faults.StandbyDuration
+		if duration == 0 {
+			duration = DefaultStandbyDuration
+		}
+
+		inv.standbyUntil = inv.now().Add(duration)
+		inv.mux.Unlock()
+	}
+
+	inv.mux.Lock()
+	defer inv.mux.Unlock()
+
+	if !inv.standby() {
+		return false
+	}
+
+	for conn := range inv.conns {
+		conn.Close()
+	}
+
+	return true
+}
+
+// Derive module IDs from the serial number: the first module has the serial number of the inverter, and the others
+// count up from there.
+func (inv *Inverter) moduleIds() ([]string, error) {
+	sn, err := strconv.ParseUint(inv.Serial, 16, 32)
+	if err != nil || len(inv.Serial) != 8 {
+		return nil, fmt.Errorf("sim: illegal inverter serial number: %q", inv.Serial)
+	}
+
+	count := inv.Modules
+	if count == 0 {
+		count = DefaultModules
+	}
+
+	ids := make([]string, count)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%08x", uint32(sn)+uint32(i))
+	}
+
+	return ids, nil
+}
+
+func (inv *Inverter) count(f func(*Stats)) {
+	inv.mux.Lock()
+	defer inv.mux.Unlock()
+
+	f(&inv.stats)
+}
+
+// Roll the dice for a fault with probability p.
+func (inv *Inverter) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+
+	inv.mux.Lock()
+	defer inv.mux.Unlock()
+
+	return inv.random().Float64() < p
+}
+
+// A few random bytes, never containing the frame start token so that they can't be mistaken for a frame header.
+func (inv *Inverter) garbage() []byte {
+	inv.mux.Lock()
+	defer inv.mux.Unlock()
+
+	data := make([]byte, 1+inv.random().IntN(8))
+	for i := range data {
+		if data[i] = byte(inv.random().UintN(256)); data[i] == types.FrameStart {
+			data[i] = 0x00
+		}
+	}
+
+	return data
+}
+
+// Must be called with the lock held.
+func (inv *Inverter) random() *rand.Rand {
+	if inv.rand == nil {
+		seed := inv.Faults.Seed
+		if seed == 0 {
+			seed = rand.Uint64()
+		}
+
+		inv.rand = rand.New(rand.NewPCG(seed, seed))
+	}
+
+	return inv.rand
+}
+
+func (inv *Inverter) now() time.Time {
+	if inv.Clock == nil {
+		return time.Now()
+	}
+
+	return inv.Clock()
+}
+
+func (inv *Inverter) interval() time.Duration {
+	if inv.Interval == 0 {
+		return DefaultInterval
+	}
+
+	return inv.Interval
+}
+
+func (inv *Inverter) ackTimeout() time.Duration {
+	if inv.AckTimeout == 0 {
+		return DefaultAckTimeout
+	}
+
+	return inv.AckTimeout
+}
+
+// Read the next message sent by a client. Clients send polls and acks in their ASCII-hex form (see
+// [types.PollMessage]), but may also send them in binary form. Bytes which don't form a valid message are skipped.
+func readMessage(r *bufio.Reader) (types.Frame, error) {
+	var frame types.Frame
+
+	for {
+		first, err := r.Peek(1)
+		if err != nil {
+			return frame, err
+		}
+
+		// in ASCII-hex form, every byte is encoded as two characters
+		width := 1
+		if first[0] != types.FrameStart {
+			width = 2
+		}
+
+		header, err := peekFrame(r, types.FrameHeaderLen, width)
+		if err != nil {
+			r.Discard(1)
+			continue
+		}
+
+		// a bogus length would block until that many bytes arrive, so skip frames longer than any message
+		size, err := types.FrameLen(header)
+		if err != nil || size > maxMessageLen {
+			r.Discard(1)
+			continue
+		}
+
+		data, err := peekFrame(r, size, width)
+		if err == nil {
+			err = frame.UnmarshalBinary(data)
+		}
+		if err != nil {
+			r.Discard(1)
+			continue
+		}
+
+		r.Discard(size * width)
+
+		return frame, nil
+	}
+}
+
+// Peek n bytes from the reader, decoding them from ASCII-hex if width is 2.
+func peekFrame(r *bufio.Reader, n, width int) ([]byte, error) {
+	data, err := r.Peek(n * width)
+	if err != nil {
+		return nil, err
+	}
+
+	if width == 1 {
+		return data, nil
+	}
+
+	return hex.DecodeString(string(data))
+}
@@ -0,0 +1,213 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+// A clock stuck at noon, so that the inverter isn't in standby.
+func noon() time.Time {
+	return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+}
+
+// Connect to the simulated inverter, sending the given messages.
+func dial(t *testing.T, addr string, msgs ...[]byte) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	for _, msg := range msgs {
+		conn.Write(msg)
+	}
+
+	return conn
+}
+
+// Read status frames from the connection until it's closed or the deadline expires.
+func readStatus(t *testing.T, conn net.Conn, timeout time.Duration) ([]types.InverterStatus, []byte) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	data, _ := io.ReadAll(conn)
+
+	var statuses []types.InverterStatus
+
+	for rest := data; len(rest) > 0; {
+		i := bytes.IndexByte(rest, types.FrameStart)
+		if i < 0 {
+			break
+		}
+
+		rest = rest[i:]
+
+		size, err := types.FrameLen(rest)
+		if err != nil || size > len(rest) {
+			rest = rest[1:]
+			continue
+		}
+
+		var status types.InverterStatus
+		if err := status.UnmarshalBinary(rest[:size]); err != nil {
+			rest = rest[1:]
+			continue
+		}
+
+		statuses = append(statuses, status)
+		rest = rest[size:]
+	}
+
+	return statuses, data
+}
+
+func TestInverter(t *testing.T) {
+	t.Run("should answer polls with the status of the inverter", func(t *testing.T) {
+		server := NewServer(&Inverter{Serial: "31583078", Clock: noon, Energy: 12.5})
+		defer server.Close()
+
+		poll, _ := types.NewPollMessage("31583078")
+		other, _ := types.NewPollMessage("30587612")
+		ack, _ := types.NewAckMessage("31583078")
+
+		conn := dial(t, server.Addr, other, poll, ack)
+
+		statuses, data := readStatus(t, conn, 200*time.Millisecond)
+		if len(statuses) != 1 {
+			t.Fatalf("unexpected number of status frames: %x", data)
+		}
+
+		status := statuses[0]
+
+		switch {
+		case data[5] != types.CommandPollResponse:
+			t.Fatalf("unexpected command: 0x%02x", data[5])
+		case status.InverterId != "31583078":
+			t.Fatalf("unexpected inverter serial: %s", status.InverterId)
+		case len(status.Modules) != 2 || status.Modules[1].ModuleId != "31583079":
+			t.Fatalf("unexpected modules: %+v", status.Modules)
+		case status.Modules[0].OutputPowerAC < 0.9*DefaultPeakPower:
+			t.Fatalf("unexpected output power at noon: %f", status.Modules[0].OutputPowerAC)
+		case status.Modules[0].TotalEnergy < 12.5:
+			t.Fatalf("unexpected total energy: %f", status.Modules[0].TotalEnergy)
+		}
+
+		if stats := server.Inverter.Stats(); stats.Polls != 1 || stats.Acks != 1 || stats.Frames != 1 || stats.Hangups != 0 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("should skip frames with a bogus length without waiting for them", func(t *testing.T) {
+		server := NewServer(&Inverter{Serial: "31583078", Clock: noon})
+		defer server.Close()
+
+		poll, _ := types.NewPollMessage("31583078")
+		ack, _ := types.NewAckMessage("31583078")
+
+		// headers of frames of 511 bytes, in binary and ASCII-hex form
+		bogus := []byte{types.FrameStart, 0x01, 0xff, types.FrameStart, types.FrameControl, types.CommandPoll}
+
+		conn := dial(t, server.Addr, bogus, []byte(hex.EncodeToString(bogus)), poll, ack)
+
+		statuses, data := readStatus(t, conn, 200*time.Millisecond)
+		if len(statuses) != 1 || data[5] != types.CommandPollResponse {
+			t.Fatalf("unexpected status frames: %x", data)
+		}
+	})
+
+	t.Run("should push status frames and hang up without acks", func(t *testing.T) {
+		server := NewServer(&Inverter{
+			Serial:     "31583078",
+			Modules:    1,
+			Interval:   20 * time.Millisecond,
+			AckTimeout: 100 * time.Millisecond,
+			Clock:      noon,
+		})
+		defer server.Close()
+
+		conn := dial(t, server.Addr)
+
+		start := time.Now()
+		statuses, _ := readStatus(t, conn, time.Second)
+
+		switch {
+		case time.Since(start) > 500*time.Millisecond:
+			t.Fatalf("expected inverter to hang up")
+		case len(statuses) < 2:
+			t.Fatalf("unexpected number of status frames: %d", len(statuses))
+		case len(statuses[0].Modules) != 1:
+			t.Fatalf("unexpected number of modules: %d", len(statuses[0].Modules))
+		}
+
+		if stats := server.Inverter.Stats(); stats.Hangups != 1 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("should refuse clients in standby at night", func(t *testing.T) {
+		server := NewServer(&Inverter{
+			Serial: "31583078",
+			Clock: func() time.Time {
+				return time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+			},
+		})
+		defer server.Close()
+
+		poll, _ := types.NewPollMessage("31583078")
+		conn := dial(t, server.Addr, poll)
+
+		if _, data := readStatus(t, conn, time.Second); len(data) != 0 {
+			t.Fatalf("unexpected data: %x", data)
+		}
+		if !server.Inverter.Standby() {
+			t.Fatalf("expected inverter in standby")
+		}
+	})
+
+	t.Run("should inject garbage and split frames", func(t *testing.T) {
+		server := NewServer(&Inverter{
+			Serial: "31583078",
+			Clock:  noon,
+			Faults: Faults{Garbage: 1, Split: 1, Seed: 1},
+		})
+		defer server.Close()
+
+		poll, _ := types.NewPollMessage("31583078")
+		conn := dial(t, server.Addr, poll)
+
+		statuses, data := readStatus(t, conn, 200*time.Millisecond)
+
+		switch {
+		case len(statuses) != 1:
+			t.Fatalf("unexpected number of status frames: %x", data)
+		case data[0] == types.FrameStart:
+			t.Fatalf("expected garbage before frame: %x", data)
+		}
+	})
+
+	t.Run("should drop connections", func(t *testing.T) {
+		server := NewServer(&Inverter{
+			Serial: "31583078",
+			Clock:  noon,
+			Faults: Faults{Drop: 1},
+		})
+		defer server.Close()
+
+		poll, _ := types.NewPollMessage("31583078")
+		conn := dial(t, server.Addr, poll)
+
+		start := time.Now()
+		if _, data := readStatus(t, conn, time.Second); len(data) != 0 || time.Since(start) > 500*time.Millisecond {
+			t.Fatalf("expected connection to be dropped: %x", data)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Server is a simulated inverter listening on a random local port, for use in tests (like httptest.Server).
+type Server struct {
+	// Address of the simulated inverter (e.g. 127.0.0.1:40123).
+	Addr string
+
+	Inverter *Inverter
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewServer starts simulating the inverter on a random local port. The caller should call Close when finished.
+func NewServer(inv *Inverter) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("sim: failed to listen on a port: %v", err))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &Server{
+		Addr:     ln.Addr().String(),
+		Inverter: inv,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		inv.Serve(ctx, ln)
+	}()
+
+	return s
+}
+
+// Close stops the simulation, disconnecting all clients.
+func (s *Server) Close() {
+	s.cancel()
+	<-s.done
+}
@@ -1,29 +1,65 @@
 package types
 
 import (
-	"encoding/hex"
+	"errors"
 	"fmt"
 )
 
-var rawAckMessage = []byte{
-	0x36, 0x38, 0x30, 0x30, 0x31, 0x30, 0x36, 0x38, 0x31, 0x30, 0x35, 0x30,
-	0x33, 0x30, 0x35, 0x38, 0x32, 0x39, 0x33, 0x32, // inverter serial
-	0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x37, 0x38, 0x31, 0x36,
+var (
+	ErrAckMessageDecodeFailure = errors.New("decode: failed to decode evt ack message")
+)
+
+// AckMessage is sent to the inverter to acknowledge receipt of a status message.
+//
+// On the wire, ack messages are sent in their ASCII-hex form, produced by MarshalText. Like [Frame.MarshalBinary],
+// MarshalBinary produces the raw binary form. UnmarshalBinary accepts both forms, since both show up in captures.
+type AckMessage struct {
+	InverterId string
 }
 
+// NewAckMessage builds the (ASCII-hex encoded) message used to acknowledge a status message from the inverter with
+// serial sn.
 func NewAckMessage(sn string) ([]byte, error) {
-	id, err := hex.DecodeString(sn)
+	msg := AckMessage{InverterId: sn}
+
+	return msg.MarshalText()
+}
+
+func (m *AckMessage) MarshalBinary() ([]byte, error) {
+	frame, err := serialMessageFrame(CommandAck, m.InverterId)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame.MarshalBinary()
+}
+
+// MarshalText encodes the message in its ASCII-hex form, as sent on the wire.
+func (m *AckMessage) MarshalText() ([]byte, error) {
+	frame, err := serialMessageFrame(CommandAck, m.InverterId)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(sn) != 8 || len(id) != 4 {
-		return nil, fmt.Errorf("illegal inverter serial number: %s", sn)
+	return frame.MarshalText()
+}
+
+func (m *AckMessage) UnmarshalBinary(data []byte) error {
+	sn, err := unmarshalSerialMessage(CommandAck, data)
+	if err != nil {
+		return errors.Join(ErrAckMessageDecodeFailure, err)
 	}
 
-	msg := make([]byte, 32)
-	copy(msg, rawAckMessage)
-	copy(msg[12:20], []byte(sn))
+	m.InverterId = sn
+
+	return nil
+}
+
+// UnmarshalText decodes the message from its ASCII-hex form.
+func (m *AckMessage) UnmarshalText(text []byte) error {
+	if len(text) > 0 && text[0] == FrameStart {
+		return errors.Join(ErrAckMessageDecodeFailure, fmt.Errorf("not in ASCII-hex form"))
+	}
 
-	return msg, nil
+	return m.UnmarshalBinary(text)
 }
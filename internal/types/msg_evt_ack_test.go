@@ -1,6 +1,8 @@
 package types
 
 import (
+	"encoding/hex"
+	"errors"
 	"testing"
 )
 
@@ -22,14 +24,14 @@ func TestNewAckMessage(t *testing.T) {
 
 	t.Run("should format correctly", func(t *testing.T) {
 		sn := "31583078"
-		expectedHex := "68001068105031583078000000007816"
+		expectedHex := "68001068105031583078000000007116"
 
 		result, err := NewAckMessage(sn)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		if len(result) != len(rawPollMessage) {
+		if len(result) != 32 {
 			t.Fatalf("unexpected message length: %d", len(result))
 		}
 
@@ -39,3 +41,84 @@ func TestNewAckMessage(t *testing.T) {
 		}
 	})
 }
+
+func TestAckMessageMarshal(t *testing.T) {
+	t.Run("should encode the binary form with MarshalBinary, and the ASCII-hex form with MarshalText", func(t *testing.T) {
+		msg := AckMessage{InverterId: "31583078"}
+
+		data, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		text, err := msg.MarshalText()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(data) != 16 || data[0] != FrameStart {
+			t.Fatalf("unexpected binary form: %x", data)
+		}
+		if string(text) != hex.EncodeToString(data) {
+			t.Fatalf("unexpected ASCII-hex form: %s", text)
+		}
+
+		var result AckMessage
+		if err := result.UnmarshalText(text); err != nil || result != msg {
+			t.Fatalf("unexpected message: %+v (%v)", result, err)
+		}
+		if err := result.UnmarshalText(data); err == nil {
+			t.Fatalf("expected binary form to be rejected as text")
+		}
+	})
+}
+
+func TestAckMessageUnmarshalBinary(t *testing.T) {
+	t.Run("should round-trip encoded messages", func(t *testing.T) {
+		msg := AckMessage{InverterId: "31583078"}
+
+		data, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var result AckMessage
+		if err := result.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if result != msg {
+			t.Fatalf("unexpected message: %+v", result)
+		}
+	})
+
+	t.Run("should decode binary form", func(t *testing.T) {
+		data, _ := hex.DecodeString("68001068105031583078000000007116")
+
+		var result AckMessage
+		if err := result.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if result.InverterId != "31583078" {
+			t.Fatalf("unexpected inverter serial: %s", result.InverterId)
+		}
+	})
+
+	t.Run("should reject other messages", func(t *testing.T) {
+		illegal := []string{
+			"",
+			"68001068105031583078000000007117",
+			"6800106810503158307800000000ff16",
+			"68001068107731583078000000009816",
+			"68000e68105031583078000000",
+		}
+
+		for _, msg := range illegal {
+			var result AckMessage
+			if err := result.UnmarshalBinary([]byte(msg)); !errors.Is(err, ErrAckMessageDecodeFailure) {
+				t.Fatalf("expected decode failure for message %q but was: %v", msg, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package types
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FrameField is a single field of a frame, located by its offset in the frame. Fields are produced by [DissectFrame]
+// to explain the contents of a frame byte by byte, e.g. when troubleshooting or reverse engineering frames.
+type FrameField struct {
+	// Field name, e.g. 'checksum' or 'input_voltage_dc'.
+	Name string
+
+	// For fields of a module block of a status frame, the module number (starting at 1).
+	Module int `json:",omitempty"`
+
+	// Offset of the field, relative to the start of the frame.
+	Offset int
+
+	// Raw bytes of the field.
+	Data HexBytes
+
+	// Decoded value of the field, if it has one (e.g. '23.64V').
+	Value string `json:",omitempty"`
+
+	// Reason the field is invalid, if it is.
+	Err string `json:",omitempty"`
+}
+
+var fieldLabels = map[string]string{
+	"start":               "Start",
+	"length":              "Length",
+	"control":             "Control",
+	"command":             "Command",
+	"inverter_id":         "Inverter ID",
+	"padding":             "Padding",
+	"reserved":            "Reserved",
+	"payload":             "Payload",
+	"checksum":            "Checksum",
+	"end":                 "End",
+	"module_id":           "Module ID",
+	"firmware_version":    "Firmware Version",
+	"input_voltage_dc":    "DC Input Voltage",
+	"output_power_ac":     "AC Output Power",
+	"total_energy":        "Total Energy",
+	"temperature":         "Temperature",
+	"output_voltage_ac":   "AC Output Voltage",
+	"output_frequency_ac": "AC Output Frequency",
+}
+
+var fieldUnits = map[string]string{
+	"input_voltage_dc":    "V",
+	"output_power_ac":     "W",
+	"total_energy":        "kWh",
+	"temperature":         "C",
+	"output_voltage_ac":   "V",
+	"output_frequency_ac": "Hz",
+}
+
+// Label returns a human-readable name for the field, as used in the README (e.g. 'Module 1 DC Input Voltage').
+func (f *FrameField) Label() string {
+	label, ok := fieldLabels[f.Name]
+	if !ok {
+		label = f.Name
+	}
+
+	if f.Module > 0 {
+		return fmt.Sprintf("Module %d %s", f.Module, label)
+	}
+
+	return label
+}
+
+// Len returns the width of the field, in bytes.
+func (f *FrameField) Len() int {
+	return len(f.Data)
+}
+
+// DissectFrame splits a frame into its fields, ordered by offset. Unlike [Frame.UnmarshalBinary], the frame is
+// dissected even if it's invalid: each invalid field is flagged with the reason, and the returned error summarizes all
+// problems found. Truncated frames are dissected as far as the data allows, according to the length in their header;
+// fields cut short are flagged, and fields beyond the end of the data are left out.
+//
+// The payload of status frames is dissected with the given profile, or the profile detected from the frame length if
+// nil. The payload of frames with an unknown command is returned as a single field.
+func DissectFrame(data []byte, profile *Profile) ([]FrameField, error) {
+	d := dissector{data: data, size: max(len(data), FrameMinLen)}
+
+	if len(data) >= 3 {
+		size := int(binary.BigEndian.Uint16(data[1:3]))
+
+		switch {
+		case size > len(data):
+			d.size = max(size, FrameMinLen)
+			d.add("length", 0, 1, 2, fmt.Sprint(size), nil)
+		case size != len(data):
+			d.add("length", 0, 1, 2, fmt.Sprint(size), fmt.Errorf("frame is %d bytes", len(data)))
+		default:
+			d.add("length", 0, 1, 2, fmt.Sprint(size), nil)
+		}
+	} else {
+		d.add("length", 0, 1, 2, "", nil)
+	}
+
+	d.frame = data
+	if len(data) < d.size {
+		d.frame = make([]byte, d.size)
+		copy(d.frame, data)
+
+		d.errs = append(d.errs, fmt.Errorf("truncated frame: got %d of %d bytes", len(data), d.size))
+	}
+
+	d.marker("start", 0, FrameStart)
+	d.marker("start", 3, FrameStart)
+	d.add("control", 0, 4, 1, "", nil)
+
+	if len(data) < FrameHeaderLen {
+		d.add("command", 0, 5, 1, "", nil)
+		return d.result()
+	}
+
+	d.add("command", 0, 5, 1, commandName(data[4], data[5]), nil)
+
+	switch {
+	case d.size == FrameMinLen:
+		// no payload
+	case data[4] != FrameControl:
+		d.add("payload", 0, FrameHeaderLen, d.size-FrameMinLen, "", nil)
+	case data[5] == CommandPoll || data[5] == CommandAck:
+		d.serialMessage()
+	case data[5] == CommandStatus || data[5] == CommandPollResponse:
+		d.status(profile)
+	default:
+		d.add("payload", 0, FrameHeaderLen, d.size-FrameMinLen, "", nil)
+	}
+
+	if len(data) < d.size {
+		d.add("checksum", 0, d.size-2, 1, "", nil)
+	} else if sum := Checksum(data[:d.size-2]); sum != data[d.size-2] {
+		d.add("checksum", 0, d.size-2, 1, "", fmt.Errorf("expected 0x%02x but was 0x%02x", sum, data[d.size-2]))
+	} else {
+		d.add("checksum", 0, d.size-2, 1, "", nil)
+	}
+
+	d.marker("end", d.size-1, FrameEnd)
+
+	return d.result()
+}
+
+// Name the command of a frame, using the same names as for frame types (e.g. 'poll-response').
+func commandName(control, command byte) string {
+	if control != FrameControl {
+		return "unknown"
+	}
+
+	switch command {
+	case CommandPoll:
+		return "poll"
+	case CommandAck:
+		return "ack"
+	case CommandStatus:
+		return "status"
+	case CommandPollResponse:
+		return "poll-response"
+	default:
+		return "unknown"
+	}
+}
+
+type dissector struct {
+	data []byte
+
+	// The frame according to the length in its header, padded with zeros if the data is truncated.
+	frame []byte
+	size  int
+
+	fields []FrameField
+	errs   []error
+}
+
+// Add a field of the given width at offset. If err is non-nil, the field is flagged as invalid. Fields cut short by
+// the end of the data are flagged as truncated, and fields beyond it are left out.
+func (d *dissector) add(name string, module, offset, width int, value string, err error) {
+	if offset >= len(d.data) {
+		return
+	}
+
+	if end := offset + width; end > len(d.data) {
+		width = len(d.data) - offset
+		value = ""
+
+		if err == nil {
+			err = fmt.Errorf("truncated: got %d of %d bytes", len(d.data)-offset, end-offset)
+		}
+	}
+
+	field := FrameField{
+		Name:   name,
+		Module: module,
+		Offset: offset,
+		Data:   HexBytes(d.data[offset : offset+width]),
+		Value:  value,
+	}
+
+	if err != nil {
+		field.Err = err.Error()
+		d.errs = append(d.errs, fmt.Errorf("%s [%d]: %w", strings.ToLower(field.Label()), offset, err))
+	}
+
+	d.fields = append(d.fields, field)
+}
+
+// Add a single byte field which must have the expected value.
+func (d *dissector) marker(name string, offset int, expected byte) {
+	if offset >= len(d.data) {
+		return
+	}
+
+	if d.data[offset] != expected {
+		d.add(name, 0, offset, 1, "", fmt.Errorf("expected 0x%02x but was 0x%02x", expected, d.data[offset]))
+	} else {
+		d.add(name, 0, offset, 1, "", nil)
+	}
+}
+
+// Return the fields found so far, ordered by offset, and all problems found.
+func (d *dissector) result() ([]FrameField, error) {
+	slices.SortStableFunc(d.fields, func(a, b FrameField) int {
+		return cmp.Or(a.Offset-b.Offset, strings.Compare(a.Name, b.Name))
+	})
+
+	if len(d.errs) > 0 {
+		return d.fields, errors.Join(ErrFrameDecodeFailure, errors.Join(d.errs...))
+	}
+
+	return d.fields, nil
+}
+
+// Dissect the payload of a poll or ack message: the inverter serial number followed by padding.
+func (d *dissector) serialMessage() {
+	payload := d.size - FrameMinLen
+	if payload != 8 {
+		d.add("payload", 0, FrameHeaderLen, payload, "", fmt.Errorf("unexpected payload length: %d", payload))
+		return
+	}
+
+	d.add("inverter_id", 0, FrameHeaderLen, 4, HexBytes(d.frame[FrameHeaderLen:][:4]).String(), nil)
+	d.add("padding", 0, FrameHeaderLen+4, 4, "", nil)
+}
+
+// Dissect the payload of a status frame, according to the profile.
+func (d *dissector) status(profile *Profile) {
+	if profile == nil {
+		profile = DetectProfile(d.size)
+	}
+
+	count, err := profile.ModuleCount(d.size)
+	if err != nil {
+		d.add("payload", 0, FrameHeaderLen, d.size-FrameMinLen, "", err)
+		return
+	}
+
+	id := profile.InverterId
+	d.add("inverter_id", 0, id.Offset, id.Width, fmt.Sprintf("%x", id.raw(d.data)), nil)
+
+	for _, span := range reservedSpans(d.frame[:profile.HeaderLen], FrameHeaderLen, id) {
+		d.add("reserved", 0, span.Offset, len(span.Data), "", nil)
+	}
+
+	m := profile.Module
+
+	for i := range count {
+		base := profile.HeaderLen + i*profile.ModuleLen
+		block := d.frame[base:][:profile.ModuleLen]
+
+		fw := m.FirmwareVersion.raw(block)
+
+		d.add("module_id", i+1, base+m.ModuleId.Offset, m.ModuleId.Width, fmt.Sprintf("%x", m.ModuleId.raw(block)), nil)
+		d.add("firmware_version", i+1, base+m.FirmwareVersion.Offset, m.FirmwareVersion.Width, fmt.Sprintf("%d/%d", fw>>8, fw&0xff), nil)
+
+		fields := m.fields()
+		covered := make([]Field, 0, len(fields))
+
+		for name, field := range fields {
+			covered = append(covered, field)
+
+			if unit, ok := fieldUnits[name]; ok {
+				d.add(name, i+1, base+field.Offset, field.Width, fmt.Sprintf("%.2f%s", field.decode(block), unit), nil)
+			}
+		}
+
+		for _, span := range reservedSpans(block, 0, covered...) {
+			d.add("reserved", i+1, base+span.Offset, len(span.Data), "", nil)
+		}
+	}
+}
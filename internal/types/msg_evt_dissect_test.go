@@ -0,0 +1,178 @@
+package types
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDissectFrame(t *testing.T) {
+	status, _ := hex.DecodeString("" +
+		"680056681051305876127001790000000000000030587612" +
+		"707945060a4c0003cfda21003a963205000000000000000000000000" +
+		"3058761370794794084a00032db021333a963205000000000000000000000000" +
+		"5116")
+
+	t.Run("should dissect status frames with their field offsets", func(t *testing.T) {
+		fields, err := DissectFrame(status, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := map[string]struct {
+			offset int
+			len    int
+			value  string
+		}{
+			"Length":                       {1, 2, "86"},
+			"Command":                      {5, 1, "poll-response"},
+			"Inverter ID":                  {6, 4, "30587612"},
+			"Module 1 Module ID":           {20, 4, "30587612"},
+			"Module 1 Firmware Version":    {24, 2, "112/121"},
+			"Module 1 DC Input Voltage":    {26, 2, "34.51V"},
+			"Module 1 Total Energy":        {30, 4, "30.50kWh"},
+			"Module 2 AC Output Power":     {60, 2, "33.16W"},
+			"Module 2 AC Output Frequency": {70, 2, "50.02Hz"},
+		}
+
+		for _, field := range fields {
+			e, ok := expected[field.Label()]
+			if !ok {
+				continue
+			}
+
+			delete(expected, field.Label())
+
+			switch {
+			case field.Offset != e.offset || field.Len() != e.len:
+				t.Fatalf("unexpected location of %s: [%d] (%d bytes)", field.Label(), field.Offset, field.Len())
+			case field.Value != e.value:
+				t.Fatalf("unexpected value of %s: %s", field.Label(), field.Value)
+			}
+		}
+
+		if len(expected) != 0 {
+			t.Fatalf("missing fields: %v", expected)
+		}
+	})
+
+	t.Run("should cover every byte of the frame in order", func(t *testing.T) {
+		fields, err := DissectFrame(status, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		offset := 0
+		for _, field := range fields {
+			if field.Offset != offset {
+				t.Fatalf("unexpected offset of %s: expected %d but was %d", field.Label(), offset, field.Offset)
+			}
+
+			offset += field.Len()
+		}
+
+		if offset != len(status) {
+			t.Fatalf("fields cover %d of %d bytes", offset, len(status))
+		}
+	})
+
+	t.Run("should dissect poll messages", func(t *testing.T) {
+		msg, _ := NewPollMessage("31583078")
+
+		var frame Frame
+		frame.UnmarshalText(msg)
+		data, _ := frame.MarshalBinary()
+
+		fields, err := DissectFrame(data, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var names []string
+		for _, field := range fields {
+			names = append(names, field.Name)
+		}
+
+		if strings.Join(names, ",") != "start,length,start,control,command,inverter_id,padding,checksum,end" {
+			t.Fatalf("unexpected fields: %v", names)
+		}
+		if fields[4].Value != "poll" || fields[5].Value != "31583078" {
+			t.Fatalf("unexpected values: %+v", fields)
+		}
+	})
+
+	t.Run("should flag invalid fields", func(t *testing.T) {
+		data := append([]byte(nil), status...)
+		data[len(data)-2] = 0x00
+		data[len(data)-1] = 0x17
+
+		fields, err := DissectFrame(data, nil)
+		if !errors.Is(err, ErrFrameDecodeFailure) {
+			t.Fatalf("expected decode failure but was: %v", err)
+		}
+
+		checksum, end := fields[len(fields)-2], fields[len(fields)-1]
+
+		switch {
+		case checksum.Err != "expected 0x51 but was 0x00":
+			t.Fatalf("unexpected checksum error: %q", checksum.Err)
+		case end.Err != "expected 0x16 but was 0x17":
+			t.Fatalf("unexpected end error: %q", end.Err)
+		case fields[len(fields)-3].Err != "":
+			t.Fatalf("unexpected error: %q", fields[len(fields)-3].Err)
+		}
+	})
+
+	t.Run("should flag status frames not matching the profile", func(t *testing.T) {
+		fields, err := DissectFrame(status, ProfileEVT400)
+		if !errors.Is(err, ErrFrameDecodeFailure) {
+			t.Fatalf("expected decode failure but was: %v", err)
+		}
+
+		if payload := fields[5]; payload.Name != "payload" || payload.Err == "" {
+			t.Fatalf("unexpected payload field: %+v", payload)
+		}
+	})
+
+	t.Run("should dissect truncated frames as far as the data allows", func(t *testing.T) {
+		fields, err := DissectFrame(status[:29], nil)
+		if !errors.Is(err, ErrFrameDecodeFailure) || !strings.Contains(err.Error(), "got 29 of 86 bytes") {
+			t.Fatalf("expected truncated frame failure but was: %v", err)
+		}
+
+		offset := 0
+		for _, field := range fields {
+			if field.Offset != offset {
+				t.Fatalf("unexpected offset of %s: expected %d but was %d", field.Label(), offset, field.Offset)
+			}
+
+			offset += field.Len()
+
+			switch {
+			case field.Label() == "Module 1 DC Input Voltage" && field.Value != "34.51V":
+				t.Fatalf("unexpected value of %s: %s", field.Label(), field.Value)
+			case offset < 29 && field.Err != "":
+				t.Fatalf("unexpected error of %s: %s", field.Label(), field.Err)
+			}
+		}
+
+		last := fields[len(fields)-1]
+
+		switch {
+		case offset != 29:
+			t.Fatalf("fields cover %d of 29 bytes", offset)
+		case last.Offset != 28 || !strings.HasPrefix(last.Err, "truncated: got 1 of"):
+			t.Fatalf("unexpected last field: %+v", last)
+		}
+
+		fields, err = DissectFrame(status[:2], nil)
+		if !errors.Is(err, ErrFrameDecodeFailure) {
+			t.Fatalf("expected decode failure but was: %v", err)
+		}
+
+		if len(fields) != 2 || fields[1].Name != "length" || fields[1].Err == "" {
+			t.Fatalf("unexpected fields: %+v", fields)
+		}
+	})
+}
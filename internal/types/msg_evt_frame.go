@@ -0,0 +1,215 @@
+package types
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	// Start marker, found at byte 0 and again at byte 3 (after the length field).
+	FrameStart byte = 0x68
+
+	// End marker, always the last byte of the frame.
+	FrameEnd byte = 0x16
+
+	// Control byte observed on all frames exchanged with the inverter.
+	FrameControl byte = 0x10
+
+	// Length of the frame header (start, length, start, control, command).
+	FrameHeaderLen = 6
+
+	// Length of the frame trailer (checksum, end).
+	FrameTrailerLen = 2
+
+	// Smallest possible frame: a header and trailer without payload.
+	FrameMinLen = FrameHeaderLen + FrameTrailerLen
+)
+
+// Known frame commands (byte 5).
+const (
+	CommandPoll         byte = 0x77
+	CommandAck          byte = 0x50
+	CommandStatus       byte = 0x04
+	CommandPollResponse byte = 0x51
+)
+
+var (
+	ErrFrameDecodeFailure = errors.New("decode: failed to decode evt frame")
+	ErrFrameEncodeFailure = errors.New("encode: failed to encode evt frame")
+)
+
+// Frame is a single message exchanged with the inverter. On the wire, frames have the following layout:
+//
+//	68 LLLL 68 CC DD [payload...] SS 16
+//	|  |    |  |  |               |  |
+//	|  |    |  |  |               |  end marker
+//	|  |    |  |  |               checksum (sum of all preceding bytes, mod 256)
+//	|  |    |  |  command
+//	|  |    |  control
+//	|  |    start marker
+//	|  total frame length (big endian)
+//	start marker
+type Frame struct {
+	Control byte
+	Command byte
+	Payload []byte
+}
+
+// Len returns the length of the encoded frame, in bytes.
+func (f *Frame) Len() int {
+	return FrameMinLen + len(f.Payload)
+}
+
+// MarshalBinary encodes the frame, computing the length field and checksum.
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	if f.Len() > 0xffff {
+		return nil, errors.Join(ErrFrameEncodeFailure, fmt.Errorf("payload too large (%d bytes)", len(f.Payload)))
+	}
+
+	data := make([]byte, 0, f.Len())
+	data = append(data, FrameStart)
+	data = binary.BigEndian.AppendUint16(data, uint16(f.Len()))
+	data = append(data, FrameStart, f.Control, f.Command)
+	data = append(data, f.Payload...)
+	data = append(data, Checksum(data), FrameEnd)
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes and validates a single frame from data. Bytes beyond the length advertised in the frame
+// header are ignored.
+func (f *Frame) UnmarshalBinary(data []byte) error {
+	size, err := FrameLen(data)
+	if err != nil {
+		return errors.Join(ErrFrameDecodeFailure, err)
+	}
+
+	if len(data) < size {
+		return errors.Join(ErrFrameDecodeFailure, fmt.Errorf("truncated frame: got %d of %d bytes", len(data), size))
+	}
+
+	data = data[:size]
+
+	if data[size-1] != FrameEnd {
+		return errors.Join(ErrFrameDecodeFailure, fmt.Errorf("unexpected frame end token [0x%x]", data[size-1]))
+	}
+
+	if sum := Checksum(data[:size-2]); sum != data[size-2] {
+		return errors.Join(ErrFrameDecodeFailure, fmt.Errorf("checksum mismatch: expected 0x%02x but was 0x%02x", sum, data[size-2]))
+	}
+
+	f.Control = data[4]
+	f.Command = data[5]
+	f.Payload = append([]byte(nil), data[FrameHeaderLen:size-FrameTrailerLen]...)
+
+	return nil
+}
+
+// MarshalText encodes the frame in its ASCII-hex form, as used for the poll and ack messages.
+func (f *Frame) MarshalText() ([]byte, error) {
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.AppendEncode(nil, data), nil
+}
+
+// UnmarshalText decodes a frame from its ASCII-hex form.
+func (f *Frame) UnmarshalText(text []byte) error {
+	data, err := hex.DecodeString(string(text))
+	if err != nil {
+		return errors.Join(ErrFrameDecodeFailure, err)
+	}
+
+	return f.UnmarshalBinary(data)
+}
+
+// FrameLen validates the frame header at the beginning of data and returns the total frame length advertised in the
+// header. At least [FrameHeaderLen] bytes of data are needed.
+func FrameLen(data []byte) (int, error) {
+	if len(data) < FrameHeaderLen {
+		return 0, fmt.Errorf("truncated frame header: got %d bytes", len(data))
+	}
+
+	if data[0] != FrameStart || data[3] != FrameStart {
+		return 0, fmt.Errorf("unexpected frame start tokens [0x%x, 0x%x]", data[0], data[3])
+	}
+
+	size := int(binary.BigEndian.Uint16(data[1:3]))
+	if size < FrameMinLen {
+		return 0, fmt.Errorf("illegal frame length: %d", size)
+	}
+
+	return size, nil
+}
+
+// Checksum computes the frame checksum over data, the sum of all bytes modulo 256.
+func Checksum(data []byte) byte {
+	var sum byte
+
+	for _, b := range data {
+		sum += b
+	}
+
+	return sum
+}
+
+// Decode an inverter serial number (e.g. 31583078) into its 4-byte binary form.
+func decodeSerial(sn string) ([]byte, error) {
+	id, err := hex.DecodeString(sn)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sn) != 8 || len(id) != 4 {
+		return nil, fmt.Errorf("illegal inverter serial number: %s", sn)
+	}
+
+	return id, nil
+}
+
+// Build a poll or ack style message: a frame carrying the inverter serial number followed by 4 bytes of padding.
+func serialMessageFrame(command byte, sn string) (*Frame, error) {
+	id, err := decodeSerial(sn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Frame{
+		Control: FrameControl,
+		Command: command,
+		Payload: append(id, 0x00, 0x00, 0x00, 0x00),
+	}, nil
+}
+
+// Decode a poll or ack style message in either ASCII-hex or binary form, returning the inverter serial number.
+func unmarshalSerialMessage(command byte, data []byte) (string, error) {
+	var (
+		frame Frame
+		err   error
+	)
+
+	if len(data) > 0 && data[0] != FrameStart {
+		err = frame.UnmarshalText(data)
+	} else {
+		err = frame.UnmarshalBinary(data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if frame.Control != FrameControl {
+		return "", fmt.Errorf("unexpected control byte [0x%x]", frame.Control)
+	}
+	if frame.Command != command {
+		return "", fmt.Errorf("unexpected command byte [0x%x]", frame.Command)
+	}
+	if len(frame.Payload) != 8 {
+		return "", fmt.Errorf("unexpected payload length: %d", len(frame.Payload))
+	}
+
+	return hex.EncodeToString(frame.Payload[:4]), nil
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestFrame(t *testing.T) {
+	t.Run("should encode header, length and checksum", func(t *testing.T) {
+		frame := Frame{
+			Control: FrameControl,
+			Command: CommandPoll,
+			Payload: []byte{0x31, 0x58, 0x30, 0x78, 0x00, 0x00, 0x00, 0x00},
+		}
+
+		result, err := frame.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expectedHex := "68001068107731583078000000009816"
+		if hex.EncodeToString(result) != expectedHex {
+			t.Fatalf("unexpected frame: %x", result)
+		}
+	})
+
+	t.Run("should encode ascii-hex form", func(t *testing.T) {
+		frame := Frame{
+			Control: FrameControl,
+			Command: CommandAck,
+			Payload: []byte{0x31, 0x58, 0x30, 0x78, 0x00, 0x00, 0x00, 0x00},
+		}
+
+		result, err := frame.MarshalText()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if string(result) != "68001068105031583078000000007116" {
+			t.Fatalf("unexpected frame: %s", result)
+		}
+	})
+
+	t.Run("should decode frames produced by the encoder", func(t *testing.T) {
+		frame := Frame{
+			Control: FrameControl,
+			Command: CommandStatus,
+			Payload: []byte{0x01, 0x02, 0x03},
+		}
+
+		data, err := frame.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var result Frame
+		if err := result.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		switch {
+		case result.Control != frame.Control:
+			t.Fatalf("unexpected control byte: 0x%x", result.Control)
+		case result.Command != frame.Command:
+			t.Fatalf("unexpected command byte: 0x%x", result.Command)
+		case !bytes.Equal(result.Payload, frame.Payload):
+			t.Fatalf("unexpected payload: %x", result.Payload)
+		}
+	})
+
+	t.Run("should decode ascii-hex form", func(t *testing.T) {
+		var result Frame
+		if err := result.UnmarshalText([]byte("68001068107731583078000000009816")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if result.Command != CommandPoll {
+			t.Fatalf("unexpected command byte: 0x%x", result.Command)
+		}
+	})
+
+	t.Run("should ignore bytes beyond the frame length", func(t *testing.T) {
+		data, _ := hex.DecodeString("6800106810773158307800000000981668001068")
+
+		var result Frame
+		if err := result.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(result.Payload) != 8 {
+			t.Fatalf("unexpected payload length: %d", len(result.Payload))
+		}
+	})
+
+	t.Run("should reject invalid frames", func(t *testing.T) {
+		illegal := map[string]string{
+			"empty":             "",
+			"truncated header":  "68001068",
+			"bad start token":   "69001068107731583078000000009816",
+			"bad second start":  "68001069107731583078000000009816",
+			"length too small":  "68000268107731583078000000009816",
+			"truncated payload": "680010681077315830780000",
+			"bad checksum":      "68001068107731583078000000009916",
+			"bad end token":     "68001068107731583078000000009817",
+			"corrupted payload": "68001068107731583078000100009816",
+		}
+
+		for name, frame := range illegal {
+			data, _ := hex.DecodeString(frame)
+
+			var result Frame
+			err := result.UnmarshalBinary(data)
+			if err == nil {
+				t.Fatalf("expected error but was nil for frame: %s", name)
+			}
+			if !errors.Is(err, ErrFrameDecodeFailure) {
+				t.Fatalf("unexpected error for frame %s: %v", name, err)
+			}
+		}
+	})
+}
@@ -1,29 +1,64 @@
 package types
 
 import (
-	"encoding/hex"
+	"errors"
 	"fmt"
 )
 
-var rawPollMessage = []byte{
-	0x36, 0x38, 0x30, 0x30, 0x31, 0x30, 0x36, 0x38, 0x31, 0x30, 0x37, 0x37,
-	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // inverter serial
-	0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x39, 0x66, 0x31, 0x36,
+var (
+	ErrPollMessageDecodeFailure = errors.New("decode: failed to decode evt poll message")
+)
+
+// PollMessage is sent to the inverter to request its current status.
+//
+// On the wire, poll messages are sent in their ASCII-hex form, produced by MarshalText. Like [Frame.MarshalBinary],
+// MarshalBinary produces the raw binary form. UnmarshalBinary accepts both forms, since both show up in captures.
+type PollMessage struct {
+	InverterId string
 }
 
+// NewPollMessage builds the (ASCII-hex encoded) message used to poll the inverter with serial sn for its status.
 func NewPollMessage(sn string) ([]byte, error) {
-	id, err := hex.DecodeString(sn)
+	msg := PollMessage{InverterId: sn}
+
+	return msg.MarshalText()
+}
+
+func (m *PollMessage) MarshalBinary() ([]byte, error) {
+	frame, err := serialMessageFrame(CommandPoll, m.InverterId)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame.MarshalBinary()
+}
+
+// MarshalText encodes the message in its ASCII-hex form, as sent on the wire.
+func (m *PollMessage) MarshalText() ([]byte, error) {
+	frame, err := serialMessageFrame(CommandPoll, m.InverterId)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(sn) != 8 || len(id) != 4 {
-		return nil, fmt.Errorf("illegal inverter serial number: %s", sn)
+	return frame.MarshalText()
+}
+
+func (m *PollMessage) UnmarshalBinary(data []byte) error {
+	sn, err := unmarshalSerialMessage(CommandPoll, data)
+	if err != nil {
+		return errors.Join(ErrPollMessageDecodeFailure, err)
 	}
 
-	msg := make([]byte, 32)
-	copy(msg, rawPollMessage)
-	copy(msg[12:20], []byte(sn))
+	m.InverterId = sn
+
+	return nil
+}
+
+// UnmarshalText decodes the message from its ASCII-hex form.
+func (m *PollMessage) UnmarshalText(text []byte) error {
+	if len(text) > 0 && text[0] == FrameStart {
+		return errors.Join(ErrPollMessageDecodeFailure, fmt.Errorf("not in ASCII-hex form"))
+	}
 
-	return msg, nil
+	return m.UnmarshalBinary(text)
 }
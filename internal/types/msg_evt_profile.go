@@ -0,0 +1,489 @@
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrIllegalProfile = errors.New("profile: illegal model profile")
+)
+
+// Profile describes the layout of status frames for a particular inverter model: where fields are located, how wide
+// they are, and how raw values are scaled.
+//
+// Status frames consist of a header of HeaderLen bytes (including the frame header) followed by a block of ModuleLen
+// bytes for each inverter module, and the frame trailer.
+type Profile struct {
+	// Model name, e.g. EVT800.
+	Name string `json:"name"`
+
+	// Optional human-readable description, e.g. the models known to use this profile.
+	Description string `json:"description,omitempty"`
+
+	// Number of modules of the inverter. If zero, the number of modules is derived from the frame length.
+	Modules int `json:"modules,omitempty"`
+
+	// Length of the status frame header, preceding the module blocks.
+	HeaderLen int `json:"header_len"`
+
+	// Length of each module block.
+	ModuleLen int `json:"module_len"`
+
+	// Location of the inverter ID, relative to the start of the frame.
+	InverterId Field `json:"inverter_id"`
+
+	// Location of module fields, relative to the start of each module block.
+	Module ModuleProfile `json:"module"`
+}
+
+// ModuleProfile describes the layout of a module block of a status frame.
+type ModuleProfile struct {
+	ModuleId          Field `json:"module_id"`
+	FirmwareVersion   Field `json:"firmware_version"`
+	InputVoltageDC    Field `json:"input_voltage_dc"`
+	OutputPowerAC     Field `json:"output_power_ac"`
+	TotalEnergy       Field `json:"total_energy"`
+	Temperature       Field `json:"temperature"`
+	OutputVoltageAC   Field `json:"output_voltage_ac"`
+	OutputFrequencyAC Field `json:"output_frequency_ac"`
+}
+
+// Field describes an unsigned big endian integer field of a status frame. Decoded values are computed as:
+//
+//	value = raw / Scale + Bias
+type Field struct {
+	// Offset of the field, in bytes.
+	Offset int `json:"offset"`
+
+	// Width of the field, in bytes (1, 2 or 4).
+	Width int `json:"width"`
+
+	// Divisor applied to the raw value. Defaults to 1.
+	Scale float64 `json:"scale,omitempty"`
+
+	// Constant added to the scaled value.
+	Bias float64 `json:"bias,omitempty"`
+}
+
+var (
+	// Profile for two-module inverters, like the EVT800B.
+	ProfileEVT800 = &Profile{
+		Name:        "EVT800",
+		Description: "two-module inverters (EVT800B)",
+		Modules:     2,
+		HeaderLen:   20,
+		ModuleLen:   32,
+		InverterId:  Field{Offset: 6, Width: 4},
+		Module:      defaultModuleProfile,
+	}
+
+	// Profile for single-module inverters, like the EVT400R.
+	ProfileEVT400 = &Profile{
+		Name:        "EVT400",
+		Description: "single-module inverters (EVT400R)",
+		Modules:     1,
+		HeaderLen:   20,
+		ModuleLen:   32,
+		InverterId:  Field{Offset: 6, Width: 4},
+		Module:      defaultModuleProfile,
+	}
+
+	// Fallback profile for frames not matching any other profile, with any number of modules.
+	ProfileGeneric = &Profile{
+		Name:        "generic",
+		Description: "any number of modules, with the EVT800 module layout",
+		HeaderLen:   20,
+		ModuleLen:   32,
+		InverterId:  Field{Offset: 6, Width: 4},
+		Module:      defaultModuleProfile,
+	}
+
+	defaultModuleProfile = ModuleProfile{
+		ModuleId:          Field{Offset: 0, Width: 4},
+		FirmwareVersion:   Field{Offset: 4, Width: 2},
+		InputVoltageDC:    Field{Offset: 6, Width: 2, Scale: 512.0},
+		OutputPowerAC:     Field{Offset: 8, Width: 2, Scale: 64.0},
+		TotalEnergy:       Field{Offset: 10, Width: 4, Scale: 8192.0},
+		Temperature:       Field{Offset: 14, Width: 2, Scale: 128.0, Bias: -40.0},
+		OutputVoltageAC:   Field{Offset: 16, Width: 2, Scale: 64.0},
+		OutputFrequencyAC: Field{Offset: 18, Width: 2, Scale: 256.0},
+	}
+)
+
+var (
+	profiles    = []*Profile{ProfileEVT800, ProfileEVT400}
+	profilesMux sync.RWMutex
+)
+
+// RegisterProfile validates p and makes it available to [LookupProfile] and [DetectProfile]. Registered profiles take
+// precedence over built-in profiles, and replace any existing profile with the same name.
+func RegisterProfile(p *Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	profilesMux.Lock()
+	defer profilesMux.Unlock()
+
+	registered := []*Profile{p}
+	for _, existing := range profiles {
+		if !strings.EqualFold(existing.Name, p.Name) {
+			registered = append(registered, existing)
+		}
+	}
+
+	profiles = registered
+
+	return nil
+}
+
+// LoadProfiles reads a JSON profile, or JSON array of profiles, from r and registers them with [RegisterProfile].
+func LoadProfiles(r io.Reader) ([]*Profile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Join(ErrIllegalProfile, err)
+	}
+
+	var loaded []*Profile
+
+	if data = bytes.TrimSpace(data); bytes.HasPrefix(data, []byte("[")) {
+		err = json.Unmarshal(data, &loaded)
+	} else {
+		loaded = []*Profile{{}}
+		err = json.Unmarshal(data, loaded[0])
+	}
+	if err != nil {
+		return nil, errors.Join(ErrIllegalProfile, err)
+	}
+
+	for _, p := range loaded {
+		if err := RegisterProfile(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return loaded, nil
+}
+
+// LookupProfile finds a registered profile by (case-insensitive) name.
+func LookupProfile(name string) (*Profile, bool) {
+	for _, p := range Profiles() {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+
+	if strings.EqualFold(ProfileGeneric.Name, name) {
+		return ProfileGeneric, true
+	}
+
+	return nil, false
+}
+
+// Profiles returns all registered profiles, in order of precedence.
+func Profiles() []*Profile {
+	profilesMux.RLock()
+	defer profilesMux.RUnlock()
+
+	return append([]*Profile(nil), profiles...)
+}
+
+// DetectProfile selects the registered profile matching the length of a status frame. If no registered profile
+// matches, [ProfileGeneric] is returned.
+func DetectProfile(frameLen int) *Profile {
+	for _, p := range Profiles() {
+		if p.matches(frameLen) {
+			return p
+		}
+	}
+
+	return ProfileGeneric
+}
+
+// profileForModules selects the registered profile for an inverter with the given number of modules. If no registered
+// profile matches, [ProfileGeneric] is returned.
+func profileForModules(count int) *Profile {
+	for _, p := range Profiles() {
+		if p.Modules == count {
+			return p
+		}
+	}
+
+	return ProfileGeneric
+}
+
+// FrameLen returns the length of a status frame carrying the given number of modules.
+func (p *Profile) FrameLen(modules int) int {
+	return p.HeaderLen + modules*p.ModuleLen + FrameTrailerLen
+}
+
+// ModuleCount returns the number of modules in a status frame of the given length, or an error if the length doesn't
+// fit this profile.
+func (p *Profile) ModuleCount(frameLen int) (int, error) {
+	count := (frameLen - p.FrameLen(0)) / p.ModuleLen
+	if count < 1 || frameLen != p.FrameLen(count) || (p.Modules != 0 && count != p.Modules) {
+		return 0, fmt.Errorf("unexpected frame length for %s profile: %d", p.Name, frameLen)
+	}
+
+	return count, nil
+}
+
+func (p *Profile) matches(frameLen int) bool {
+	_, err := p.ModuleCount(frameLen)
+	return err == nil
+}
+
+// Validate checks that the profile describes a sensible frame layout.
+func (p *Profile) Validate() error {
+	switch {
+	case p.Name == "":
+		return errors.Join(ErrIllegalProfile, fmt.Errorf("name is empty"))
+	case p.Modules < 0:
+		return errors.Join(ErrIllegalProfile, fmt.Errorf("%s: illegal module count: %d", p.Name, p.Modules))
+	case p.HeaderLen < FrameHeaderLen:
+		return errors.Join(ErrIllegalProfile, fmt.Errorf("%s: illegal header length: %d", p.Name, p.HeaderLen))
+	case p.ModuleLen < 1:
+		return errors.Join(ErrIllegalProfile, fmt.Errorf("%s: illegal module length: %d", p.Name, p.ModuleLen))
+	}
+
+	if err := p.InverterId.validate(p.HeaderLen, 4); err != nil {
+		return errors.Join(ErrIllegalProfile, fmt.Errorf("%s: inverter_id: %w", p.Name, err))
+	}
+
+	for name, field := range p.Module.fields() {
+		width := 0
+		switch name {
+		case "module_id":
+			width = 4
+		case "firmware_version":
+			width = 2
+		}
+
+		if err := field.validate(p.ModuleLen, width); err != nil {
+			return errors.Join(ErrIllegalProfile, fmt.Errorf("%s: %s: %w", p.Name, name, err))
+		}
+	}
+
+	return nil
+}
+
+// Decode the inverter ID and module blocks of a status frame.
+func (p *Profile) decode(data []byte, s *InverterStatus) error {
+	count, err := p.ModuleCount(len(data))
+	if err != nil {
+		return err
+	}
+
+	s.InverterId = fmt.Sprintf("%x", p.InverterId.raw(data))
+	s.Modules = make([]InverterModuleStatus, count)
+	s.Raw = &RawInverterStatus{
+		Reserved: reservedSpans(data[:p.HeaderLen], FrameHeaderLen, p.InverterId),
+		Modules:  make([]RawInverterModuleStatus, count),
+	}
+
+	for i := range s.Modules {
+		block := data[p.HeaderLen+i*p.ModuleLen:][:p.ModuleLen]
+		p.Module.decode(block, &s.Modules[i])
+		p.Module.decodeRaw(block, &s.Raw.Modules[i])
+	}
+
+	return nil
+}
+
+// Encode the inverter ID and module blocks of a status, returning the frame payload.
+func (p *Profile) encode(s *InverterStatus) ([]byte, error) {
+	if len(s.Modules) == 0 {
+		return nil, fmt.Errorf("no modules")
+	}
+	if p.Modules != 0 && len(s.Modules) != p.Modules {
+		return nil, fmt.Errorf("unexpected module count for %s profile: %d", p.Name, len(s.Modules))
+	}
+
+	data := make([]byte, p.FrameLen(len(s.Modules)))
+
+	id, err := parseHexId(s.InverterId)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Raw != nil {
+		if err := putReservedSpans(data[:p.HeaderLen], s.Raw.Reserved); err != nil {
+			return nil, err
+		}
+	}
+
+	p.InverterId.put(data, uint64(id))
+
+	for i := range s.Modules {
+		block := data[p.HeaderLen+i*p.ModuleLen:][:p.ModuleLen]
+
+		if s.Raw != nil && i < len(s.Raw.Modules) {
+			if err := putReservedSpans(block, s.Raw.Modules[i].Reserved); err != nil {
+				return nil, fmt.Errorf("module %d: %w", i+1, err)
+			}
+		}
+
+		if err := p.Module.encode(block, &s.Modules[i]); err != nil {
+			return nil, fmt.Errorf("module %d: %w", i+1, err)
+		}
+	}
+
+	return data[FrameHeaderLen : len(data)-FrameTrailerLen], nil
+}
+
+func (m *ModuleProfile) fields() map[string]Field {
+	return map[string]Field{
+		"module_id":           m.ModuleId,
+		"firmware_version":    m.FirmwareVersion,
+		"input_voltage_dc":    m.InputVoltageDC,
+		"output_power_ac":     m.OutputPowerAC,
+		"total_energy":        m.TotalEnergy,
+		"temperature":         m.Temperature,
+		"output_voltage_ac":   m.OutputVoltageAC,
+		"output_frequency_ac": m.OutputFrequencyAC,
+	}
+}
+
+func (m *ModuleProfile) decode(block []byte, module *InverterModuleStatus) {
+	fw := m.FirmwareVersion.raw(block)
+
+	module.ModuleId = fmt.Sprintf("%x", m.ModuleId.raw(block))
+	module.FirmwareVersion = fmt.Sprintf("%d/%d", fw>>8, fw&0xff)
+	module.InputVoltageDC = m.InputVoltageDC.decode(block)
+	module.OutputPowerAC = m.OutputPowerAC.decode(block)
+	module.TotalEnergy = m.TotalEnergy.decode(block)
+	module.Temperature = m.Temperature.decode(block)
+	module.OutputVoltageAC = m.OutputVoltageAC.decode(block)
+	module.OutputFrequencyAC = m.OutputFrequencyAC.decode(block)
+}
+
+func (m *ModuleProfile) decodeRaw(block []byte, raw *RawInverterModuleStatus) {
+	fields := m.fields()
+
+	raw.Fields = make(map[string]uint64, len(fields))
+	for name, field := range fields {
+		raw.Fields[name] = field.raw(block)
+	}
+
+	covered := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		covered = append(covered, field)
+	}
+
+	raw.Reserved = reservedSpans(block, 0, covered...)
+}
+
+func (m *ModuleProfile) encode(block []byte, module *InverterModuleStatus) error {
+	id, err := parseHexId(module.ModuleId)
+	if err != nil {
+		return err
+	}
+
+	m.ModuleId.put(block, uint64(id))
+
+	var major, minor uint8
+
+	_, err = fmt.Sscanf(module.FirmwareVersion, "%d/%d", &major, &minor)
+	if err != nil {
+		return fmt.Errorf("illegal firmware version %q: %w", module.FirmwareVersion, err)
+	}
+
+	m.FirmwareVersion.put(block, uint64(major)<<8|uint64(minor))
+
+	fields := []struct {
+		name  string
+		field Field
+		value float64
+	}{
+		{"input voltage dc", m.InputVoltageDC, module.InputVoltageDC},
+		{"output power ac", m.OutputPowerAC, module.OutputPowerAC},
+		{"total energy", m.TotalEnergy, module.TotalEnergy},
+		{"temperature", m.Temperature, module.Temperature},
+		{"output voltage ac", m.OutputVoltageAC, module.OutputVoltageAC},
+		{"output frequency ac", m.OutputFrequencyAC, module.OutputFrequencyAC},
+	}
+
+	for _, f := range fields {
+		if err := f.field.encode(block, f.value); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (f Field) validate(limit, width int) error {
+	if f.Width != 1 && f.Width != 2 && f.Width != 4 {
+		return fmt.Errorf("illegal width: %d", f.Width)
+	}
+	if width != 0 && f.Width != width {
+		return fmt.Errorf("illegal width: %d (must be %d)", f.Width, width)
+	}
+	if f.Offset < 0 || f.Offset+f.Width > limit {
+		return fmt.Errorf("offset out of bounds: %d", f.Offset)
+	}
+	if f.Scale < 0 || math.IsNaN(f.Scale) || math.IsInf(f.Scale, 0) {
+		return fmt.Errorf("illegal scale: %f", f.Scale)
+	}
+
+	return nil
+}
+
+func (f Field) scale() float64 {
+	if f.Scale == 0 {
+		return 1.0
+	}
+
+	return f.Scale
+}
+
+// Read the raw (unscaled) field value from data.
+func (f Field) raw(data []byte) uint64 {
+	b := data[f.Offset : f.Offset+f.Width]
+
+	switch f.Width {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b))
+	default:
+		return uint64(binary.BigEndian.Uint32(b))
+	}
+}
+
+// Write the raw (unscaled) field value to data.
+func (f Field) put(data []byte, v uint64) {
+	b := data[f.Offset : f.Offset+f.Width]
+
+	switch f.Width {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		binary.BigEndian.PutUint16(b, uint16(v))
+	default:
+		binary.BigEndian.PutUint32(b, uint32(v))
+	}
+}
+
+func (f Field) decode(data []byte) float64 {
+	return float64(f.raw(data))/f.scale() + f.Bias
+}
+
+func (f Field) encode(data []byte, value float64) error {
+	v, err := quantize(value-f.Bias, f.scale(), 1<<(8*f.Width)-1)
+	if err != nil {
+		return err
+	}
+
+	f.put(data, v)
+
+	return nil
+}
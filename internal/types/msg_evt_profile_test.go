@@ -0,0 +1,182 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Restore the profile registry once the test completes.
+func restoreProfiles(t *testing.T) {
+	saved := Profiles()
+
+	t.Cleanup(func() {
+		profilesMux.Lock()
+		defer profilesMux.Unlock()
+
+		profiles = saved
+	})
+}
+
+func TestProfile(t *testing.T) {
+	t.Run("built-in profiles should be valid", func(t *testing.T) {
+		for _, p := range []*Profile{ProfileEVT800, ProfileEVT400, ProfileGeneric} {
+			if err := p.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	})
+
+	t.Run("should detect profile from frame length", func(t *testing.T) {
+		expected := map[int]*Profile{
+			54:  ProfileEVT400,
+			86:  ProfileEVT800,
+			150: ProfileGeneric,
+			87:  ProfileGeneric,
+		}
+
+		for size, p := range expected {
+			if result := DetectProfile(size); result != p {
+				t.Fatalf("unexpected profile for frame length %d: %s", size, result.Name)
+			}
+		}
+	})
+
+	t.Run("should lookup profiles by name", func(t *testing.T) {
+		for name, p := range map[string]*Profile{"evt800": ProfileEVT800, "EVT400": ProfileEVT400, "generic": ProfileGeneric} {
+			if result, ok := LookupProfile(name); !ok || result != p {
+				t.Fatalf("unexpected profile for name %s: %v", name, result)
+			}
+		}
+
+		if _, ok := LookupProfile("EVT9000"); ok {
+			t.Fatalf("expected lookup to fail")
+		}
+	})
+
+	t.Run("should reject frames not matching a fixed module count", func(t *testing.T) {
+		status := InverterStatus{
+			InverterId: "31583078",
+			Modules:    []InverterModuleStatus{{ModuleId: "31583078", FirmwareVersion: "1/1"}},
+		}
+
+		msg, err := status.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var result InverterStatus
+		if err := DecodeStatus(msg, ProfileEVT800, &result); !errors.Is(err, ErrStatusFrameDecodeFailure) {
+			t.Fatalf("expected decode failure but was: %v", err)
+		}
+		if err := DecodeStatus(msg, ProfileEVT400, &result); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("should load and decode with custom profiles", func(t *testing.T) {
+		restoreProfiles(t)
+
+		loaded, err := LoadProfiles(strings.NewReader(`[{
+			"name": "EVT-TEST",
+			"modules": 3,
+			"header_len": 12,
+			"module_len": 16,
+			"inverter_id": {"offset": 6, "width": 4},
+			"module": {
+				"module_id": {"offset": 0, "width": 4},
+				"firmware_version": {"offset": 4, "width": 2},
+				"input_voltage_dc": {"offset": 6, "width": 2, "scale": 10},
+				"output_power_ac": {"offset": 8, "width": 1},
+				"total_energy": {"offset": 9, "width": 4, "scale": 1000},
+				"temperature": {"offset": 13, "width": 1, "bias": -20},
+				"output_voltage_ac": {"offset": 14, "width": 1, "scale": 0.5},
+				"output_frequency_ac": {"offset": 15, "width": 1}
+			}
+		}]`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(loaded) != 1 {
+			t.Fatalf("unexpected number of profiles: %d", len(loaded))
+		}
+
+		p, ok := LookupProfile("evt-test")
+		if !ok {
+			t.Fatalf("expected custom profile to be registered")
+		}
+		if DetectProfile(p.FrameLen(3)) != p {
+			t.Fatalf("expected custom profile to be detected")
+		}
+
+		status := InverterStatus{InverterId: "31583078"}
+		for i := range 3 {
+			status.Modules = append(status.Modules, InverterModuleStatus{
+				ModuleId:          fmt.Sprintf("%x", 0x31583078+i),
+				FirmwareVersion:   "2/7",
+				InputVoltageDC:    31.4,
+				OutputPowerAC:     120,
+				TotalEnergy:       12.345,
+				Temperature:       21,
+				OutputVoltageAC:   230,
+				OutputFrequencyAC: 50,
+			})
+		}
+
+		msg, err := status.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(msg) != 12+3*16+2 {
+			t.Fatalf("unexpected frame length: %d", len(msg))
+		}
+
+		var result InverterStatus
+		if err := result.UnmarshalBinary(msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(result.Modules) != 3 {
+			t.Fatalf("unexpected module count: %d", len(result.Modules))
+		}
+
+		epsilon := 0.001
+		m := result.Modules[2]
+
+		switch {
+		case m.ModuleId != "3158307a":
+			t.Fatalf("unexpected module id: %s", m.ModuleId)
+		case m.FirmwareVersion != "2/7":
+			t.Fatalf("unexpected firmware: %s", m.FirmwareVersion)
+		case math.Abs(m.InputVoltageDC-31.4) > epsilon:
+			t.Fatalf("unexpected input voltage dc: %f", m.InputVoltageDC)
+		case math.Abs(m.TotalEnergy-12.345) > epsilon:
+			t.Fatalf("unexpected total energy: %f", m.TotalEnergy)
+		case math.Abs(m.Temperature-21) > epsilon:
+			t.Fatalf("unexpected temp: %f", m.Temperature)
+		case math.Abs(m.OutputVoltageAC-230) > epsilon:
+			t.Fatalf("unexpected output voltage ac: %f", m.OutputVoltageAC)
+		}
+	})
+
+	t.Run("should reject illegal profiles", func(t *testing.T) {
+		restoreProfiles(t)
+
+		illegal := []string{
+			`{}`,
+			`{"name": "x", "header_len": 2, "module_len": 32}`,
+			`{"name": "x", "header_len": 20, "module_len": 0}`,
+			`{"name": "x", "header_len": 20, "module_len": 32, "inverter_id": {"offset": 18, "width": 4}}`,
+			`{"name": "x", "header_len": 20, "module_len": 32, "inverter_id": {"offset": 6, "width": 3}}`,
+			`[{"name": "x"`,
+		}
+
+		for _, profile := range illegal {
+			if _, err := LoadProfiles(strings.NewReader(profile)); !errors.Is(err, ErrIllegalProfile) {
+				t.Fatalf("expected illegal profile error for %s but was: %v", profile, err)
+			}
+		}
+	})
+}
@@ -1,19 +1,23 @@
 package types
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
 )
 
 var (
 	ErrStatusFrameDecodeFailure = errors.New("decode: failed to decode evt status frame")
+	ErrStatusFrameEncodeFailure = errors.New("encode: failed to encode evt status frame")
 )
 
 type InverterStatus struct {
 	InverterId string
-	Module1    InverterModuleStatus
-	Module2    InverterModuleStatus
+	Modules    []InverterModuleStatus
+
+	// Undecoded contents of the status frame, if decoded from a frame.
+	Raw *RawInverterStatus `json:"-"`
 }
 
 type InverterModuleStatus struct {
@@ -27,75 +31,115 @@ type InverterModuleStatus struct {
 	OutputFrequencyAC float64
 }
 
-type rawInverterStatus struct {
-	// reserved [0-5]
-	_ uint32
-	_ uint16
+// TotalOutputPowerAC returns the instantaneous power (AC) of all inverter modules, in W.
+func (s *InverterStatus) TotalOutputPowerAC() float64 {
+	var total float64
 
-	// inverter ID (EVT ID) [6-9]
-	InverterId uint32
+	for _, module := range s.Modules {
+		total += module.OutputPowerAC
+	}
 
-	// reserved [10-19]
-	_ uint64
-	_ uint16
+	return total
+}
 
-	// module 1 status frame [20-51]
-	Module1 rawInverterModuleStatus
+// TotalEnergy returns the accumulated energy generated by all inverter modules, in kWh.
+func (s *InverterStatus) TotalEnergy() float64 {
+	var total float64
 
-	// module 1 status frame [52-83]
-	Module2 rawInverterModuleStatus
+	for _, module := range s.Modules {
+		total += module.TotalEnergy
+	}
 
-	// reserved [84-85]
-	_        byte
-	FrameEnd byte
+	return total
 }
 
-type rawInverterModuleStatus struct {
-	Id                   uint32
-	FirmwareVersionMajor uint8
-	FirmwareVersionMinor uint8
-	InputVoltageDC       uint16
-	OutputPowerAC        uint16
-	TotalEnergy          uint32
-	Temperature          uint16
-	OutputVoltageAC      uint16
-	OutputFrequencyAC    uint16
-
-	// padding
-	_ uint64
-	_ uint32
+// StatusFrameLen returns the length of a status frame carrying the given number of modules, for inverters using the
+// default frame layout.
+func StatusFrameLen(modules int) int {
+	return ProfileGeneric.FrameLen(modules)
 }
 
+// UnmarshalBinary decodes a status frame, using the profile detected from the frame length (see [DetectProfile]).
 func (s *InverterStatus) UnmarshalBinary(data []byte) error {
-	var payload rawInverterStatus
+	return DecodeStatus(data, nil, s)
+}
 
-	_, err := binary.Decode(data, binary.BigEndian, &payload)
+// MarshalBinary encodes the status as a status frame, inverting the scaling applied by UnmarshalBinary. The frame
+// layout is that of the profile matching the number of modules.
+//
+// Values are rounded to the resolution of the frame fields, so for any status s decoded with UnmarshalBinary, encoding
+// s and decoding the result again yields s. Reserved bytes are taken from s.Raw, or encoded as zeros if s.Raw is nil.
+func (s *InverterStatus) MarshalBinary() ([]byte, error) {
+	return EncodeStatus(s, nil)
+}
+
+// DecodeStatus decodes a status frame into s using the given profile. If profile is nil, the profile is detected from
+// the frame length.
+func DecodeStatus(data []byte, profile *Profile, s *InverterStatus) error {
+	var frame Frame
+
+	err := frame.UnmarshalBinary(data)
 	if err != nil {
 		return errors.Join(ErrStatusFrameDecodeFailure, err)
 	}
 
-	if payload.FrameEnd != 0x16 {
-		return errors.Join(ErrStatusFrameDecodeFailure, fmt.Errorf("(bug) unexpected frame end token [0x%x]", payload.FrameEnd))
+	if frame.Control != FrameControl {
+		return errors.Join(ErrStatusFrameDecodeFailure, fmt.Errorf("unexpected control byte [0x%x]", frame.Control))
 	}
 
-	s.InverterId = fmt.Sprintf("%x", payload.InverterId)
-	s.Module1.ModuleId = fmt.Sprintf("%x", payload.Module1.Id)
-	s.Module1.FirmwareVersion = fmt.Sprintf("%d/%d", payload.Module1.FirmwareVersionMajor, payload.Module1.FirmwareVersionMinor)
-	s.Module1.InputVoltageDC = float64(payload.Module1.InputVoltageDC) / 512.0
-	s.Module1.OutputPowerAC = float64(payload.Module1.OutputPowerAC) / 64.0
-	s.Module1.TotalEnergy = float64(payload.Module1.TotalEnergy) / 8192.0
-	s.Module1.Temperature = float64(payload.Module1.Temperature)/128.0 - 40.0
-	s.Module1.OutputVoltageAC = float64(payload.Module1.OutputVoltageAC) / 64.0
-	s.Module1.OutputFrequencyAC = float64(payload.Module1.OutputFrequencyAC) / 256.0
-
-	s.Module2.ModuleId = fmt.Sprintf("%x", payload.Module2.Id)
-	s.Module2.FirmwareVersion = fmt.Sprintf("%d/%d", payload.Module2.FirmwareVersionMajor, payload.Module2.FirmwareVersionMinor)
-	s.Module2.InputVoltageDC = float64(payload.Module2.InputVoltageDC) / 512.0
-	s.Module2.OutputPowerAC = float64(payload.Module2.OutputPowerAC) / 64.0
-	s.Module2.TotalEnergy = float64(payload.Module2.TotalEnergy) / 8192.0
-	s.Module2.Temperature = float64(payload.Module2.Temperature)/128.0 - 40.0
-	s.Module2.OutputVoltageAC = float64(payload.Module2.OutputVoltageAC) / 64.0
-	s.Module2.OutputFrequencyAC = float64(payload.Module2.OutputFrequencyAC) / 256.0
+	if frame.Command != CommandStatus && frame.Command != CommandPollResponse {
+		return errors.Join(ErrStatusFrameDecodeFailure, fmt.Errorf("unexpected command byte [0x%x]", frame.Command))
+	}
+
+	if profile == nil {
+		profile = DetectProfile(frame.Len())
+	}
+
+	err = profile.decode(data[:frame.Len()], s)
+	if err != nil {
+		return errors.Join(ErrStatusFrameDecodeFailure, err)
+	}
 
 	return nil
 }
+
+// EncodeStatus encodes s as a status frame using the given profile. If profile is nil, the profile is selected by the
+// number of modules.
+func EncodeStatus(s *InverterStatus, profile *Profile) ([]byte, error) {
+	if profile == nil {
+		profile = profileForModules(len(s.Modules))
+	}
+
+	payload, err := profile.encode(s)
+	if err != nil {
+		return nil, errors.Join(ErrStatusFrameEncodeFailure, err)
+	}
+
+	frame := Frame{
+		Control: FrameControl,
+		Command: CommandStatus,
+		Payload: payload,
+	}
+
+	return frame.MarshalBinary()
+}
+
+// Scale a value to its fixed-point representation, rejecting values that don't fit in [0, limit].
+func quantize(value, scale float64, limit uint64) (uint64, error) {
+	v := math.Round(value * scale)
+	if math.IsNaN(v) || v < 0 || v > float64(limit) {
+		return 0, fmt.Errorf("value out of range: %f", value)
+	}
+
+	return uint64(v), nil
+}
+
+// Parse an inverter or module ID (e.g. 31583078) into its 32-bit binary form.
+func parseHexId(id string) (uint32, error) {
+	v, err := strconv.ParseUint(id, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("illegal id %q: %w", id, err)
+	}
+
+	return uint32(v), nil
+}
@@ -0,0 +1,114 @@
+package types
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// RawInverterStatus holds the undecoded contents of a status frame: the raw (unscaled) values of decoded fields, and the
+// reserved bytes not covered by the model profile. This is mostly useful for reverse engineering the meaning of the
+// reserved bytes.
+type RawInverterStatus struct {
+	// Reserved bytes of the frame header, with offsets relative to the start of the frame.
+	Reserved []RawSpan
+
+	Modules []RawInverterModuleStatus
+}
+
+// RawInverterModuleStatus holds the undecoded contents of a module block of a status frame.
+type RawInverterModuleStatus struct {
+	// Raw (unscaled) values of the decoded fields, by field name (e.g. input_voltage_dc).
+	Fields map[string]uint64
+
+	// Reserved bytes of the module block, with offsets relative to the start of the module block.
+	Reserved []RawSpan
+}
+
+// RawSpan is a contiguous run of reserved bytes.
+type RawSpan struct {
+	Offset int
+	Data   HexBytes
+}
+
+// HexBytes is a byte slice represented as a hex string in text encodings (e.g. JSON).
+type HexBytes []byte
+
+func (b HexBytes) MarshalText() ([]byte, error) {
+	return hex.AppendEncode(nil, b), nil
+}
+
+func (b *HexBytes) UnmarshalText(text []byte) error {
+	data, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+
+	*b = data
+
+	return nil
+}
+
+func (b HexBytes) String() string {
+	return hex.EncodeToString(b)
+}
+
+// Words splits the span into big endian 16-bit words, keyed by the offset of each word. An odd trailing byte is
+// returned as a word of its own.
+func (s RawSpan) Words() map[int]uint16 {
+	words := map[int]uint16{}
+
+	for i := 0; i < len(s.Data); i += 2 {
+		if i+1 < len(s.Data) {
+			words[s.Offset+i] = uint16(s.Data[i])<<8 | uint16(s.Data[i+1])
+		} else {
+			words[s.Offset+i] = uint16(s.Data[i])
+		}
+	}
+
+	return words
+}
+
+// Find the runs of bytes in data not covered by any of the given fields, ignoring the first skip bytes.
+func reservedSpans(data []byte, skip int, fields ...Field) []RawSpan {
+	covered := make([]bool, len(data))
+
+	for i := range min(skip, len(data)) {
+		covered[i] = true
+	}
+
+	for _, f := range fields {
+		for i := f.Offset; i < f.Offset+f.Width && i < len(data); i++ {
+			covered[i] = true
+		}
+	}
+
+	var spans []RawSpan
+
+	for i := 0; i < len(data); i++ {
+		if covered[i] {
+			continue
+		}
+
+		start := i
+		for i < len(data) && !covered[i] {
+			i++
+		}
+
+		spans = append(spans, RawSpan{Offset: start, Data: HexBytes(append([]byte(nil), data[start:i]...))})
+	}
+
+	return spans
+}
+
+// Write reserved spans back into data.
+func putReservedSpans(data []byte, spans []RawSpan) error {
+	for _, span := range spans {
+		if span.Offset < 0 || span.Offset+len(span.Data) > len(data) {
+			return fmt.Errorf("reserved bytes out of bounds: offset %d, length %d", span.Offset, len(span.Data))
+		}
+
+		copy(data[span.Offset:], span.Data)
+	}
+
+	return nil
+}
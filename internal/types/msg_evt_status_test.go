@@ -1,18 +1,21 @@
 package types
 
 import (
-	"encoding/binary"
+	"bytes"
 	"errors"
+	"fmt"
 	"math"
+	"reflect"
 	"testing"
 )
 
 func TestInverterStatusUnmarshalBinary(t *testing.T) {
-	t.Run("raw struct should have binary size of 86", func(t *testing.T) {
-		var payload rawInverterStatus
-
-		if binary.Size(payload) != 86 {
-			t.Fatalf("unexpected struct binary size: %d", binary.Size(payload))
+	t.Run("default layout should have a status frame length of 86 for two modules", func(t *testing.T) {
+		if StatusFrameLen(2) != 86 {
+			t.Fatalf("unexpected status frame length: %d", StatusFrameLen(2))
+		}
+		if ProfileEVT800.FrameLen(2) != 86 || ProfileEVT400.FrameLen(1) != 54 {
+			t.Fatalf("unexpected profile frame lengths: %d, %d", ProfileEVT800.FrameLen(2), ProfileEVT400.FrameLen(1))
 		}
 	})
 
@@ -29,7 +32,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 				0x70, 0x79, 0x47, 0x94, 0x08, 0x4a, 0x00, 0x03,
 				0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x96, 0x32, 0x05,
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-				0x00, 0x00, 0x00, 0x00, 0x1f, 0x16,
+				0x00, 0x00, 0x00, 0x00, 0x51, 0x16,
 			},
 			{
 				0x68, 0x00, 0x56, 0x68, 0x10, 0x51, 0x30, 0x58,
@@ -42,7 +45,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 				0x70, 0x79, 0x47, 0x96, 0x08, 0x5e, 0x00, 0x03,
 				0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x9a, 0x32, 0x08,
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-				0x00, 0x00, 0x00, 0x00, 0x26, 0x16,
+				0x00, 0x00, 0x00, 0x00, 0x58, 0x16,
 			},
 			{
 				0x68, 0x00, 0x56, 0x68, 0x10, 0x04, 0x30, 0x58,
@@ -55,7 +58,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 				0x70, 0x79, 0x47, 0xa2, 0x08, 0x76, 0x00, 0x03,
 				0x2d, 0xb0, 0x21, 0x40, 0x3a, 0xa2, 0x32, 0x01,
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-				0x00, 0x00, 0x00, 0x00, 0x5c, 0x16,
+				0x00, 0x00, 0x00, 0x00, 0x8e, 0x16,
 			},
 			{
 				0x68, 0x00, 0x56, 0x68, 0x10, 0x51, 0x30, 0x58,
@@ -68,7 +71,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 				0x70, 0x79, 0x47, 0xba, 0x08, 0x92, 0x00, 0x03,
 				0x2d, 0xb0, 0x21, 0x40, 0x3a, 0xec, 0x32, 0x00,
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-				0x00, 0x00, 0x00, 0x00, 0xb1, 0x16,
+				0x00, 0x00, 0x00, 0x00, 0xe3, 0x16,
 			},
 			{
 				0x68, 0x00, 0x56, 0x68, 0x10, 0x04, 0x30, 0x58,
@@ -81,7 +84,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 				0x70, 0x79, 0x47, 0x90, 0x08, 0xbd, 0x00, 0x03,
 				0x2d, 0xb0, 0x21, 0x4c, 0x3b, 0x1d, 0x32, 0x00,
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-				0x00, 0x00, 0x00, 0x00, 0x3f, 0x16,
+				0x00, 0x00, 0x00, 0x00, 0x71, 0x16,
 			},
 			{
 				0x68, 0x00, 0x56, 0x68, 0x10, 0x51, 0x30, 0x58,
@@ -94,7 +97,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 				0x70, 0x79, 0x47, 0xa4, 0x08, 0xd1, 0x00, 0x03,
 				0x2d, 0xc7, 0x21, 0x59, 0x3b, 0x0f, 0x31, 0xf9,
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-				0x00, 0x00, 0x00, 0x00, 0xd7, 0x16,
+				0x00, 0x00, 0x00, 0x00, 0x09, 0x16,
 			},
 			{
 				0x68, 0x00, 0x56, 0x68, 0x10, 0x51, 0x30, 0x58,
@@ -107,7 +110,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 				0x70, 0x79, 0x47, 0x92, 0x08, 0x4a, 0x00, 0x03,
 				0x2d, 0xb0, 0x21, 0x33, 0x3a, 0xc3, 0x32, 0x07,
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-				0x00, 0x00, 0x00, 0x00, 0xad, 0x16,
+				0x00, 0x00, 0x00, 0x00, 0xdf, 0x16,
 			},
 		}
 
@@ -119,12 +122,14 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 			}
 
 			switch {
+			case len(s.Modules) != 2:
+				t.Fatalf("unexpected module count: %d", len(s.Modules))
 			case s.InverterId != "30587612":
 				t.Fatalf("unexpected inverter serial: %s", s.InverterId)
-			case s.Module1.ModuleId != "30587612":
-				t.Fatalf("unexpected module 1 id: %s", s.Module1.ModuleId)
-			case s.Module2.ModuleId != "30587613":
-				t.Fatalf("unexpected module 2 id: %s", s.Module2.ModuleId)
+			case s.Modules[0].ModuleId != "30587612":
+				t.Fatalf("unexpected module 1 id: %s", s.Modules[0].ModuleId)
+			case s.Modules[1].ModuleId != "30587613":
+				t.Fatalf("unexpected module 2 id: %s", s.Modules[1].ModuleId)
 			}
 		}
 	})
@@ -141,7 +146,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 			0x70, 0x79, 0x47, 0x94, 0x08, 0x4a, 0x00, 0x03,
 			0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x96, 0x32, 0x05,
 			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-			0x00, 0x00, 0x00, 0x00, 0x1f, 0x16,
+			0x00, 0x00, 0x00, 0x00, 0x51, 0x16,
 		}
 
 		s := &InverterStatus{}
@@ -153,40 +158,42 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 		epsilon := 0.001
 
 		switch {
+		case len(s.Modules) != 2:
+			t.Fatalf("unexpected module count: %d", len(s.Modules))
 		case s.InverterId != "30587612":
 			t.Fatalf("unexpected inverter serial: %s", s.InverterId)
-		case s.Module1.ModuleId != "30587612":
-			t.Fatalf("unexpected module1 id: %s", s.Module1.ModuleId)
-		case s.Module1.FirmwareVersion != "112/121":
-			t.Fatalf("unexpected module1 firmware: %s", s.Module1.FirmwareVersion)
-		case math.Abs(s.Module1.InputVoltageDC-34.511719) > epsilon:
-			t.Fatalf("unexpected module1 input voltage dc: %f", s.Module1.InputVoltageDC)
-		case math.Abs(s.Module1.OutputPowerAC-41.187500) > epsilon:
-			t.Fatalf("unexpected module1 output power ac: %f", s.Module1.OutputPowerAC)
-		case math.Abs(s.Module1.TotalEnergy-30.495361) > epsilon:
-			t.Fatalf("unexpected module1 total energy: %f", s.Module1.TotalEnergy)
-		case math.Abs(s.Module1.Temperature-26) > epsilon:
-			t.Fatalf("unexpected module1 temp: %f", s.Module1.Temperature)
-		case math.Abs(s.Module1.OutputVoltageAC-234.343750) > epsilon:
-			t.Fatalf("unexpected module1 output voltage ac: %f", s.Module1.OutputVoltageAC)
-		case math.Abs(s.Module1.OutputFrequencyAC-50.019531) > epsilon:
-			t.Fatalf("unexpected module1 frequency ac: %f", s.Module1.OutputFrequencyAC)
-		case s.Module2.ModuleId != "30587613":
-			t.Fatalf("unexpected module2 id: %s", s.Module2.ModuleId)
-		case s.Module2.FirmwareVersion != "112/121":
-			t.Fatalf("unexpected module2 firmware: %s", s.Module2.FirmwareVersion)
-		case math.Abs(s.Module2.InputVoltageDC-35.789062) > epsilon:
-			t.Fatalf("unexpected module2 input voltage dc: %f", s.Module2.InputVoltageDC)
-		case math.Abs(s.Module2.OutputPowerAC-33.156250) > epsilon:
-			t.Fatalf("unexpected module2 output power ac: %f", s.Module2.OutputPowerAC)
-		case math.Abs(s.Module2.TotalEnergy-25.427734) > epsilon:
-			t.Fatalf("unexpected module2 total energy: %f", s.Module2.TotalEnergy)
-		case math.Abs(s.Module2.Temperature-26.398438) > epsilon:
-			t.Fatalf("unexpected module2 temp: %f", s.Module2.Temperature)
-		case math.Abs(s.Module2.OutputVoltageAC-234.343750) > epsilon:
-			t.Fatalf("unexpected module2 output voltage ac: %f", s.Module2.OutputVoltageAC)
-		case math.Abs(s.Module2.OutputFrequencyAC-50.019531) > epsilon:
-			t.Fatalf("unexpected module2 frequency ac: %f", s.Module2.OutputFrequencyAC)
+		case s.Modules[0].ModuleId != "30587612":
+			t.Fatalf("unexpected module1 id: %s", s.Modules[0].ModuleId)
+		case s.Modules[0].FirmwareVersion != "112/121":
+			t.Fatalf("unexpected module1 firmware: %s", s.Modules[0].FirmwareVersion)
+		case math.Abs(s.Modules[0].InputVoltageDC-34.511719) > epsilon:
+			t.Fatalf("unexpected module1 input voltage dc: %f", s.Modules[0].InputVoltageDC)
+		case math.Abs(s.Modules[0].OutputPowerAC-41.187500) > epsilon:
+			t.Fatalf("unexpected module1 output power ac: %f", s.Modules[0].OutputPowerAC)
+		case math.Abs(s.Modules[0].TotalEnergy-30.495361) > epsilon:
+			t.Fatalf("unexpected module1 total energy: %f", s.Modules[0].TotalEnergy)
+		case math.Abs(s.Modules[0].Temperature-26) > epsilon:
+			t.Fatalf("unexpected module1 temp: %f", s.Modules[0].Temperature)
+		case math.Abs(s.Modules[0].OutputVoltageAC-234.343750) > epsilon:
+			t.Fatalf("unexpected module1 output voltage ac: %f", s.Modules[0].OutputVoltageAC)
+		case math.Abs(s.Modules[0].OutputFrequencyAC-50.019531) > epsilon:
+			t.Fatalf("unexpected module1 frequency ac: %f", s.Modules[0].OutputFrequencyAC)
+		case s.Modules[1].ModuleId != "30587613":
+			t.Fatalf("unexpected module2 id: %s", s.Modules[1].ModuleId)
+		case s.Modules[1].FirmwareVersion != "112/121":
+			t.Fatalf("unexpected module2 firmware: %s", s.Modules[1].FirmwareVersion)
+		case math.Abs(s.Modules[1].InputVoltageDC-35.789062) > epsilon:
+			t.Fatalf("unexpected module2 input voltage dc: %f", s.Modules[1].InputVoltageDC)
+		case math.Abs(s.Modules[1].OutputPowerAC-33.156250) > epsilon:
+			t.Fatalf("unexpected module2 output power ac: %f", s.Modules[1].OutputPowerAC)
+		case math.Abs(s.Modules[1].TotalEnergy-25.427734) > epsilon:
+			t.Fatalf("unexpected module2 total energy: %f", s.Modules[1].TotalEnergy)
+		case math.Abs(s.Modules[1].Temperature-26.398438) > epsilon:
+			t.Fatalf("unexpected module2 temp: %f", s.Modules[1].Temperature)
+		case math.Abs(s.Modules[1].OutputVoltageAC-234.343750) > epsilon:
+			t.Fatalf("unexpected module2 output voltage ac: %f", s.Modules[1].OutputVoltageAC)
+		case math.Abs(s.Modules[1].OutputFrequencyAC-50.019531) > epsilon:
+			t.Fatalf("unexpected module2 frequency ac: %f", s.Modules[1].OutputFrequencyAC)
 		}
 	})
 
@@ -202,7 +209,7 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 			0x70, 0x79, 0x47, 0x94, 0x08, 0x4a, 0x00, 0x03,
 			0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x96, 0x32, 0x05,
 			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-			0x00, 0x00, 0x00, 0x00, 0x1f, 0x16, 0x32, 0x05,
+			0x00, 0x00, 0x00, 0x00, 0x51, 0x16, 0x32, 0x05,
 			0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x96, 0x32, 0x05,
 		}
 
@@ -225,7 +232,57 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 			0x70, 0x79, 0x47, 0x94, 0x08, 0x4a, 0x00, 0x03,
 			0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x96, 0x32, 0x05,
 			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-			0x00, 0x00, 0x00, 0x00, 0x1f, 0x17,
+			0x00, 0x00, 0x00, 0x00, 0x51, 0x17,
+		}
+
+		s := &InverterStatus{}
+		err := s.UnmarshalBinary(msg)
+		if err == nil {
+			t.Fatalf("expected error but was nil")
+		}
+		if !errors.Is(err, ErrStatusFrameDecodeFailure) {
+			t.Fatalf("expected error: %v", err)
+		}
+	})
+
+	t.Run("should return error if checksum invalid", func(t *testing.T) {
+		msg := []byte{
+			0x68, 0x00, 0x56, 0x68, 0x10, 0x51, 0x30, 0x58,
+			0x76, 0x12, 0x70, 0x01, 0x79, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x30, 0x58, 0x76, 0x12,
+			0x70, 0x79, 0x45, 0x06, 0x0a, 0x4c, 0x00, 0x03,
+			0xcf, 0xda, 0x21, 0x00, 0x3a, 0x96, 0x32, 0x05,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x30, 0x58, 0x76, 0x13,
+			0x70, 0x79, 0x47, 0x94, 0x08, 0x4a, 0x00, 0x03,
+			0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x96, 0x32, 0x05,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x1f, 0x16,
+		}
+
+		s := &InverterStatus{}
+		err := s.UnmarshalBinary(msg)
+		if err == nil {
+			t.Fatalf("expected error but was nil")
+		}
+		if !errors.Is(err, ErrStatusFrameDecodeFailure) {
+			t.Fatalf("expected error: %v", err)
+		}
+	})
+
+	t.Run("should return error if command invalid", func(t *testing.T) {
+		msg := []byte{
+			0x68, 0x00, 0x56, 0x68, 0x10, 0x77, 0x30, 0x58,
+			0x76, 0x12, 0x70, 0x01, 0x79, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x30, 0x58, 0x76, 0x12,
+			0x70, 0x79, 0x45, 0x06, 0x0a, 0x4c, 0x00, 0x03,
+			0xcf, 0xda, 0x21, 0x00, 0x3a, 0x96, 0x32, 0x05,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x30, 0x58, 0x76, 0x13,
+			0x70, 0x79, 0x47, 0x94, 0x08, 0x4a, 0x00, 0x03,
+			0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x96, 0x32, 0x05,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x77, 0x16,
 		}
 
 		s := &InverterStatus{}
@@ -238,6 +295,24 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 		}
 	})
 
+	t.Run("should return error if frame length doesn't match a whole number of modules", func(t *testing.T) {
+		for _, size := range []int{0, 31, 33, 64 + 7} {
+			frame := Frame{
+				Control: FrameControl,
+				Command: CommandStatus,
+				Payload: make([]byte, StatusFrameLen(0)-FrameMinLen+size),
+			}
+
+			msg, _ := frame.MarshalBinary()
+
+			s := &InverterStatus{}
+			err := s.UnmarshalBinary(msg)
+			if !errors.Is(err, ErrStatusFrameDecodeFailure) {
+				t.Fatalf("expected decode failure for module block of %d bytes but was: %v", size, err)
+			}
+		}
+	})
+
 	t.Run("should return error if data length invalid", func(t *testing.T) {
 		msg := []byte{
 			0x68, 0x00, 0x56, 0x68, 0x10, 0x51, 0x30, 0x58,
@@ -262,3 +337,230 @@ func TestInverterStatusUnmarshalBinary(t *testing.T) {
 		}
 	})
 }
+
+func TestInverterStatusMarshalBinary(t *testing.T) {
+	t.Run("should round-trip decoded status frames", func(t *testing.T) {
+		msg := []byte{
+			0x68, 0x00, 0x56, 0x68, 0x10, 0x04, 0x30, 0x58,
+			0x76, 0x12, 0x70, 0x01, 0x79, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x30, 0x58, 0x76, 0x12,
+			0x70, 0x79, 0x45, 0x30, 0x0a, 0xdb, 0x00, 0x03,
+			0xcf, 0xda, 0x21, 0x26, 0x3b, 0x1d, 0x32, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x30, 0x58, 0x76, 0x13,
+			0x70, 0x79, 0x47, 0x90, 0x08, 0xbd, 0x00, 0x03,
+			0x2d, 0xb0, 0x21, 0x4c, 0x3b, 0x1d, 0x32, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x71, 0x16,
+		}
+
+		var decoded InverterStatus
+		if err := decoded.UnmarshalBinary(msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		encoded, err := decoded.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var result InverterStatus
+		if err := result.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(result, decoded) {
+			t.Fatalf("unexpected status: %+v", result)
+		}
+
+		// reserved bytes are retained, so the frame is reproduced exactly
+		if !bytes.Equal(encoded, msg) {
+			t.Fatalf("unexpected frame: %x", encoded)
+		}
+	})
+
+	t.Run("should round values to field resolution", func(t *testing.T) {
+		status := InverterStatus{
+			InverterId: "31583078",
+			Modules: []InverterModuleStatus{{
+				ModuleId:          "31583078",
+				FirmwareVersion:   "112/121",
+				InputVoltageDC:    34.5,
+				OutputPowerAC:     41.19,
+				TotalEnergy:       30.4954,
+				Temperature:       -12.3,
+				OutputVoltageAC:   234.34,
+				OutputFrequencyAC: 50.02,
+			}, {
+				ModuleId:        "31583079",
+				FirmwareVersion: "1/2",
+			}},
+		}
+
+		encoded, err := status.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var result InverterStatus
+		if err := result.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		epsilon := 0.01
+
+		switch {
+		case len(result.Modules) != 2:
+			t.Fatalf("unexpected module count: %d", len(result.Modules))
+		case result.InverterId != status.InverterId:
+			t.Fatalf("unexpected inverter serial: %s", result.InverterId)
+		case result.Modules[0].FirmwareVersion != status.Modules[0].FirmwareVersion:
+			t.Fatalf("unexpected module1 firmware: %s", result.Modules[0].FirmwareVersion)
+		case math.Abs(result.Modules[0].InputVoltageDC-status.Modules[0].InputVoltageDC) > epsilon:
+			t.Fatalf("unexpected module1 input voltage dc: %f", result.Modules[0].InputVoltageDC)
+		case math.Abs(result.Modules[0].OutputPowerAC-status.Modules[0].OutputPowerAC) > epsilon:
+			t.Fatalf("unexpected module1 output power ac: %f", result.Modules[0].OutputPowerAC)
+		case math.Abs(result.Modules[0].TotalEnergy-status.Modules[0].TotalEnergy) > epsilon:
+			t.Fatalf("unexpected module1 total energy: %f", result.Modules[0].TotalEnergy)
+		case math.Abs(result.Modules[0].Temperature-status.Modules[0].Temperature) > epsilon:
+			t.Fatalf("unexpected module1 temp: %f", result.Modules[0].Temperature)
+		case math.Abs(result.Modules[0].OutputVoltageAC-status.Modules[0].OutputVoltageAC) > epsilon:
+			t.Fatalf("unexpected module1 output voltage ac: %f", result.Modules[0].OutputVoltageAC)
+		case math.Abs(result.Modules[0].OutputFrequencyAC-status.Modules[0].OutputFrequencyAC) > epsilon:
+			t.Fatalf("unexpected module1 frequency ac: %f", result.Modules[0].OutputFrequencyAC)
+		case result.Modules[1].ModuleId != status.Modules[1].ModuleId:
+			t.Fatalf("unexpected module2 id: %s", result.Modules[1].ModuleId)
+		}
+
+		// a second pass must be lossless
+		again, err := result.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(again, encoded) {
+			t.Fatalf("unexpected frame: %x", again)
+		}
+	})
+
+	t.Run("should encode any number of modules", func(t *testing.T) {
+		for count := 1; count <= 4; count++ {
+			status := InverterStatus{InverterId: "31583078"}
+
+			for i := range count {
+				status.Modules = append(status.Modules, InverterModuleStatus{
+					ModuleId:        fmt.Sprintf("%x", 0x31583078+i),
+					FirmwareVersion: "112/121",
+					OutputPowerAC:   float64(i) * 10,
+				})
+			}
+
+			encoded, err := status.MarshalBinary()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(encoded) != StatusFrameLen(count) || encoded[2] != byte(StatusFrameLen(count)) {
+				t.Fatalf("unexpected frame length for %d modules: %d", count, len(encoded))
+			}
+
+			var result InverterStatus
+			if err := result.UnmarshalBinary(encoded); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.InverterId != status.InverterId || !reflect.DeepEqual(result.Modules, status.Modules) {
+				t.Fatalf("unexpected status: %+v", result)
+			}
+			if result.TotalOutputPowerAC() != float64(count*(count-1)/2*10) {
+				t.Fatalf("unexpected total power: %f", result.TotalOutputPowerAC())
+			}
+		}
+	})
+
+	t.Run("should reject values that can't be encoded", func(t *testing.T) {
+		valid := InverterModuleStatus{ModuleId: "1", FirmwareVersion: "1/1"}
+
+		illegal := []InverterStatus{
+			{InverterId: "1"},
+			{InverterId: "", Modules: []InverterModuleStatus{valid}},
+			{InverterId: "1", Modules: []InverterModuleStatus{{ModuleId: "xyz", FirmwareVersion: "1/1"}}},
+			{InverterId: "1", Modules: []InverterModuleStatus{{ModuleId: "1", FirmwareVersion: "1.1"}}},
+			{InverterId: "1", Modules: []InverterModuleStatus{{ModuleId: "1", FirmwareVersion: "1/1", OutputPowerAC: -1}}},
+			{InverterId: "1", Modules: []InverterModuleStatus{valid, {ModuleId: "1", FirmwareVersion: "1/1", InputVoltageDC: 1000}}},
+			{InverterId: "1", Modules: []InverterModuleStatus{valid, {ModuleId: "1", FirmwareVersion: "1/1", Temperature: -41}}},
+		}
+
+		for _, status := range illegal {
+			_, err := status.MarshalBinary()
+			if err == nil {
+				t.Fatalf("expected error but was nil for status: %+v", status)
+			}
+			if !errors.Is(err, ErrStatusFrameEncodeFailure) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	})
+}
+
+func TestInverterStatusRaw(t *testing.T) {
+	msg := []byte{
+		0x68, 0x00, 0x56, 0x68, 0x10, 0x51, 0x30, 0x58,
+		0x76, 0x12, 0x70, 0x01, 0x79, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x30, 0x58, 0x76, 0x12,
+		0x70, 0x79, 0x45, 0x06, 0x0a, 0x4c, 0x00, 0x03,
+		0xcf, 0xda, 0x21, 0x00, 0x3a, 0x96, 0x32, 0x05,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x30, 0x58, 0x76, 0x13,
+		0x70, 0x79, 0x47, 0x94, 0x08, 0x4a, 0x00, 0x03,
+		0x2d, 0xb0, 0x21, 0x33, 0x3a, 0x96, 0x32, 0x05,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xab,
+		0xcd, 0x00, 0x00, 0x00, 0xc9, 0x16,
+	}
+
+	t.Run("should keep raw field values and reserved bytes", func(t *testing.T) {
+		s := &InverterStatus{}
+		if err := s.UnmarshalBinary(msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if s.Raw == nil || len(s.Raw.Modules) != 2 {
+			t.Fatalf("unexpected raw status: %+v", s.Raw)
+		}
+
+		switch {
+		case len(s.Raw.Reserved) != 1 || s.Raw.Reserved[0].Offset != 10:
+			t.Fatalf("unexpected reserved header bytes: %+v", s.Raw.Reserved)
+		case s.Raw.Reserved[0].Data.String() != "70017900000000000000":
+			t.Fatalf("unexpected reserved header bytes: %s", s.Raw.Reserved[0].Data)
+		case s.Raw.Modules[0].Fields["input_voltage_dc"] != 0x4506:
+			t.Fatalf("unexpected raw input voltage dc: %x", s.Raw.Modules[0].Fields["input_voltage_dc"])
+		case s.Raw.Modules[1].Fields["total_energy"] != 0x00032db0:
+			t.Fatalf("unexpected raw total energy: %x", s.Raw.Modules[1].Fields["total_energy"])
+		case len(s.Raw.Modules[1].Reserved) != 1 || s.Raw.Modules[1].Reserved[0].Offset != 20:
+			t.Fatalf("unexpected reserved module bytes: %+v", s.Raw.Modules[1].Reserved)
+		case s.Raw.Modules[1].Reserved[0].Data.String() != "00000000000000abcd000000":
+			t.Fatalf("unexpected reserved module bytes: %s", s.Raw.Modules[1].Reserved[0].Data)
+		}
+
+		words := s.Raw.Modules[1].Reserved[0].Words()
+		if len(words) != 6 || words[26] != 0x00ab || words[28] != 0xcd00 {
+			t.Fatalf("unexpected reserved words: %v", words)
+		}
+	})
+
+	t.Run("should encode reserved bytes back into the frame", func(t *testing.T) {
+		s := &InverterStatus{}
+		if err := s.UnmarshalBinary(msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		encoded, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// the command byte (and therefore checksum) is not retained
+		if !bytes.Equal(encoded[6:84], msg[6:84]) {
+			t.Fatalf("unexpected frame: %x", encoded)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Backfill collects snapshots of the metrics at past points in time (e.g. while replaying a capture), and writes them
+// in the OpenMetrics text format with their timestamps, so that they can be imported into Prometheus with
+// 'promtool tsdb create-blocks-from openmetrics'.
+//
+// OpenMetrics requires all samples of a metric family, and of each series within it, to be written together and in
+// order, so snapshots are kept in memory until written.
+type Backfill struct {
+	// Include raw field values of status frames (openevt_module_raw_field).
+	EnableRawMetrics bool
+
+	families map[string]*dto.MetricFamily
+	series   map[string][][]*dto.Metric
+	index    map[string]int
+}
+
+// Snapshot records the current value of the metrics updated with the status of the inverter at addr (see [UpdateAt])
+// at the given time. Metrics of other inverters, and of modules not in the status, are left out. Snapshots of an
+// inverter must be taken in chronological order; samples not taken after the previous sample of their series are
+// ignored.
+func (b *Backfill) Snapshot(ts time.Time, addr string, status *types.InverterStatus) error {
+	gatherers := prometheus.Gatherers{reg}
+
+	if b.EnableRawMetrics {
+		raw := prometheus.NewRegistry()
+		raw.MustRegister(moduleRawField)
+
+		gatherers = append(gatherers, raw)
+	}
+
+	families, err := gatherers.Gather()
+	if err != nil {
+		return err
+	}
+
+	if b.families == nil {
+		b.families = map[string]*dto.MetricFamily{}
+		b.series = map[string][][]*dto.Metric{}
+		b.index = map[string]int{}
+	}
+
+	ms := ts.UnixMilli()
+
+	for _, family := range families {
+		name := family.GetName()
+
+		for _, metric := range family.Metric {
+			if !updatedBy(metric, addr, status) {
+				continue
+			}
+
+			if _, ok := b.families[name]; !ok {
+				b.families[name] = &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type, Unit: family.Unit}
+			}
+
+			metric.TimestampMs = &ms
+
+			key := seriesKey(name, metric)
+
+			i, ok := b.index[key]
+			if !ok {
+				i = len(b.series[name])
+				b.index[key] = i
+				b.series[name] = append(b.series[name], nil)
+			}
+
+			samples := b.series[name][i]
+			if len(samples) > 0 && samples[len(samples)-1].GetTimestampMs() >= ms {
+				continue
+			}
+
+			b.series[name][i] = append(samples, metric)
+		}
+	}
+
+	return nil
+}
+
+// WriteTo writes all snapshots in the OpenMetrics text format, terminated by '# EOF'.
+func (b *Backfill) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+
+	names := make([]string, 0, len(b.families))
+	for name := range b.families {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		family := b.families[name]
+		family.Metric = slices.Concat(b.series[name]...)
+
+		written, err := expfmt.MetricFamilyToOpenMetrics(w, family)
+		n += int64(written)
+
+		if err != nil {
+			return n, err
+		}
+	}
+
+	written, err := expfmt.FinalizeOpenMetrics(w)
+	n += int64(written)
+
+	return n, err
+}
+
+// Check whether the series was updated with the status of the inverter at addr.
+func updatedBy(metric *dto.Metric, addr string, status *types.InverterStatus) bool {
+	labels := map[string]string{}
+	for _, label := range metric.Label {
+		labels[label.GetName()] = label.GetValue()
+	}
+
+	if labels["addr"] != addr || labels["sn"] != status.InverterId {
+		return false
+	}
+
+	id, ok := labels["module_id"]
+	if !ok {
+		return true
+	}
+
+	// leave out series of modules missing from the status, and of firmware versions they reported before
+	return slices.ContainsFunc(status.Modules, func(module types.InverterModuleStatus) bool {
+		if version, ok := labels["firmware_version"]; ok && version != module.FirmwareVersion {
+			return false
+		}
+
+		return module.ModuleId == id
+	})
+}
+
+// Identify a series of the metric family by its labels.
+func seriesKey(name string, metric *dto.Metric) string {
+	var sb strings.Builder
+
+	sb.WriteString(name)
+
+	for _, label := range metric.Label {
+		sb.WriteString("\xff")
+		sb.WriteString(label.GetName())
+		sb.WriteString("=")
+		sb.WriteString(label.GetValue())
+	}
+
+	return sb.String()
+}
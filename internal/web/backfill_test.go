@@ -0,0 +1,116 @@
+package web
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+func TestBackfill(t *testing.T) {
+	t.Run("should write snapshots as timestamped OpenMetrics samples, grouped by series", func(t *testing.T) {
+		var backfill Backfill
+
+		ts := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		for i, power := range []float64{40, 60, 80} {
+			status := &types.InverterStatus{
+				InverterId: "30587612",
+				Modules:    []types.InverterModuleStatus{{ModuleId: "30587612", OutputPowerAC: power}},
+			}
+
+			UpdateAt("192.0.2.77", status, ts.Add(time.Duration(i)*time.Minute))
+
+			if err := backfill.Snapshot(ts.Add(time.Duration(i)*time.Minute), "192.0.2.77", status); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		// snapshots out of order are ignored
+		if err := backfill.Snapshot(ts, "192.0.2.77", &types.InverterStatus{InverterId: "30587612"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var buf bytes.Buffer
+
+		if _, err := backfill.WriteTo(&buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var samples []string
+
+		for line := range strings.Lines(buf.String()) {
+			if strings.HasPrefix(line, "openevt_module_output_power_ac{") && strings.Contains(line, `sn="30587612"`) {
+				samples = append(samples, line[strings.LastIndex(line, "} ")+2:])
+			}
+		}
+
+		expected := []string{"40.0 1.7172432e+09\n", "60.0 1.71724326e+09\n", "80.0 1.71724332e+09\n"}
+
+		if strings.Join(samples, "") != strings.Join(expected, "") {
+			t.Fatalf("unexpected samples: %q", samples)
+		}
+
+		if !strings.HasSuffix(buf.String(), "# EOF\n") {
+			t.Fatalf("missing EOF marker")
+		}
+	})
+	t.Run("should only write samples of the inverter updated at the time of the snapshot", func(t *testing.T) {
+		var backfill Backfill
+
+		ts := time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)
+
+		updates := []struct {
+			addr  string
+			sn    string
+			power float64
+		}{
+			{"192.0.2.80", "31583001", 10},
+			{"192.0.2.81", "31583002", 20},
+			{"192.0.2.80", "31583001", 30},
+			{"192.0.2.80", "31583001", 50},
+		}
+
+		for i, update := range updates {
+			status := &types.InverterStatus{
+				InverterId: update.sn,
+				Modules:    []types.InverterModuleStatus{{ModuleId: update.sn, OutputPowerAC: update.power}},
+			}
+
+			UpdateAt(update.addr, status, ts.Add(time.Duration(i)*time.Minute))
+
+			if err := backfill.Snapshot(ts.Add(time.Duration(i)*time.Minute), update.addr, status); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		var buf bytes.Buffer
+
+		if _, err := backfill.WriteTo(&buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		samples := map[string][]string{}
+
+		for line := range strings.Lines(buf.String()) {
+			if !strings.HasPrefix(line, "openevt_module_output_power_ac{") {
+				continue
+			}
+
+			for _, sn := range []string{"31583001", "31583002"} {
+				if strings.Contains(line, `sn="`+sn+`"`) {
+					samples[sn] = append(samples[sn], line[strings.LastIndex(line, "} ")+2:])
+				}
+			}
+		}
+
+		switch {
+		case strings.Join(samples["31583001"], "") != "10.0 1.7173296e+09\n30.0 1.71732972e+09\n50.0 1.71732978e+09\n":
+			t.Fatalf("unexpected samples: %q", samples["31583001"])
+		case strings.Join(samples["31583002"], "") != "20.0 1.71732966e+09\n":
+			t.Fatalf("unexpected samples: %q", samples["31583002"])
+		}
+	})
+}
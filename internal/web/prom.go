@@ -1,11 +1,14 @@
 package web
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
+	"github.com/brandon1024/OpenEVT/internal/admin"
 	"github.com/brandon1024/OpenEVT/internal/types"
 )
 
@@ -22,14 +25,50 @@ var (
 	power = promauto.With(reg).NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "openevt_power_ac",
-			Help: "Total instantaneous power (AC) of both inverter modules, in W.",
+			Help: "Total instantaneous power (AC) of all inverter modules, in W.",
 		},
 		[]string{"addr", "sn"},
 	)
 	energy = promauto.With(reg).NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "openevt_energy",
-			Help: "Total accumulated energy generated by both inverter modules, in kWh.",
+			Help: "Total accumulated energy generated by all inverter modules, in kWh.",
+		},
+		[]string{"addr", "sn"},
+	)
+	lastUpdate = promauto.With(reg).NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openevt_last_update_timestamp_seconds",
+			Help: "Time the last status frame was received from the inverter, in seconds since the epoch.",
+		},
+		[]string{"addr", "sn"},
+	)
+
+	wifiRSSI = promauto.With(reg).NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openevt_wifi_rssi_dbm",
+			Help: "Signal strength of the Wi-Fi network the inverter joined, in dBm.",
+		},
+		[]string{"addr", "sn"},
+	)
+	wifiInfo = promauto.With(reg).NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openevt_wifi_info",
+			Help: "Network settings of the Wi-Fi module of the inverter, always 1.",
+		},
+		[]string{"addr", "sn", "ssid", "sta_ip", "firmware", "mode"},
+	)
+	wifiScrapeSuccess = promauto.With(reg).NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openevt_wifi_scrape_success",
+			Help: "Whether the last scrape of the admin web interface of the inverter succeeded (0-failed, 1-succeeded).",
+		},
+		[]string{"addr", "sn"},
+	)
+	wifiLastScrape = promauto.With(reg).NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openevt_wifi_last_scrape_timestamp_seconds",
+			Help: "Time of the last successful scrape of the admin web interface of the inverter, in seconds since the epoch.",
 		},
 		[]string{"addr", "sn"},
 	)
@@ -76,25 +115,73 @@ var (
 		},
 		[]string{"addr", "sn", "module_id", "firmware_version"},
 	)
+
+	// registered on demand, see ListenAndServe
+	moduleRawField = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "openevt_module_raw_field",
+			Help: "Raw (unscaled) value of a field or reserved 16-bit word of a module status frame.",
+		},
+		[]string{"addr", "sn", "module_id", "field"},
+	)
 )
 
+// A status received from an inverter, the address it was received from and the time it was received.
+type inverterUpdate struct {
+	types.InverterStatus
+
+	Address   string
+	Timestamp time.Time
+}
+
+// Status of the Wi-Fi module of an inverter, and the time it was scraped.
+type wifiUpdate struct {
+	admin.Status
+
+	Timestamp time.Time
+}
+
 var (
-	inverter    types.InverterStatus
+	// last status received, from any inverter
+	inverter inverterUpdate
+
+	// last status received from each inverter, by serial number
+	inverters   = map[string]inverterUpdate{}
 	inverterMux sync.RWMutex
+
+	// last status of the Wi-Fi module of each inverter, by serial number
+	wifi = map[string]wifiUpdate{}
 )
 
-func get() types.InverterStatus {
+func get() inverterUpdate {
 	inverterMux.RLock()
 	defer inverterMux.RUnlock()
 
 	return inverter
 }
 
-func set(status types.InverterStatus) {
+func getBySerial(sn string) (inverterUpdate, bool) {
+	inverterMux.RLock()
+	defer inverterMux.RUnlock()
+
+	update, ok := inverters[sn]
+	return update, ok
+}
+
+func getWiFi(sn string) (wifiUpdate, bool) {
+	inverterMux.RLock()
+	defer inverterMux.RUnlock()
+
+	update, ok := wifi[sn]
+	return update, ok
+}
+
+func set(addr string, status types.InverterStatus, ts time.Time) {
 	inverterMux.Lock()
 	defer inverterMux.Unlock()
 
-	inverter = status
+	inverter = inverterUpdate{InverterStatus: status, Address: addr, Timestamp: ts}
+	inverters[status.InverterId] = inverter
 }
 
 func UpdateConnectionStatus(addr, sn string, status float64) {
@@ -106,19 +193,87 @@ func UpdateConnectionStatus(addr, sn string, status float64) {
 	connected.With(labels).Set(status)
 }
 
+// Remove all metrics of the inverter at a previous address, once it's known to have moved to another address (e.g.
+// after its DHCP lease changed).
+func RemoveAddress(addr, sn string) {
+	labels := prometheus.Labels{
+		"addr": addr,
+		"sn":   sn,
+	}
+
+	for _, vec := range []*prometheus.GaugeVec{
+		connected, power, energy, lastUpdate, wifiRSSI, wifiInfo, wifiScrapeSuccess, wifiLastScrape,
+		moduleInputVoltageDC, moduleOutputPowerAC, moduleTotalEnergy, moduleTemperature, moduleOutputVoltageAC,
+		moduleOutputFrequencyAC, moduleRawField,
+	} {
+		vec.DeletePartialMatch(labels)
+	}
+}
+
+// Update the metrics of the Wi-Fi module of the inverter with a status scraped from its admin web interface at the
+// given time.
+func UpdateWiFi(addr, sn string, status *admin.Status, ts time.Time) {
+	labels := prometheus.Labels{
+		"addr": addr,
+		"sn":   sn,
+	}
+
+	inverterMux.Lock()
+	wifi[sn] = wifiUpdate{Status: *status, Timestamp: ts}
+	inverterMux.Unlock()
+
+	wifiScrapeSuccess.With(labels).Set(1)
+	wifiLastScrape.With(labels).Set(float64(ts.UnixNano()) / 1e9)
+
+	if status.RSSI != 0 {
+		wifiRSSI.With(labels).Set(float64(status.RSSI))
+	} else {
+		// not connected to a Wi-Fi network (e.g. only reachable on its access point)
+		wifiRSSI.Delete(labels)
+	}
+
+	// settings may have changed since the last scrape
+	wifiInfo.DeletePartialMatch(labels)
+	wifiInfo.With(prometheus.Labels{
+		"addr":     addr,
+		"sn":       sn,
+		"ssid":     status.SSID,
+		"sta_ip":   status.IPAddress,
+		"firmware": status.Firmware,
+		"mode":     status.WorkMode,
+	}).Set(1)
+}
+
+// Record that the admin web interface of the inverter couldn't be scraped.
+func UpdateWiFiFailure(addr, sn string) {
+	wifiScrapeSuccess.With(prometheus.Labels{"addr": addr, "sn": sn}).Set(0)
+}
+
+// Update the metrics of the inverter with a status received just now.
 func Update(addr string, status *types.InverterStatus) {
+	UpdateAt(addr, status, time.Now())
+}
+
+// Update the metrics of the inverter with a status received at the given time (e.g. when replaying a capture).
+func UpdateAt(addr string, status *types.InverterStatus, ts time.Time) {
 	labels := prometheus.Labels{
 		"addr": addr,
 		"sn":   status.InverterId,
 	}
 
-	set(*status)
+	set(addr, *status, ts)
+
+	lastUpdate.With(labels).Set(float64(ts.UnixNano()) / 1e9)
+	power.With(labels).Set(status.TotalOutputPowerAC())
+	energy.With(labels).Set(status.TotalEnergy())
 
-	power.With(labels).Set(status.Module1.OutputPowerAC + status.Module2.OutputPowerAC)
-	energy.With(labels).Set(status.Module1.TotalEnergy + status.Module2.TotalEnergy)
+	for i := range status.Modules {
+		UpdateModule(addr, status.InverterId, &status.Modules[i])
 
-	UpdateModule(addr, status.InverterId, &status.Module1)
-	UpdateModule(addr, status.InverterId, &status.Module2)
+		if status.Raw != nil && i < len(status.Raw.Modules) {
+			UpdateModuleRaw(addr, status.InverterId, status.Modules[i].ModuleId, &status.Raw.Modules[i])
+		}
+	}
 }
 
 func UpdateModule(addr, sn string, module *types.InverterModuleStatus) {
@@ -133,6 +288,26 @@ func UpdateModule(addr, sn string, module *types.InverterModuleStatus) {
 	moduleOutputPowerAC.With(labels).Set(module.OutputPowerAC)
 	moduleTotalEnergy.With(labels).Set(module.TotalEnergy)
 	moduleTemperature.With(labels).Set(module.Temperature)
-	moduleOutputVoltageAC.With(labels).Set(module.OutputPowerAC)
+	moduleOutputVoltageAC.With(labels).Set(module.OutputVoltageAC)
 	moduleOutputFrequencyAC.With(labels).Set(module.OutputFrequencyAC)
 }
+
+func UpdateModuleRaw(addr, sn, moduleId string, raw *types.RawInverterModuleStatus) {
+	labels := prometheus.Labels{
+		"addr":      addr,
+		"sn":        sn,
+		"module_id": moduleId,
+	}
+
+	for name, value := range raw.Fields {
+		labels["field"] = name
+		moduleRawField.With(labels).Set(float64(value))
+	}
+
+	for _, span := range raw.Reserved {
+		for offset, value := range span.Words() {
+			labels["field"] = fmt.Sprintf("reserved_%d", offset)
+			moduleRawField.With(labels).Set(float64(value))
+		}
+	}
+}
@@ -3,16 +3,21 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func ListenAndServe(ctx context.Context, addr, path string, disableExporterMetrics bool) error {
+func ListenAndServe(ctx context.Context, addr, path string, disableExporterMetrics, enableRawMetrics bool) error {
 	if !disableExporterMetrics {
 		reg.MustRegister(collectors.NewGoCollector())
 	}
+	if enableRawMetrics {
+		reg.MustRegister(moduleRawField)
+	}
 
 	mux := http.NewServeMux()
 
@@ -37,5 +42,50 @@ func ListenAndServe(ctx context.Context, addr, path string, disableExporterMetri
 func GetInverter(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	json.NewEncoder(w).Encode(get())
+	update := get()
+
+	// when several inverters report to us, select one by serial number
+	if sn := req.URL.Query().Get("sn"); sn != "" {
+		var ok bool
+		if update, ok = getBySerial(sn); !ok {
+			http.Error(w, fmt.Sprintf("unknown inverter: %s", sn), http.StatusNotFound)
+			return
+		}
+	}
+
+	resp := inverterResponse(update)
+
+	// include undecoded frame contents, if requested
+	if raw, _ := strconv.ParseBool(req.URL.Query().Get("raw")); raw {
+		resp["Raw"] = update.Raw
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Build the JSON representation of the inverter status. In addition to the list of modules, each module is exposed as
+// 'ModuleN' (e.g. 'Module1') for compatibility with templates written against earlier versions of the API.
+func inverterResponse(update inverterUpdate) map[string]any {
+	resp := map[string]any{
+		"InverterId": update.InverterId,
+		"Modules":    update.Modules,
+	}
+
+	for i, module := range update.Modules {
+		resp[fmt.Sprintf("Module%d", i+1)] = module
+	}
+
+	if update.Address != "" {
+		resp["Address"] = update.Address
+	}
+
+	if !update.Timestamp.IsZero() {
+		resp["Timestamp"] = update.Timestamp
+	}
+
+	if wifi, ok := getWiFi(update.InverterId); ok {
+		resp["WiFi"] = wifi
+	}
+
+	return resp
 }
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brandon1024/OpenEVT/internal/admin"
+	"github.com/brandon1024/OpenEVT/internal/types"
+)
+
+func TestGetInverter(t *testing.T) {
+	t.Run("should expose modules as a list and as ModuleN fields", func(t *testing.T) {
+		set("192.0.2.1", types.InverterStatus{
+			InverterId: "31583078",
+			Modules: []types.InverterModuleStatus{
+				{ModuleId: "31583078", OutputPowerAC: 41.5},
+				{ModuleId: "31583079", OutputPowerAC: 33.25},
+			},
+		}, time.Now())
+
+		rec := httptest.NewRecorder()
+		GetInverter(rec, httptest.NewRequest("GET", "/inverter", nil))
+
+		var resp struct {
+			InverterId string
+			Modules    []types.InverterModuleStatus
+			Module1    types.InverterModuleStatus
+			Module2    types.InverterModuleStatus
+		}
+
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		switch {
+		case resp.InverterId != "31583078":
+			t.Fatalf("unexpected inverter serial: %s", resp.InverterId)
+		case len(resp.Modules) != 2:
+			t.Fatalf("unexpected module count: %d", len(resp.Modules))
+		case resp.Module1.OutputPowerAC != 41.5:
+			t.Fatalf("unexpected module1 output power ac: %f", resp.Module1.OutputPowerAC)
+		case resp.Module2.ModuleId != "31583079":
+			t.Fatalf("unexpected module2 id: %s", resp.Module2.ModuleId)
+		}
+	})
+
+	t.Run("should include the address and time the status was received", func(t *testing.T) {
+		ts := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+		UpdateAt("192.0.2.1", &types.InverterStatus{InverterId: "31583078"}, ts)
+
+		rec := httptest.NewRecorder()
+		GetInverter(rec, httptest.NewRequest("GET", "/inverter", nil))
+
+		var resp struct {
+			Address   string
+			Timestamp time.Time
+		}
+
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		switch {
+		case resp.Address != "192.0.2.1":
+			t.Fatalf("unexpected address: %s", resp.Address)
+		case !resp.Timestamp.Equal(ts):
+			t.Fatalf("unexpected timestamp: %v", resp.Timestamp)
+		}
+	})
+}
+
+func TestGetInverterBySerial(t *testing.T) {
+	set("192.0.2.1", types.InverterStatus{InverterId: "31583078", Modules: []types.InverterModuleStatus{{ModuleId: "31583078"}}}, time.Now())
+	set("192.0.2.1", types.InverterStatus{InverterId: "30587612", Modules: []types.InverterModuleStatus{{ModuleId: "30587612"}}}, time.Now())
+
+	t.Run("should select the inverter by serial number", func(t *testing.T) {
+		for _, sn := range []string{"31583078", "30587612"} {
+			rec := httptest.NewRecorder()
+			GetInverter(rec, httptest.NewRequest("GET", "/inverter?sn="+sn, nil))
+
+			var resp struct {
+				InverterId string
+			}
+
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.InverterId != sn {
+				t.Fatalf("unexpected inverter serial: %s", resp.InverterId)
+			}
+		}
+	})
+
+	t.Run("should return not found for unknown inverters", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		GetInverter(rec, httptest.NewRequest("GET", "/inverter?sn=12345678", nil))
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("unexpected status code: %d", rec.Code)
+		}
+	})
+}
+
+func TestGetInverterRaw(t *testing.T) {
+	set("192.0.2.1", types.InverterStatus{
+		InverterId: "31583078",
+		Modules:    []types.InverterModuleStatus{{ModuleId: "31583078"}},
+		Raw: &types.RawInverterStatus{
+			Reserved: []types.RawSpan{{Offset: 10, Data: types.HexBytes{0x70, 0x01}}},
+			Modules:  []types.RawInverterModuleStatus{{Fields: map[string]uint64{"input_voltage_dc": 0x4506}}},
+		},
+	}, time.Now())
+
+	t.Run("should omit raw fields by default", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		GetInverter(rec, httptest.NewRequest("GET", "/inverter", nil))
+
+		var resp map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, ok := resp["Raw"]; ok {
+			t.Fatalf("unexpected raw fields in response")
+		}
+	})
+
+	t.Run("should include raw fields if requested", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		GetInverter(rec, httptest.NewRequest("GET", "/inverter?raw=1", nil))
+
+		var resp struct {
+			Raw types.RawInverterStatus
+		}
+
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		switch {
+		case len(resp.Raw.Reserved) != 1 || resp.Raw.Reserved[0].Data.String() != "7001":
+			t.Fatalf("unexpected reserved bytes: %+v", resp.Raw.Reserved)
+		case len(resp.Raw.Modules) != 1 || resp.Raw.Modules[0].Fields["input_voltage_dc"] != 0x4506:
+			t.Fatalf("unexpected raw modules: %+v", resp.Raw.Modules)
+		}
+	})
+}
+
+func TestGetInverterWiFi(t *testing.T) {
+	set("192.0.2.1", types.InverterStatus{InverterId: "31583078"}, time.Now())
+	set("192.0.2.2", types.InverterStatus{InverterId: "30587612"}, time.Now())
+
+	UpdateWiFi("192.0.2.1", "31583078", &admin.Status{IPAddress: "192.0.2.1", RSSI: -71, WorkMode: admin.WorkModeTCPServer}, time.Now())
+
+	t.Run("should include the status of the Wi-Fi module", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		GetInverter(rec, httptest.NewRequest("GET", "/inverter?sn=31583078", nil))
+
+		var resp struct {
+			WiFi *admin.Status
+		}
+
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if resp.WiFi == nil || resp.WiFi.RSSI != -71 || resp.WiFi.WorkMode != admin.WorkModeTCPServer {
+			t.Fatalf("unexpected wifi status: %+v", resp.WiFi)
+		}
+	})
+
+	t.Run("should omit the status of the Wi-Fi module if not scraped", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		GetInverter(rec, httptest.NewRequest("GET", "/inverter?sn=30587612", nil))
+
+		var resp map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, ok := resp["WiFi"]; ok {
+			t.Fatalf("unexpected wifi status in response")
+		}
+	})
+}